@@ -1,6 +1,8 @@
 #ifndef _C_PLUGIN_H_
 #define _C_PLUGIN_H_
 
+#include <stdio.h>
+#include <string.h>
 #include "write_plugin.h"
 
 #ifdef __cplusplus
@@ -21,68 +23,218 @@ extern "C" {
 #define CLOSE_LIBRARY dlclose
 #endif
 
+// 插件接口的函数指针类型
+typedef void (*PLUGIN_INFO_FN)(PluginInfo*);
+typedef int (*ABI_VERSION_FN)();
+typedef int (*LAST_ERROR_FN)(char*, int);
+typedef int (*LOGIN_FN)(char*);
+typedef void (*LOGOUT_FN)();
+typedef int (*WRITE_RT_ANALOG_FN)(int32_t, int64_t, int64_t, Analog*, int64_t, bool);
+typedef int (*WRITE_RT_DIGITAL_FN)(int32_t, int64_t, int64_t, Digital*, int64_t, bool);
+typedef int (*WRITE_RT_ANALOG_LIST_FN)(int32_t, int64_t, int64_t*, Analog**, int64_t*, int64_t);
+typedef int (*WRITE_RT_DIGITAL_LIST_FN)(int32_t, int64_t, int64_t*, Digital**, int64_t*, int64_t);
+typedef int (*WRITE_HIS_ANALOG_FN)(int32_t, int64_t, int64_t, Analog*, int64_t);
+typedef int (*WRITE_HIS_DIGITAL_FN)(int32_t, int64_t, int64_t, Digital*, int64_t);
+typedef int (*WRITE_STATIC_ANALOG_FN)(int32_t, int64_t, StaticAnalog*, int64_t, int64_t);
+typedef int (*WRITE_STATIC_DIGITAL_FN)(int32_t, int64_t, StaticDigital*, int64_t, int64_t);
+
+// 插件句柄, 加载插件时一次性解析所有接口, 未导出的接口为NULL
 typedef struct _DYLIB_HANDLE_ {
     LIBRARY_HANDLE handle;
+    int abi_version;
+
+    // 可选接口
+    PLUGIN_INFO_FN plugin_info;
+    ABI_VERSION_FN abi_version_fn;
+    LAST_ERROR_FN last_error;
+    WRITE_RT_ANALOG_LIST_FN write_rt_analog_list;
+    WRITE_RT_DIGITAL_LIST_FN write_rt_digital_list;
+
+    // 必要接口
+    LOGIN_FN login;
+    LOGOUT_FN logout;
+    WRITE_RT_ANALOG_FN write_rt_analog;
+    WRITE_RT_DIGITAL_FN write_rt_digital;
+    WRITE_HIS_ANALOG_FN write_his_analog;
+    WRITE_HIS_DIGITAL_FN write_his_digital;
+    WRITE_STATIC_ANALOG_FN write_static_analog;
+    WRITE_STATIC_DIGITAL_FN write_static_digital;
 } DYLIB_HANDLE;
 
+// 最近一次加载插件或解析接口失败的错误信息
+static char dylib_error_msg[512] = {0};
+
+// 记录加载插件或解析接口失败的错误信息
+static void dylib_set_error(const char *name) {
+#ifdef _WIN32
+    snprintf(dylib_error_msg, sizeof(dylib_error_msg), "%s: error code %lu", name, (unsigned long) GetLastError());
+#else
+    const char *err = dlerror();
+    snprintf(dylib_error_msg, sizeof(dylib_error_msg), "%s", err != NULL ? err : name);
+#endif
+}
+
+// 获取最近一次加载插件或解析接口失败的错误信息
+const char *dylib_error() {
+    return dylib_error_msg;
+}
+
+// 解析接口, 接口不存在时返回NULL并记录错误信息
+static void *dylib_symbol(LIBRARY_HANDLE handle, const char *name) {
+    void *symbol = (void *) GET_FUNCTION(handle, name);
+    if (symbol == NULL) {
+        dylib_set_error(name);
+    }
+    return symbol;
+}
+
+// 加载插件并解析所有接口
+// 加载失败时返回的handle.handle为NULL, 错误信息可以通过 dylib_error 获取
 DYLIB_HANDLE load_library(char *name) {
-    DYLIB_HANDLE handle = {LOAD_LIBRARY(name)};
+    DYLIB_HANDLE handle;
+    memset(&handle, 0, sizeof(handle));
+    dylib_error_msg[0] = '\0';
+
+    handle.handle = LOAD_LIBRARY(name);
+    if (handle.handle == NULL) {
+        dylib_set_error(name);
+        return handle;
+    }
+
+    handle.login = (LOGIN_FN) dylib_symbol(handle.handle, "login");
+    handle.logout = (LOGOUT_FN) dylib_symbol(handle.handle, "logout");
+    handle.write_rt_analog = (WRITE_RT_ANALOG_FN) dylib_symbol(handle.handle, "write_rt_analog");
+    handle.write_rt_digital = (WRITE_RT_DIGITAL_FN) dylib_symbol(handle.handle, "write_rt_digital");
+    handle.write_his_analog = (WRITE_HIS_ANALOG_FN) dylib_symbol(handle.handle, "write_his_analog");
+    handle.write_his_digital = (WRITE_HIS_DIGITAL_FN) dylib_symbol(handle.handle, "write_his_digital");
+    handle.write_static_analog = (WRITE_STATIC_ANALOG_FN) dylib_symbol(handle.handle, "write_static_analog");
+    handle.write_static_digital = (WRITE_STATIC_DIGITAL_FN) dylib_symbol(handle.handle, "write_static_digital");
+
+    // 可选接口不存在时不记录错误信息, 以免覆盖必要接口的错误信息
+    char saved_error[sizeof(dylib_error_msg)];
+    memcpy(saved_error, dylib_error_msg, sizeof(saved_error));
+    handle.plugin_info = (PLUGIN_INFO_FN) dylib_symbol(handle.handle, "plugin_info");
+    handle.abi_version_fn = (ABI_VERSION_FN) dylib_symbol(handle.handle, "abi_version");
+    handle.last_error = (LAST_ERROR_FN) dylib_symbol(handle.handle, "last_error");
+    handle.write_rt_analog_list = (WRITE_RT_ANALOG_LIST_FN) dylib_symbol(handle.handle, "write_rt_analog_list");
+    handle.write_rt_digital_list = (WRITE_RT_DIGITAL_LIST_FN) dylib_symbol(handle.handle, "write_rt_digital_list");
+    memcpy(dylib_error_msg, saved_error, sizeof(saved_error));
+
+    handle.abi_version = 1;
+    if (handle.abi_version_fn != NULL) {
+        handle.abi_version = handle.abi_version_fn();
+    } else if (handle.plugin_info != NULL) {
+        PluginInfo info;
+        memset(&info, 0, sizeof(info));
+        handle.plugin_info(&info);
+        handle.abi_version = info.abi_version;
+    }
     return handle;
 }
 
+// 获取插件信息, 插件未实现plugin_info时使用默认值
+void dy_plugin_info(DYLIB_HANDLE handle, PluginInfo *info) {
+    memset(info, 0, sizeof(PluginInfo));
+    info->abi_version = handle.abi_version;
+    info->max_batch_size = 0;
+    info->reentrant = true;
+    info->support_list = true;
+    info->support_his = true;
+    info->support_static = true;
+    if (handle.plugin_info != NULL) {
+        handle.plugin_info(info);
+        info->vendor[sizeof(info->vendor)-1] = '\0';
+        info->abi_version = handle.abi_version;
+    }
+}
+
 int close_library(DYLIB_HANDLE  handle) {
     return CLOSE_LIBRARY(handle.handle);
 }
 
+// 写入失败后获取错误信息, 插件未实现last_error时err_buf为空字符串
+// 备注: 必须与写入接口在同一个C调用中执行, 保证last_error与写入接口在同一线程
+int dy_check_error(DYLIB_HANDLE handle, int code, char *err_buf, int err_len) {
+    if (err_buf != NULL && err_len > 0) {
+        err_buf[0] = '\0';
+    }
+    if (handle.abi_version < 2) {
+        return WRITE_OK;
+    }
+    if (code != WRITE_OK && err_buf != NULL && err_len > 0 && handle.last_error != NULL) {
+        handle.last_error(err_buf, err_len);
+        err_buf[err_len-1] = '\0';
+    }
+    return code;
+}
+
 int dy_login(DYLIB_HANDLE handle, char* param) {
-    int (*login)(char*) = (int (*)(char*)) GET_FUNCTION(handle.handle, "login");
-    return login(param);
+    return handle.login(param);
 }
 
 void dy_logout(DYLIB_HANDLE handle) {
-    void (*logout)() = (void (*)()) GET_FUNCTION(handle.handle, "logout");
-    logout();
+    handle.logout();
 }
 
-void dy_write_rt_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Analog *analog, int64_t count, bool is_fast) {
-    void (*write_rt_analog)(int32_t, int64_t, int64_t, Analog*, int64_t, bool) = (void (*)(int32_t, int64_t, int64_t, Analog*, int64_t, bool)) GET_FUNCTION(handle.handle, "write_rt_analog");
-    write_rt_analog(magic, unit_id, time, analog, count, is_fast);
+int dy_write_rt_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Analog *analog, int64_t count, bool is_fast, char *err_buf, int err_len) {
+    int code = handle.write_rt_analog(magic, unit_id, time, analog, count, is_fast);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
-void dy_write_rt_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Digital *digital, int64_t count, bool is_fast) {
-    void (*write_rt_digital)(int64_t, int64_t, int64_t, Digital*, int64_t, bool) = (void (*)(int64_t, int64_t, int64_t, Digital*, int64_t, bool)) GET_FUNCTION(handle.handle, "write_rt_digital");
-    write_rt_digital(magic, unit_id, time, digital, count, is_fast);
+int dy_write_rt_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Digital *digital, int64_t count, bool is_fast, char *err_buf, int err_len) {
+    int code = handle.write_rt_digital(magic, unit_id, time, digital, count, is_fast);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
-void dy_write_rt_analog_list(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count) {
-    void (*write_rt_analog_list)(int32_t, int64_t, int64_t*, Analog**, int64_t*, int64_t) = (void (*)(int32_t, int64_t, int64_t*, Analog**, int64_t*, int64_t)) GET_FUNCTION(handle.handle, "write_rt_analog_list");
-    write_rt_analog_list(magic, unit_id, time, analog_array_array_ptr, array_count, count);
+// 插件未实现 write_rt_analog_list 时, 使用 write_rt_analog 逐个断面写入, 遇到错误立即返回
+int dy_write_rt_analog_list(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count, char *err_buf, int err_len) {
+    if (handle.write_rt_analog_list != NULL) {
+        int code = handle.write_rt_analog_list(magic, unit_id, time, analog_array_array_ptr, array_count, count);
+        return dy_check_error(handle, code, err_buf, err_len);
+    }
+    for (int64_t i = 0; i < count; i++) {
+        int code = dy_write_rt_analog(handle, magic, unit_id, time[i], analog_array_array_ptr[i], array_count[i], true, err_buf, err_len);
+        if (code != WRITE_OK) {
+            return code;
+        }
+    }
+    return WRITE_OK;
 }
 
-void dy_write_rt_digital_list(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count) {
-    void (*write_rt_digital_list)(int32_t, int64_t, int64_t*, Digital**, int64_t*, int64_t) = (void (*)(int32_t, int64_t, int64_t*, Digital**, int64_t*, int64_t)) GET_FUNCTION(handle.handle, "write_rt_digital_list");
-    write_rt_digital_list(magic, unit_id, time, digital_array_array_ptr, array_count, count);
+// 插件未实现 write_rt_digital_list 时, 使用 write_rt_digital 逐个断面写入, 遇到错误立即返回
+int dy_write_rt_digital_list(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count, char *err_buf, int err_len) {
+    if (handle.write_rt_digital_list != NULL) {
+        int code = handle.write_rt_digital_list(magic, unit_id, time, digital_array_array_ptr, array_count, count);
+        return dy_check_error(handle, code, err_buf, err_len);
+    }
+    for (int64_t i = 0; i < count; i++) {
+        int code = dy_write_rt_digital(handle, magic, unit_id, time[i], digital_array_array_ptr[i], array_count[i], true, err_buf, err_len);
+        if (code != WRITE_OK) {
+            return code;
+        }
+    }
+    return WRITE_OK;
 }
 
 
-void dy_write_his_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Analog *analog, int64_t count) {
-    void (*write_his_analog)(int32_t, int64_t, int64_t, Analog*, int64_t) = (void (*)(int32_t, int64_t, int64_t, Analog*, int64_t)) GET_FUNCTION(handle.handle, "write_his_analog");
-    write_his_analog(magic, unit_id, time, analog, count);
+int dy_write_his_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Analog *analog, int64_t count, char *err_buf, int err_len) {
+    int code = handle.write_his_analog(magic, unit_id, time, analog, count);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
-void dy_write_his_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Digital *digital, int64_t count) {
-    void (*write_his_digital)(int32_t, int64_t, int64_t, Digital*, int64_t) = (void (*)(int32_t, int64_t, int64_t, Digital*, int64_t)) GET_FUNCTION(handle.handle, "write_his_digital");
-    write_his_digital(magic, unit_id, time, digital, count);
+int dy_write_his_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, int64_t time, Digital *digital, int64_t count, char *err_buf, int err_len) {
+    int code = handle.write_his_digital(magic, unit_id, time, digital, count);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
-void dy_write_static_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, StaticAnalog *static_analog, int64_t count, int64_t type) {
-    void (*write_static_analog)(int32_t, int64_t, StaticAnalog*, int64_t, int64_t) = (void (*)(int32_t, int64_t, StaticAnalog*, int64_t, int64_t)) GET_FUNCTION(handle.handle, "write_static_analog");
-    write_static_analog(magic, unit_id, static_analog, count, type);
+int dy_write_static_analog(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, StaticAnalog *static_analog, int64_t count, int64_t type, char *err_buf, int err_len) {
+    int code = handle.write_static_analog(magic, unit_id, static_analog, count, type);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
-void dy_write_static_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, StaticDigital *static_digital, int64_t count, int64_t type) {
-    void (*write_static_digital)(int32_t, int64_t, StaticDigital*, int64_t, int64_t) = (void (*)(int32_t, int64_t, StaticDigital*, int64_t, int64_t)) GET_FUNCTION(handle.handle, "write_static_digital");
-    write_static_digital(magic, unit_id, static_digital, count, type);
+int dy_write_static_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id, StaticDigital *static_digital, int64_t count, int64_t type, char *err_buf, int err_len) {
+    int code = handle.write_static_digital(magic, unit_id, static_digital, count, type);
+    return dy_check_error(handle, code, err_buf, err_len);
 }
 
 
@@ -90,4 +242,4 @@ void dy_write_static_digital(DYLIB_HANDLE handle, int32_t magic, int64_t unit_id
 }
 #endif
 
-#endif // _C_PLUGIN_H_
\ No newline at end of file
+#endif // _C_PLUGIN_H_
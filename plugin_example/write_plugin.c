@@ -1,6 +1,48 @@
 #include <stdio.h>
+#include <string.h>
 #include "write_plugin.h"
 
+#ifdef _WIN32
+#define THREAD_LOCAL __declspec(thread)
+#else
+#define THREAD_LOCAL __thread
+#endif
+
+// 当前线程最近一次写入失败的错误信息
+static THREAD_LOCAL char last_error_msg[256] = {0};
+
+// 获取插件信息
+void plugin_info(PluginInfo *info) {
+    info->abi_version = WRITE_PLUGIN_ABI_VERSION;
+    strncpy(info->vendor, "example", sizeof(info->vendor) - 1);
+    info->max_batch_size = 0;
+    info->reentrant = true;
+    info->support_list = true;
+    info->support_his = true;
+    info->support_static = true;
+}
+
+// 返回插件实现的ABI版本号
+int abi_version() {
+    return WRITE_PLUGIN_ABI_VERSION;
+}
+
+// 记录错误信息并返回错误码
+static int set_last_error(int code, const char *msg) {
+    strncpy(last_error_msg, msg, sizeof(last_error_msg) - 1);
+    return code;
+}
+
+// 获取当前线程最近一次写入失败的错误信息
+int last_error(char *buf, int len) {
+    if (buf == NULL || len <= 0) {
+        return 0;
+    }
+    strncpy(buf, last_error_msg, len - 1);
+    buf[len - 1] = '\0';
+    return strlen(buf);
+}
+
 // 登陆数据库
 int login(char *param) {
     if (param != NULL) {
@@ -18,7 +60,11 @@ void logout() {
 }
 
 // 写实时模拟量
-void write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count, bool is_fast) {
+int write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count, bool is_fast) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     // if (is_fast) {
     //     printf("write rt analog(fast): unit_id: %lld, time: %lld, count: %lld\n", unit_id, time, count);
     // } else {
@@ -55,10 +101,16 @@ void write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analo
             }
         }
     }
+
+    return WRITE_OK;
 }
 
 // 写实时数字量
-void write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count, bool is_fast) {
+int write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count, bool is_fast) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     // if (is_fast) {
     //     printf("write rt digital(fast): unit_id: %lld, time: %lld, count: %lld\n", unit_id, time, count);
     // } else {
@@ -86,10 +138,16 @@ void write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *dig
             }
         }
     }
+
+    return WRITE_OK;
 }
 
 // 写实时模拟量
-void write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count) {
+int write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     printf("write rt analog: unit_id: %lld, section count: %lld\n", unit_id, count);
 
     for (int64_t i=0; i<count; i++) {
@@ -97,15 +155,27 @@ void write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog
             printf("----> %lld, %lld", unit_id, analog_array_array_ptr[i]->global_id);
         }
     }
+
+    return WRITE_OK;
 }
 
 // 写实时数字量
-void write_rt_digital_list(int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count) {
+int write_rt_digital_list(int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     printf("write rt digital: unit_id: %lld, section count: %lld\n", unit_id, count);
+
+    return WRITE_OK;
 }
 
 // 写历史模拟量
-void write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count) {
+int write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     // printf("write his analog: unit_id: %lld, time: %lld, count: %lld\n", unit_id, time, count);
     int sum = 0;
 
@@ -129,15 +199,27 @@ void write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *anal
             }
         }
     }
+
+    return WRITE_OK;
 }
 
 // 写历史数字量
-void write_his_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count) {
+int write_his_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     printf("write his digital: unit_id: %lld, time: %lld, count: %lld\n", unit_id, time, count);
+
+    return WRITE_OK;
 }
 
 // 写静态模拟量
-void write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_analog_array_ptr, int64_t count, int64_t type) {
+int write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_analog_array_ptr, int64_t count, int64_t type) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     if (type == 0) {
         printf("write realtime static analog(fast): unit_id: %lld, count: %lld\n", unit_id, count);
     } else if (type == 1) {
@@ -167,10 +249,16 @@ void write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_an
                 printf("unit_id != unit2, %lld, %lld \n", unit_id, unit_id2);
             }
         }
+
+    return WRITE_OK;
 }
 
 // 写静态数字量
-void write_static_digital(int32_t magic, int64_t unit_id, StaticDigital *static_digital_array_ptr, int64_t count, int64_t type) {
+int write_static_digital(int32_t magic, int64_t unit_id, StaticDigital *static_digital_array_ptr, int64_t count, int64_t type) {
+    if (count <= 0) {
+        return set_last_error(WRITE_ERR_INVALID_DATA, "empty section");
+    }
+
     if (type == 0) {
         printf("write realtime static digital(fast): unit_id: %lld, count: %lld\n", unit_id, count);
     } else if (type == 1) {
@@ -197,4 +285,6 @@ void write_static_digital(int32_t magic, int64_t unit_id, StaticDigital *static_
                 printf("unit_id != unit2, %lld, %lld\n", unit_id, unit_id2);
             }
         }
+
+    return WRITE_OK;
 }
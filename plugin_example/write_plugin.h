@@ -24,6 +24,20 @@
 extern "C" {
 #endif
 
+// 插件ABI版本号
+// 1: 写入接口无返回值(旧版本插件, 未导出abi_version)
+// 2: 写入接口返回错误码, 并可通过last_error获取错误信息
+#define WRITE_PLUGIN_ABI_VERSION 2
+
+// 写入接口返回的错误码, 0表示写入成功, 非0表示写入失败
+#define WRITE_OK 0                // 写入成功
+#define WRITE_ERR_UNKNOWN 1       // 未知错误
+#define WRITE_ERR_CONNECTION 2    // 连接错误, 如网络断开, 未登录
+#define WRITE_ERR_TIMEOUT 3       // 写入超时
+#define WRITE_ERR_INVALID_DATA 4  // 数据错误, 如点不存在, 数据类型不匹配
+#define WRITE_ERR_OVERLOAD 5      // 数据库过载, 拒绝写入
+#define WRITE_ERR_UNSUPPORTED 6   // 插件不支持该接口
+
 // 模拟量结构
 typedef struct _Analog_ {
     int64_t global_id; // 全局ID
@@ -87,6 +101,34 @@ typedef struct _StaticDigital_ {
     char unit[32];      // UNIT, 32Byte
 } StaticDigital;
 
+// 插件信息, 用于写入程序根据插件能力选择写入方式
+typedef struct _PluginInfo_ {
+    int32_t abi_version;     // ABI版本号, 应当填写 WRITE_PLUGIN_ABI_VERSION
+    char vendor[64];         // 厂商名称
+    int64_t max_batch_size;  // 批量写入接口单次最多写入的断面数量, 0表示不限制
+    bool reentrant;          // 写入接口是否可重入, 为false时写入程序会串行调用插件接口
+    bool support_list;       // 是否支持批量写入(write_rt_analog_list, write_rt_digital_list)
+    bool support_his;        // 是否支持写历史值(write_his_analog, write_his_digital)
+    bool support_static;     // 是否支持写静态值(write_static_analog, write_static_digital)
+} PluginInfo;
+
+// 获取插件信息(可选接口)
+// info: 由写入程序分配并填写默认值, 插件按需修改
+// 默认值: 可重入, 批量写入大小不限制, 支持批量写入, 历史值和静态值写入
+// 备注: 不支持的接口可以不导出; 支持批量写入但未导出批量写入接口时, 写入程序会逐个断面调用 write_rt_analog/write_rt_digital
+void plugin_info(PluginInfo *info);
+
+// 返回插件实现的ABI版本号, 应当直接返回 WRITE_PLUGIN_ABI_VERSION
+// 备注: 未导出此接口的插件被视为ABI版本1, 写入接口的返回值会被忽略
+int abi_version();
+
+// 获取调用线程最近一次写入失败的错误信息(可选接口)
+// buf: 错误信息缓冲区, 插件需保证写入的字符串以'\0'结尾
+// len: 缓冲区长度
+// 返回值: 错误信息长度, 没有错误信息时返回0
+// 备注: 写入程序会在写入接口返回非0错误码后, 在同一线程中立即调用此接口
+int last_error(char *buf, int len);
+
 // 登陆数据库
 // param是命令行向login传递的参数, 如果参数为空则param为NULL
 int login(char *param);
@@ -101,7 +143,8 @@ void logout();
 // analog_array_ptr: 指向模拟量数组的指针
 // count: 数组长度
 // is_fast: 当为true时表示写快采点, 当为false时表示写普通点
-void write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count, bool is_fast);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count, bool is_fast);
 
 // 写实时数字量
 // magic: 魔数, 用于标记测试数据集
@@ -110,7 +153,8 @@ void write_rt_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analo
 // digital_array_ptr: 指向数字量数组的指针
 // count: 数组长度
 // is_fast: 当为true时表示写快采点, 当为false时表示写普通点
-void write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count, bool is_fast);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count, bool is_fast);
 
 // 批量写实时模拟量
 // magic: 魔数, 用于标记测试数据集
@@ -120,7 +164,8 @@ void write_rt_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *dig
 // analog_array_array_ptr: 模拟量断面数组, 包含count个断面的模拟量
 // array_count: 每个断面中包含值的数量
 // 备注: 只有写快采点的时候会调用此接口
-void write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog **analog_array_array_ptr, int64_t *array_count, int64_t count);
 
 // 批量写实时数字量
 // magic: 魔数, 用于标记测试数据集
@@ -130,7 +175,8 @@ void write_rt_analog_list(int32_t magic, int64_t unit_id, int64_t *time, Analog
 // analog_array_array_ptr: 数字量断面数组, 包含count个断面的数字量
 // array_count: 每个断面中包含值的数量
 // 备注: 只有写快采点的时候会调用此接口
-void write_rt_digital_list(int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_rt_digital_list(int32_t magic, int64_t unit_id, int64_t *time, Digital **digital_array_array_ptr, int64_t *array_count, int64_t count);
 
 // 写历史模拟量
 // magic: 魔数, 用于标记测试数据集
@@ -138,7 +184,8 @@ void write_rt_digital_list(int32_t magic, int64_t unit_id, int64_t *time, Digita
 // time: 断面时间戳
 // analog_array_ptr: 指向模拟量数组的指针
 // count: 数组长度
-void write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *analog_array_ptr, int64_t count);
 
 // 写历史数字量
 // magic: 魔数, 用于标记测试数据集
@@ -146,7 +193,8 @@ void write_his_analog(int32_t magic, int64_t unit_id, int64_t time, Analog *anal
 // time: 断面时间戳
 // digital_array_ptr: 指向数字量数组的指针
 // count: 数组长度
-void write_his_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_his_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *digital_array_ptr, int64_t count);
 
 // 写静态模拟量
 // magic: 魔数, 用于标记测试数据集
@@ -154,7 +202,8 @@ void write_his_digital(int32_t magic, int64_t unit_id, int64_t time, Digital *di
 // static_analog_array_ptr: 指向静态模拟量数组的指针
 // count: 数组长度
 // type: 数据类型, 通过命令行传递, 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点
-void write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_analog_array_ptr, int64_t count, int64_t type);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_analog_array_ptr, int64_t count, int64_t type);
 
 // 写静态数字量
 // magic: 魔数, 用于标记测试数据集
@@ -162,7 +211,8 @@ void write_static_analog(int32_t magic, int64_t unit_id, StaticAnalog *static_an
 // static_digital_array_ptr: 指向静态数字量数组的指针
 // count: 数组长度
 // type: 数据类型, 通过命令行传递, 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点
-void write_static_digital(int32_t magic, int64_t unit_id, StaticDigital *static_digital_array_ptr, int64_t count, int64_t type);
+// 返回值: WRITE_OK表示写入成功, 其他值为 WRITE_ERR_* 错误码
+int write_static_digital(int32_t magic, int64_t unit_id, StaticDigital *static_digital_array_ptr, int64_t count, int64_t type);
 
 #ifdef __cplusplus
 }
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// LatencyStats 一类断面的写入耗时和失败统计
+type LatencyStats struct {
+	Histogram          *Histogram             // 每次写入的耗时
+	SectionCount       int64                  // 断面数量
+	PNumCount          int64                  // PNum数量
+	BatchSize          int64                  // 单次写入的最大断面数量, 开启快采点缓存时大于1
+	FailedSectionCount int64                  // 写入失败的断面数量
+	FailedPNumCount    int64                  // 写入失败的PNum数量
+	ErrorCodes         map[WriteErrorCode]int // 每种错误码出现的次数
+}
+
+func NewLatencyStats(precision int) (*LatencyStats, error) {
+	histogram, err := NewHistogram(precision)
+	if err != nil {
+		return nil, err
+	}
+	return &LatencyStats{Histogram: histogram, BatchSize: 1, ErrorCodes: make(map[WriteErrorCode]int)}, nil
+}
+
+func (s *LatencyStats) record(duration time.Duration, sectionCount int64, pnumCount int64, failedSectionCount int64, failedPNumCount int64, errs ...[]error) {
+	s.Histogram.Record(duration)
+	s.SectionCount += sectionCount
+	s.PNumCount += pnumCount
+	if sectionCount > s.BatchSize {
+		s.BatchSize = sectionCount
+	}
+	s.FailedSectionCount += failedSectionCount
+	s.FailedPNumCount += failedPNumCount
+	for _, list := range errs {
+		for _, err := range list {
+			s.ErrorCodes[WriteErrorCodeOf(err)]++
+		}
+	}
+}
+
+// Merge 将other中的统计合并到s
+func (s *LatencyStats) Merge(other *LatencyStats) {
+	s.Histogram.Merge(other.Histogram)
+	s.SectionCount += other.SectionCount
+	s.PNumCount += other.PNumCount
+	if other.BatchSize > s.BatchSize {
+		s.BatchSize = other.BatchSize
+	}
+	s.FailedSectionCount += other.FailedSectionCount
+	s.FailedPNumCount += other.FailedPNumCount
+	for code, n := range other.ErrorCodes {
+		s.ErrorCodes[code] += n
+	}
+}
+
+// failedCount 写入失败时返回断面数量和PNum数量, 否则返回0
+func failedCount(info WriteSectionInfo) (int64, int64) {
+	if len(info.Errors) == 0 {
+		return 0, 0
+	}
+	return info.SectionCount, info.PNumCount
+}
+
+// WriteStats 快采点或普通点的写入统计
+// 使用直方图统计耗时, 内存占用与写入的断面数量无关
+type WriteStats struct {
+	lock        *sync.Mutex
+	kind        string        // fast或normal, 用于输出写入明细
+	trace       *TraceWriter  // 写入明细输出, 可以为nil
+	limit       *StopLimit    // 停止条件, 可以为nil
+	UnitNumber  int64         // 机组数量
+	Total       *LatencyStats // 模拟量和数字量合并统计, 同一批断面的模拟量和数字量耗时之和作为一次写入
+	Analog      *LatencyStats // 模拟量
+	Digital     *LatencyStats // 数字量
+	Sleep       time.Duration // 睡眠耗时
+	Schedule    ScheduleStats // 周期性写入的调度统计
+	SkippedRows int64         // 跳过的CSV行数, 即无法读取, 列数错误或无法解析的行
+}
+
+func NewWriteStats(kind string, precision int, trace *TraceWriter) (*WriteStats, error) {
+	s := &WriteStats{lock: new(sync.Mutex), kind: kind, trace: trace}
+	var err error
+	if s.Total, err = NewLatencyStats(precision); err != nil {
+		return nil, err
+	}
+	if s.Analog, err = NewLatencyStats(precision); err != nil {
+		return nil, err
+	}
+	if s.Digital, err = NewLatencyStats(precision); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Record 记录一次写入, analog和digital为同一批断面的模拟量和数字量
+// 同一批断面的模拟量或数字量只要有一个机组写入失败, 该批断面即视为写入失败
+// 断面数量取模拟量和数字量中较多的一个, 只有模拟量或只有数字量时也能正确统计
+func (s *WriteStats) Record(analog WriteSectionInfo, digital WriteSectionInfo) {
+	s.trace.Write(s.kind+"_analog", analog)
+	s.trace.Write(s.kind+"_digital", digital)
+	sections := analog.SectionCount
+	if digital.SectionCount > sections {
+		sections = digital.SectionCount
+	}
+	s.limit.record(sections, analog.PNumCount+digital.PNumCount)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.UnitNumber = analog.UnitNumber
+	aFailedSection, aFailedPNum := failedCount(analog)
+	dFailedSection, dFailedPNum := failedCount(digital)
+	s.Analog.record(analog.Duration, analog.SectionCount, analog.PNumCount, aFailedSection, aFailedPNum, analog.Errors)
+	s.Digital.record(digital.Duration, digital.SectionCount, digital.PNumCount, dFailedSection, dFailedPNum, digital.Errors)
+
+	failedSection := aFailedSection
+	if dFailedSection > failedSection {
+		failedSection = dFailedSection
+	}
+	s.Total.record(
+		analog.Duration+digital.Duration, sections, analog.PNumCount+digital.PNumCount,
+		failedSection, aFailedPNum+dFailedPNum, analog.Errors, digital.Errors,
+	)
+}
+
+// RecordSleep 记录睡眠耗时
+func (s *WriteStats) RecordSleep(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Sleep += d
+}
+
+// RecordSchedule 记录一次周期性写入的调度情况
+// intended为计划写入时间, actual为实际写入时间, duration为写入耗时, period为写入周期
+func (s *WriteStats) RecordSchedule(intended time.Time, actual time.Time, duration time.Duration, period time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Schedule.record(actual.Sub(intended), duration-period)
+}
+
+// RecordCatchUp 记录调度落后时跳过的周期数和合并写入的周期数
+func (s *WriteStats) RecordCatchUp(skipped int, coalesced int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Schedule.Skipped += int64(skipped)
+	s.Schedule.Coalesced += int64(coalesced)
+}
+
+// IsEmpty 是否没有写入记录
+func (s *WriteStats) IsEmpty() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Total.Histogram.Count() == 0
+}
+
+// Merge 将other中的统计合并到s
+func (s *WriteStats) Merge(other *WriteStats) {
+	other.lock.Lock()
+	defer other.lock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if other.UnitNumber > s.UnitNumber {
+		s.UnitNumber = other.UnitNumber
+	}
+	s.Total.Merge(other.Total)
+	s.Analog.Merge(other.Analog)
+	s.Digital.Merge(other.Digital)
+	s.Sleep += other.Sleep
+	s.Schedule.Merge(other.Schedule)
+	s.SkippedRows += other.SkippedRows
+}
+
+// Skip 记录跳过的CSV行数
+func (s *WriteStats) Skip(n int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.SkippedRows += n
+}
+
+// Recorder 单个写入协程的统计, 由 Collector.NewRecorder 创建
+// 每个记录器只在一个协程中写入, 锁只在合并统计时才会发生竞争
+type Recorder struct {
+	Fast   *WriteStats // 快采点, 静态写入时为静态点
+	Normal *WriteStats // 普通点, 写历史值时为历史点
+}
+
+// Collector 一次写入的统计
+// 每个写入协程通过 NewRecorder 获取自己的记录器, 统计时通过 Merge 合并所有记录器
+type Collector struct {
+	precision int
+	trace     *TraceWriter
+	progress  *Progress  // 写入进度, 未开启时为nil
+	metrics   *Metrics   // Prometheus指标服务, 未开启时为nil
+	limit     *StopLimit // 停止条件, 未设置时为nil
+	lock      *sync.Mutex
+	recorders []*Recorder
+	inputs    []*ProgressInput
+}
+
+// NewCollector 创建统计, precision为耗时直方图的有效数字位数, tracePath为写入明细的输出路径, 为空表示不输出
+func NewCollector(precision int, tracePath string) (*Collector, error) {
+	// 提前检查精度, 避免写入开始后才创建记录器失败
+	if _, err := NewHistogram(precision); err != nil {
+		return nil, err
+	}
+	c := &Collector{precision: precision, lock: new(sync.Mutex), recorders: make([]*Recorder, 0), inputs: make([]*ProgressInput, 0)}
+	if tracePath != "" {
+		trace, err := NewTraceWriter(tracePath)
+		if err != nil {
+			return nil, err
+		}
+		c.trace = trace
+	}
+	return c, nil
+}
+
+// NewRecorder 为一个写入协程创建记录器
+func (c *Collector) NewRecorder() *Recorder {
+	fast, _ := NewWriteStats("fast", c.precision, c.trace)
+	normal, _ := NewWriteStats("normal", c.precision, c.trace)
+	fast.limit, normal.limit = c.limit, c.limit
+	r := &Recorder{Fast: fast, Normal: normal}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.recorders = append(c.recorders, r)
+	return r
+}
+
+// StartStopLimit 设置停止条件并开始计时, 写入统计达到上限时平滑退出
+func (c *Collector) StartStopLimit(limit *StopLimit) {
+	c.limit = limit
+	limit.Start()
+}
+
+// StartProgress 开始定期输出写入进度, interval为0时不输出
+func (c *Collector) StartProgress(interval time.Duration, fastCache bool) {
+	if interval <= 0 {
+		return
+	}
+	c.progress = NewProgress(c, interval, fastCache)
+	c.progress.Start()
+}
+
+// ServeMetrics 开始提供Prometheus指标, addr为空时不提供
+func (c *Collector) ServeMetrics(addr string, plugin *WritePlugin) error {
+	if addr == "" {
+		return nil
+	}
+	metrics, err := ServeMetrics(addr, c, plugin)
+	if err != nil {
+		return err
+	}
+	c.metrics = metrics
+	return nil
+}
+
+// Input 注册一组CSV输入, kind为fast或normal, name为输出时的名称, ch为读取后的断面缓存队列
+func (c *Collector) Input(kind string, name string, ch chan Section) *ProgressInput {
+	input := &ProgressInput{kind: kind, name: name, ch: ch}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inputs = append(c.inputs, input)
+	return input
+}
+
+// InputSummary 一类断面所有CSV输入的汇总
+type InputSummary struct {
+	Name     string
+	Length   int   // 缓存队列中的断面数量
+	Capacity int   // 缓存队列大小
+	Read     int64 // 已读取的字节数
+	Total    int64 // CSV文件总大小, 循环读取时乘以读取遍数, 无限循环时不计入
+}
+
+// inputSummary 汇总一类断面的CSV输入, 没有注册输入时返回false
+func (c *Collector) inputSummary(kind string) (InputSummary, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	rtn := InputSummary{}
+	for _, input := range c.inputs {
+		if input.kind != kind {
+			continue
+		}
+		rtn.Name = input.name
+		rtn.Length += len(input.ch)
+		rtn.Capacity += cap(input.ch)
+		rtn.Read += input.read.Load()
+		rtn.Total += input.total.Load()
+	}
+	return rtn, rtn.Name != ""
+}
+
+// Merge 写入结束后合并所有记录器, 返回快采点和普通点的统计, 同时停止输出写入进度, 输出触发的停止条件
+func (c *Collector) Merge() (*WriteStats, *WriteStats) {
+	if c.progress != nil {
+		c.progress.Stop()
+		c.progress = nil
+	}
+	if c.limit != nil {
+		c.limit.Stop()
+		c.limit.LogStopReason()
+	}
+	return c.merge()
+}
+
+// merge 合并所有记录器, 写入过程中也可以调用
+func (c *Collector) merge() (*WriteStats, *WriteStats) {
+	fast, _ := NewWriteStats("fast", c.precision, nil)
+	normal, _ := NewWriteStats("normal", c.precision, nil)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, r := range c.recorders {
+		fast.Merge(r.Fast)
+		normal.Merge(r.Normal)
+	}
+	for _, input := range c.inputs {
+		if input.kind == "fast" {
+			fast.SkippedRows += input.skipped.Load()
+		} else {
+			normal.SkippedRows += input.skipped.Load()
+		}
+	}
+	return fast, normal
+}
+
+// Close 命令结束时(包括登录失败等提前返回)停止指标服务, 关闭写入明细输出, 测试场景的下一个阶段可以使用相同的 --metrics_addr
+func (c *Collector) Close() {
+	if c.metrics != nil {
+		c.metrics.Close()
+	}
+	if c.trace == nil {
+		return
+	}
+	if err := c.trace.Close(); err != nil {
+		log.Printf("写入明细输出失败: %v, %v\n", c.trace.path, err)
+	} else {
+		log.Printf("写入明细已输出: %v, 记录数量: %v\n", c.trace.path, c.trace.count)
+	}
+}
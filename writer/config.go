@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// FastCacheBatchSize 开启快采点缓存时, 默认每次批量写入的断面数量
+const FastCacheBatchSize = 100
+
+// PeriodicConfig 周期性写入的写入周期, 过载保护和缓存参数, 时间单位均为毫秒
+// 默认值为 CacheSize 等常量, 可以通过 --config 指定的YAML文件修改, 命令行参数优先于配置文件
+type PeriodicConfig struct {
+	CacheSize                       int `yaml:"cache_size" json:"cache_size"`                                                 // 缓存队列大小
+	OverloadProtectionWriteDuration int `yaml:"overload_protection_write_duration" json:"overload_protection_write_duration"` // 过载保护持续时间, 只有开启过载保护时有效
+	OverloadProtectionWritePeriodic int `yaml:"overload_protection_write_periodic" json:"overload_protection_write_periodic"` // 过载保护写入周期, 只有开启过载保护时有效
+	FastRegularWritePeriodic        int `yaml:"fast_regular_write_periodic" json:"fast_regular_write_periodic"`               // 快采点写入周期
+	NormalRegularWritePeriodic      int `yaml:"normal_regular_write_periodic" json:"normal_regular_write_periodic"`           // 普通点写入周期, 写历史值时为历史点写入周期
+	FastCacheBatchSize              int `yaml:"fast_cache_batch_size" json:"fast_cache_batch_size"`                           // 开启快采点缓存时, 每次批量写入的断面数量
+}
+
+// DefaultPeriodicConfig 默认的周期性写入参数
+func DefaultPeriodicConfig() PeriodicConfig {
+	return PeriodicConfig{
+		CacheSize:                       CacheSize,
+		OverloadProtectionWriteDuration: OverloadProtectionWriteDuration,
+		OverloadProtectionWritePeriodic: OverloadProtectionWritePeriodic,
+		FastRegularWritePeriodic:        FastRegularWritePeriodic,
+		NormalRegularWritePeriodic:      NormalRegularWritePeriodic,
+		FastCacheBatchSize:              FastCacheBatchSize,
+	}
+}
+
+// fields 参数名称(与命令行参数和配置文件的键相同)和对应的字段
+func (c *PeriodicConfig) fields() []struct {
+	name  string
+	value *int
+} {
+	return []struct {
+		name  string
+		value *int
+	}{
+		{"cache_size", &c.CacheSize},
+		{"overload_protection_write_duration", &c.OverloadProtectionWriteDuration},
+		{"overload_protection_write_periodic", &c.OverloadProtectionWritePeriodic},
+		{"fast_regular_write_periodic", &c.FastRegularWritePeriodic},
+		{"normal_regular_write_periodic", &c.NormalRegularWritePeriodic},
+		{"fast_cache_batch_size", &c.FastCacheBatchSize},
+	}
+}
+
+// Check 检查参数, 过载保护持续时间可以为0(不进行过载保护), 其他参数必须大于0
+func (c PeriodicConfig) Check() error {
+	for _, f := range c.fields() {
+		if *f.value < 0 || (*f.value == 0 && f.name != "overload_protection_write_duration") {
+			return fmt.Errorf("周期性写入参数错误: %v=%v, 必须大于0", f.name, *f.value)
+		}
+	}
+	return nil
+}
+
+// LoadPeriodicConfig 读取周期性写入参数: 先使用默认值, 再读取 --config 指定的配置文件, 最后使用命令行中指定的参数
+func LoadPeriodicConfig(cmd *cobra.Command) (PeriodicConfig, error) {
+	config := DefaultPeriodicConfig()
+	path, _ := cmd.Flags().GetString("config")
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("读取配置文件失败: %v, %v", path, err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&config); err != nil {
+			return config, fmt.Errorf("解析配置文件失败: %v, %v", path, err)
+		}
+	}
+	for _, f := range config.fields() {
+		if cmd.Flags().Changed(f.name) {
+			*f.value, _ = cmd.Flags().GetInt(f.name)
+		}
+	}
+	return config, config.Check()
+}
+
+// AddPeriodicConfigFlags 添加周期性写入参数的命令行参数, 写历史值(isRt为false)时只有缓存队列大小和写入周期有效
+func AddPeriodicConfigFlags(cmd *cobra.Command, isRt bool) {
+	cmd.Flags().String("config", "", "周期性写入参数的YAML配置文件, 键与下列参数名称相同, 命令行中指定的参数优先")
+	cmd.Flags().Int("cache_size", CacheSize, "缓存队列大小")
+	if isRt {
+		cmd.Flags().Int("overload_protection_write_duration", OverloadProtectionWriteDuration, "过载保护持续时间, 单位毫秒, 只有开启过载保护时有效")
+		cmd.Flags().Int("overload_protection_write_periodic", OverloadProtectionWritePeriodic, "过载保护写入周期, 单位毫秒, 只有开启过载保护时有效")
+		cmd.Flags().Int("fast_regular_write_periodic", FastRegularWritePeriodic, "快采点写入周期, 单位毫秒")
+		cmd.Flags().Int("normal_regular_write_periodic", NormalRegularWritePeriodic, "普通点写入周期, 单位毫秒")
+		cmd.Flags().Int("fast_cache_batch_size", FastCacheBatchSize, "开启快采点缓存时, 每次批量写入的断面数量")
+	} else {
+		cmd.Flags().Int("normal_regular_write_periodic", NormalRegularWritePeriodic, "历史点写入周期, 单位毫秒")
+	}
+}
+
+// LogPeriodicConfig 输出周期性写入参数
+func LogPeriodicConfig(config PeriodicConfig) {
+	log.Printf("写入参数 - 缓存队列大小: %v, 过载保护持续时间: %vms, 过载保护写入周期: %vms, 快采点写入周期: %vms, 普通点写入周期: %vms, 快采点缓存批量大小: %v\n",
+		config.CacheSize, config.OverloadProtectionWriteDuration, config.OverloadProtectionWritePeriodic,
+		config.FastRegularWritePeriodic, config.NormalRegularWritePeriodic, config.FastCacheBatchSize,
+	)
+}
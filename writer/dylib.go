@@ -0,0 +1,205 @@
+package main
+
+// #cgo CFLAGS: -I../plugin
+// #include <stdlib.h>
+// #include "dylib.h"
+import "C"
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unsafe"
+)
+
+// DylibWriter C动态库插件
+// 内部调用了 plugin/dylib.h 头文件, 这个头文件封装了C的动态库加载函数
+type DylibWriter struct {
+	handle C.DYLIB_HANDLE
+	info   PluginInfo
+}
+
+// NewDylibWriter 加载插件, 并一次性解析插件的所有接口
+// 插件无法加载或缺少必要接口时返回错误, 错误信息中列出所有缺少的接口
+func NewDylibWriter(path string) (*DylibWriter, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	handle := C.load_library(cPath)
+	if handle.handle == nil {
+		return nil, fmt.Errorf("加载插件失败: %v, %v", path, C.GoString(C.dylib_error()))
+	}
+
+	// 插件信息, 插件未实现 plugin_info 时使用默认值
+	cInfo := C.PluginInfo{}
+	C.dy_plugin_info(handle, &cInfo)
+	info := PluginInfo{
+		ABIVersion:    int(cInfo.abi_version),
+		Vendor:        C.GoString(&cInfo.vendor[0]),
+		MaxBatchSize:  int(cInfo.max_batch_size),
+		Reentrant:     bool(cInfo.reentrant),
+		SupportList:   bool(cInfo.support_list),
+		SupportHis:    bool(cInfo.support_his),
+		SupportStatic: bool(cInfo.support_static),
+	}
+
+	// 必要接口, 插件声明不支持的接口可以不导出
+	missing := make([]string, 0)
+	for _, symbol := range []struct {
+		name     string
+		ok       bool
+		required bool
+	}{
+		{"login", handle.login != nil, true},
+		{"logout", handle.logout != nil, true},
+		{"write_rt_analog", handle.write_rt_analog != nil, true},
+		{"write_rt_digital", handle.write_rt_digital != nil, true},
+		{"write_his_analog", handle.write_his_analog != nil, info.SupportHis},
+		{"write_his_digital", handle.write_his_digital != nil, info.SupportHis},
+		{"write_static_analog", handle.write_static_analog != nil, info.SupportStatic},
+		{"write_static_digital", handle.write_static_digital != nil, info.SupportStatic},
+	} {
+		if symbol.required && !symbol.ok {
+			missing = append(missing, symbol.name)
+		}
+	}
+	if len(missing) != 0 {
+		dlError := C.GoString(C.dylib_error())
+		_ = C.close_library(handle)
+		return nil, fmt.Errorf("插件缺少必要接口: %v, 插件路径: %v, 错误信息: %v", strings.Join(missing, ", "), path, dlError)
+	}
+
+	// 可选接口
+	if info.ABIVersion < 2 {
+		log.Println("插件未实现 abi_version, 按ABI版本1处理, 写入接口的返回值将被忽略")
+	}
+	if info.SupportList && handle.write_rt_analog_list == nil {
+		log.Println("插件未实现 write_rt_analog_list, 使用 write_rt_analog 逐个断面写入")
+	}
+	if info.SupportList && handle.write_rt_digital_list == nil {
+		log.Println("插件未实现 write_rt_digital_list, 使用 write_rt_digital 逐个断面写入")
+	}
+
+	return &DylibWriter{handle: handle, info: info}, nil
+}
+
+func (w *DylibWriter) Info() PluginInfo {
+	return w.info
+}
+
+func (w *DylibWriter) Login(param string) int {
+	if param == "" {
+		return int(C.dy_login(w.handle, nil))
+	} else {
+		cParam := C.CString(param)
+		defer C.free(unsafe.Pointer(cParam))
+		return int(C.dy_login(w.handle, cParam))
+	}
+}
+
+func (w *DylibWriter) Logout() {
+	C.dy_logout(w.handle)
+}
+
+func (w *DylibWriter) WriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_rt_analog(w.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Analog)(&section.Data[0]), C.int64_t(len(section.Data)), C.bool(isFast), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_rt_analog", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_rt_digital(w.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Digital)(&section.Data[0]), C.int64_t(len(section.Data)), C.bool(isFast), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_rt_digital", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteRtAnalogList(magic int32, unitId int64, sections []AnalogSection) error {
+	// 初始化 C 数组
+	timeList := make([]C.int64_t, 0)
+	analogArrayList := make([]*C.Analog, 0)
+	countList := make([]C.int64_t, 0)
+
+	for i := range sections {
+		timeList = append(timeList, C.int64_t(sections[i].Time))
+
+		// 分配 C 内存并将 Go 数据复制到 C 内存中
+		analogData := C.malloc(C.size_t(len(sections[i].Data)) * C.size_t(unsafe.Sizeof(C.Analog{})))
+		if analogData == nil {
+			panic("C.malloc failed")
+		}
+		for j := range sections[i].Data {
+			(*[1 << 30]C.Analog)(analogData)[j] = C.Analog(sections[i].Data[j])
+		}
+		analogArrayList = append(analogArrayList, (*C.Analog)(analogData))
+		countList = append(countList, C.int64_t(len(sections[i].Data)))
+	}
+
+	// 调用 C 函数，传递结构体指针数组
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_rt_analog_list(w.handle, C.int32_t(magic), C.int64_t(unitId), &timeList[0], &analogArrayList[0], &countList[0], C.int64_t(len(sections)), &errBuf[0], C.int(len(errBuf)))
+
+	// 释放 C 分配的内存
+	for i := range analogArrayList {
+		if analogArrayList[i] != nil {
+			C.free(unsafe.Pointer(analogArrayList[i]))
+		}
+	}
+	return NewWriteError("write_rt_analog_list", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteRtDigitalList(magic int32, unitId int64, sections []DigitalSection) error {
+	// 初始化 C 数组
+	timeList := make([]C.int64_t, 0)
+	digitalArrayList := make([]*C.Digital, 0)
+	countList := make([]C.int64_t, 0)
+
+	for i := range sections {
+		timeList = append(timeList, C.int64_t(sections[i].Time))
+
+		// 分配 C 内存并将 Go 数据复制到 C 内存中
+		digitalData := C.malloc(C.size_t(len(sections[i].Data)) * C.size_t(unsafe.Sizeof(C.Digital{})))
+		if digitalData == nil {
+			panic("C.malloc failed")
+		}
+		for j := range sections[i].Data {
+			(*[1 << 30]C.Digital)(digitalData)[j] = C.Digital(sections[i].Data[j])
+		}
+		digitalArrayList = append(digitalArrayList, (*C.Digital)(digitalData))
+		countList = append(countList, C.int64_t(len(sections[i].Data)))
+	}
+
+	// 调用 C 函数，传递结构体指针数组
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_rt_digital_list(w.handle, C.int32_t(magic), C.int64_t(unitId), &timeList[0], &digitalArrayList[0], &countList[0], C.int64_t(len(sections)), &errBuf[0], C.int(len(errBuf)))
+
+	// 释放 C 分配的内存
+	for i := range digitalArrayList {
+		if digitalArrayList[i] != nil {
+			C.free(unsafe.Pointer(digitalArrayList[i]))
+		}
+	}
+	return NewWriteError("write_rt_digital_list", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteHisAnalog(magic int32, unitId int64, section AnalogSection) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_his_analog(w.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Analog)(&section.Data[0]), C.int64_t(len(section.Data)), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_his_analog", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteHisDigital(magic int32, unitId int64, section DigitalSection) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_his_digital(w.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Digital)(&section.Data[0]), C.int64_t(len(section.Data)), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_his_digital", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_static_analog(w.handle, C.int32_t(magic), C.int64_t(unitId), (*C.StaticAnalog)(&section.Data[0]), C.int64_t(len(section.Data)), C.int64_t(typ), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_static_analog", unitId, code, errBuf)
+}
+
+func (w *DylibWriter) WriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error {
+	errBuf := make([]C.char, ErrorMessageSize)
+	code := C.dy_write_static_digital(w.handle, C.int32_t(magic), C.int64_t(unitId), (*C.StaticDigital)(&section.Data[0]), C.int64_t(len(section.Data)), C.int64_t(typ), &errBuf[0], C.int(len(errBuf)))
+	return NewWriteError("write_static_digital", unitId, code, errBuf)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// GenStartTime 生成数据集的默认起始时间, 与CSV20240614示例文件一致
+const GenStartTime = 1718350759143
+
+// GenDataset 生成的一组数据文件, 包括动态模拟量, 动态数字量以及对应的静态模拟量和静态数字量
+type GenDataset struct {
+	Name   string        // 文件名中的数据类型, 如REALTIME_FAST, 生成<起始时间>_REALTIME_FAST_ANALOG.csv和<起始时间>_REALTIME_FAST_STATIC_ANALOG.csv
+	Flag   string        // 命令行参数前缀, 与写入命令的CSV参数一致, 如rt_fast对应--rt_fast_analog_number和--rt_fast_period
+	Period time.Duration // 默认采样周期
+}
+
+// GenDatasets 支持生成的数据文件, 采样周期默认与周期性写入的写入周期一致
+var GenDatasets = []GenDataset{
+	{Name: "REALTIME_FAST", Flag: "rt_fast", Period: FastRegularWritePeriodic * time.Millisecond},
+	{Name: "REALTIME_NORMAL", Flag: "rt_normal", Period: NormalRegularWritePeriodic * time.Millisecond},
+	{Name: "HISTORY_NORMAL", Flag: "his_normal", Period: time.Second},
+}
+
+// GenStringFields 静态文件中的随机字符串字段, 默认长度为插件接口中的缓冲区大小减1, 留出结尾的NUL
+var GenStringFields = []struct {
+	Name   string
+	Length int
+}{
+	{"chn", 31},
+	{"pn", 31},
+	{"desc", 127},
+	{"unit", 31},
+}
+
+// GenOptions 数据集的生成参数
+type GenOptions struct {
+	Output     string          // 输出目录
+	Start      int64           // 第一个断面的时间戳, 单位毫秒
+	Duration   time.Duration   // 数据集的时间跨度
+	UnitNumber int64           // 机组数量, 只有PerUnit时有效
+	PerUnit    bool            // 为每个机组生成各自的动态文件, 与写入命令的 --per_unit_csv 对应
+	Seed       int64           // 随机数种子
+	Periods    []time.Duration // 采样周期, 顺序与GenDatasets一致
+	Analogs    []int           // 模拟量的点数量, 顺序与GenDatasets一致
+	Digitals   []int           // 数字量的点数量, 顺序与GenDatasets一致
+	Lengths    []int           // 随机字符串字段的长度, 顺序与GenStringFields一致
+	transform  *ValueTransform
+}
+
+// genFile 一个要生成的文件
+type genFile struct {
+	path  string
+	write func(w *bufio.Writer) (int64, error) // 写入文件内容, 返回数据行数
+}
+
+// AddGenFlags 添加生成数据集的命令行参数
+func AddGenFlags(cmd *cobra.Command) {
+	cmd.Flags().String("output", ".", "输出目录, 不存在时自动创建")
+	cmd.Flags().Int64("start_time", GenStartTime, "第一个断面的时间戳, 单位毫秒")
+	cmd.Flags().Duration("duration", 10*time.Second, "数据集的时间跨度, 断面数量为时间跨度除以采样周期")
+	for _, dataset := range GenDatasets {
+		cmd.Flags().Int(dataset.Flag+"_analog_number", 100, strings.ToLower(dataset.Name)+"模拟量的点数量, 为0时不生成该文件")
+		cmd.Flags().Int(dataset.Flag+"_digital_number", 100, strings.ToLower(dataset.Name)+"数字量的点数量, 为0时不生成该文件")
+		cmd.Flags().Duration(dataset.Flag+"_period", dataset.Period, strings.ToLower(dataset.Name)+"的采样周期, 最小为1ms")
+	}
+	cmd.Flags().String("value_model", "noise:1,flip:0.01", "生成数据的数值模型, 多个模型用逗号分隔并按顺序叠加, 为空时每个点保持初始值, 支持: "+valueModelNames())
+	cmd.Flags().Int64("value_seed", 0, "随机数种子, 相同的种子和参数生成相同的数据集")
+	for _, field := range GenStringFields {
+		cmd.Flags().Int(field.Name+"_length", field.Length, "静态文件中"+strings.ToUpper(field.Name)+"字段的随机字符串长度")
+	}
+	cmd.Flags().Int64("unit_number", 1, "机组数量, 只有--per_unit_csv时有效")
+	cmd.Flags().Bool("per_unit_csv", false, "为true时为每个机组生成各自的动态文件, 文件名加上unit_<机组编号>_前缀, 静态文件所有机组共用")
+}
+
+// NewGenOptions 读取并检查生成参数
+func NewGenOptions(cmd *cobra.Command) (GenOptions, error) {
+	options := GenOptions{}
+	options.Output, _ = cmd.Flags().GetString("output")
+	options.Start, _ = cmd.Flags().GetInt64("start_time")
+	options.Duration, _ = cmd.Flags().GetDuration("duration")
+	options.UnitNumber, _ = cmd.Flags().GetInt64("unit_number")
+	options.PerUnit, _ = cmd.Flags().GetBool("per_unit_csv")
+	options.Seed, _ = cmd.Flags().GetInt64("value_seed")
+	if options.Duration <= 0 {
+		return options, fmt.Errorf("--duration必须大于0")
+	}
+	if options.UnitNumber <= 0 {
+		return options, fmt.Errorf("--unit_number必须大于0")
+	}
+	if !options.PerUnit {
+		options.UnitNumber = 1
+	}
+	for _, dataset := range GenDatasets {
+		period, _ := cmd.Flags().GetDuration(dataset.Flag + "_period")
+		if period < time.Millisecond || period%time.Millisecond != 0 {
+			return options, fmt.Errorf("--%v_period必须是1ms的整数倍: %v", dataset.Flag, period)
+		}
+		options.Periods = append(options.Periods, period)
+		for _, kind := range []string{"analog", "digital"} {
+			n, _ := cmd.Flags().GetInt(dataset.Flag + "_" + kind + "_number")
+			if n < 0 || n > 0x1FFFFF {
+				return options, fmt.Errorf("--%v_%v_number取值范围[0, %v], P_NUM超出21位会被GlobalID截断", dataset.Flag, kind, 0x1FFFFF)
+			}
+			if kind == "analog" {
+				options.Analogs = append(options.Analogs, n)
+			} else {
+				options.Digitals = append(options.Digitals, n)
+			}
+		}
+	}
+	for i, field := range GenStringFields {
+		n, _ := cmd.Flags().GetInt(field.Name + "_length")
+		if limit := staticStringLimits[i].Limit; n < 0 || n >= limit {
+			return options, fmt.Errorf("--%v_length取值范围[0, %v], 字符串需要以NUL结尾", field.Name, limit-1)
+		}
+		options.Lengths = append(options.Lengths, n)
+	}
+
+	spec, _ := cmd.Flags().GetString("value_model")
+	options.transform = &ValueTransform{Seed: options.Seed}
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			options.transform.Specs = append(options.transform.Specs, s)
+		}
+	}
+	if err := options.transform.parse(); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// Generate 生成数据集, 每个文件由各自的协程生成, 返回第一个错误
+func Generate(options GenOptions) error {
+	if err := os.MkdirAll(options.Output, 0755); err != nil {
+		return err
+	}
+
+	files := make([]genFile, 0)
+	for i, dataset := range GenDatasets {
+		period := options.Periods[i]
+		sections := int64(options.Duration / period)
+		if sections == 0 {
+			sections = 1
+		}
+		for _, isAnalog := range []bool{true, false} {
+			kind, n := "DIGITAL", options.Digitals[i]
+			if isAnalog {
+				kind, n = "ANALOG", options.Analogs[i]
+			}
+			if n == 0 {
+				continue
+			}
+			// 每个文件的随机数互不相同: 由数据类型, 模拟量/数字量和机组区分
+			id := int64(i) << 1
+			if !isAnalog {
+				id |= 1
+			}
+
+			path := filepath.Join(options.Output, fmt.Sprintf("%v_%v_STATIC_%v.csv", options.Start, dataset.Name, kind))
+			files = append(files, genFile{path: path, write: options.staticWriter(id, n, isAnalog)})
+
+			path = filepath.Join(options.Output, fmt.Sprintf("%v_%v_%v.csv", options.Start, dataset.Name, kind))
+			paths := []string{path}
+			if options.PerUnit {
+				paths = CsvOptions{PerUnit: true, UnitNumber: options.UnitNumber}.csvPaths(path)
+			}
+			for unitId, unitPath := range paths {
+				files = append(files, genFile{path: unitPath, write: options.dynamicWriter(int64(unitId), id, n, isAnalog, period, sections)})
+			}
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("所有点数量都为0, 没有需要生成的文件")
+	}
+
+	log.Printf("生成数据集 - 输出目录: %v, 起始时间: %v, 时间跨度: %v, 数值模型: %v, 随机数种子: %v, 按机组生成: %v\n",
+		options.Output, options.Start, options.Duration, strings.Join(options.transform.Specs, ","), options.Seed, options.PerUnit)
+	start := time.Now()
+	wg := new(sync.WaitGroup)
+	errs := make([]error, len(files))
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = files[i].generate()
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	log.Printf("生成数据集完成, 文件数量: %v, 耗时: %v\n", len(files), time.Since(start))
+	return nil
+}
+
+// generate 生成文件, 成功后输出文件的行数和大小
+func (f genFile) generate() error {
+	start := time.Now()
+	file, err := os.Create(f.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	w := bufio.NewWriterSize(file, 1<<20)
+	rows, err := f.write(w)
+	if err != nil {
+		return fmt.Errorf("生成文件失败: %v, %v", f.path, err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("生成文件失败: %v, %v", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("生成文件失败: %v, %v", f.path, err)
+	}
+	log.Printf("生成文件: %v, 行数: %v, 大小: %v字节, 耗时: %v\n", f.path, rows, info.Size(), time.Since(start))
+	return nil
+}
+
+// rand 文件id的随机数生成器, 机组unitId使用不同的随机数
+func (options GenOptions) rand(unitId int64, id int64) *rand.Rand {
+	return rand.New(rand.NewSource(int64(splitmix64(uint64(options.Seed) ^ uint64(unitId)<<32 ^ uint64(id)))))
+}
+
+// staticWriter 静态文件, 列与 ParseStaticAnalogRecord, ParseStaticDigitalRecord 一致
+func (options GenOptions) staticWriter(id int64, n int, isAnalog bool) func(w *bufio.Writer) (int64, error) {
+	return func(w *bufio.Writer) (int64, error) {
+		r := options.rand(0, id)
+		if isAnalog {
+			_, _ = w.WriteString("P_NUM,TAGT,FACK,L4AR,L3AR,L2AR,L1AR,H4AR,H3AR,H2AR,H1AR,CHN,PN,DESC,UNIT,MU,MD\n")
+		} else {
+			_, _ = w.WriteString("P_NUM,FACK,CHN,PN,DESC,UNIT\n")
+		}
+		buf := make([]byte, 0, 512)
+		for pNum := 1; pNum <= n; pNum++ {
+			buf = strconv.AppendInt(buf[:0], int64(pNum), 10)
+			if isAnalog {
+				buf = append(buf, ',')
+				buf = strconv.AppendInt(buf, int64(r.Intn(1<<16)), 10)
+			}
+			buf = append(buf, ',')
+			buf = strconv.AppendInt(buf, int64(r.Intn(1<<16)), 10)
+			if isAnalog {
+				buf = append(buf, ",False,False,False,False,False,False,False,False"...)
+			}
+			for _, length := range options.Lengths {
+				buf = append(buf, ',')
+				for i := 0; i < length; i++ {
+					buf = append(buf, byte('a'+r.Intn(26)))
+				}
+			}
+			if isAnalog {
+				buf = append(buf, ",999,0"...)
+			}
+			buf = append(buf, '\n')
+			if _, err := w.Write(buf); err != nil {
+				return int64(pNum - 1), err
+			}
+		}
+		return int64(n), nil
+	}
+}
+
+// dynamicWriter 动态文件, 列与 ParseAnalogRecord, ParseDigitalRecord 一致
+// 每个点的初始值随机生成, 之后每个断面对初始值施加数值模型, 与写入时的 ValueTransform 相同:
+// 模拟量为初始值加上各模型的变化量, 数字量在模型要求翻转时取初始值的反, 各断面之间互不影响
+func (options GenOptions) dynamicWriter(unitId int64, id int64, n int, isAnalog bool, period time.Duration, sections int64) func(w *bufio.Writer) (int64, error) {
+	return func(w *bufio.Writer) (int64, error) {
+		r := options.rand(unitId, id)
+		t := options.transform
+		avs := make([]float64, n)
+		avrs := make([]float64, n)
+		dvs := make([]bool, n)
+		for i := 0; i < n; i++ {
+			avs[i] = r.Float64() * 100
+			avrs[i] = r.Float64()
+			dvs[i] = r.Intn(2) == 1
+		}
+		if isAnalog {
+			_, _ = w.WriteString("TIME,P_NUM,AV,AVR,Q,BF,FQ,FAI,MS,TEW,CST\n")
+		} else {
+			_, _ = w.WriteString("TIME,P_NUM,DV,DVR,Q,BF,FQ,FAI,MS,TEW,CST\n")
+		}
+		buf := make([]byte, 0, 128)
+		rows := int64(0)
+		step := period.Milliseconds()
+		for k := int64(0); k < sections; k++ {
+			ts := options.Start + k*step
+			ctx := &ValueContext{UnitId: unitId, Time: ts, Origin: options.Start}
+			// 与写入时的数值模型一样由种子, 模型序号, 点和时间决定
+			for i := 0; i < n; i++ {
+				pointId := unitId<<32 | id<<24 | int64(i+1)
+				buf = strconv.AppendInt(buf[:0], ts, 10)
+				buf = append(buf, ',')
+				buf = strconv.AppendInt(buf, int64(i+1), 10)
+				if isAnalog {
+					buf = append(buf, ',')
+					buf = strconv.AppendFloat(buf, avs[i]+t.analogDelta(ctx, pointId), 'f', 3, 32)
+					buf = append(buf, ',')
+					buf = strconv.AppendFloat(buf, avrs[i], 'f', 3, 32)
+					buf = append(buf, ",False,False,False,0.0,False,A,0\n"...)
+				} else {
+					buf = append(buf, ',')
+					buf = append(buf, pythonBool(dvs[i] != t.digitalFlip(ctx, pointId))...)
+					buf = append(buf, ",False,False,False,False,False,False,A,0\n"...)
+				}
+				if _, err := w.Write(buf); err != nil {
+					return rows, err
+				}
+				rows++
+			}
+		}
+		return rows, nil
+	}
+}
+
+// pythonBool 与示例CSV一致的布尔值格式
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
@@ -4,10 +4,9 @@ go 1.21.4
 
 require (
 	github.com/spf13/cobra v1.8.1
-	gonum.org/v1/gonum v0.15.0
+	github.com/spf13/pflag v1.0.5
+	google.golang.org/protobuf v1.36.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
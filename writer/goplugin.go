@@ -0,0 +1,44 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"fmt"
+	"plugin"
+	"runtime"
+	"writer/sdk"
+)
+
+// GoPluginScheme Go插件路径前缀, 如: --plugin=goplugin://./libgowrite_plugin.so
+const GoPluginScheme = "goplugin://"
+
+// GoPluginSymbol Go插件导出的构造函数名称, 类型必须为 func() sdk.Writer
+const GoPluginSymbol = "NewWriter"
+
+// NewGoPluginWriter 加载使用 go build -buildmode=plugin 编译的Go插件
+// 备注: Go插件必须与写入程序使用相同版本的Go编译, 并且依赖相同版本的 writer/sdk
+func NewGoPluginWriter(path string) (*SdkWriter, error) {
+	// 打开非Go编译的动态库会导致进程直接崩溃, 加载前先检查
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("加载Go插件失败: %v 不是Go插件, %v", path, err)
+	}
+	if info.GoVersion != runtime.Version() {
+		return nil, fmt.Errorf("加载Go插件失败: %v 使用 %v 编译, 写入程序使用 %v 编译", path, info.GoVersion, runtime.Version())
+	}
+
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("加载Go插件失败: %v, %v", path, err)
+	}
+	symbol, err := p.Lookup(GoPluginSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("Go插件缺少必要接口: %v, 插件路径: %v, 错误信息: %v", GoPluginSymbol, path, err)
+	}
+	newWriter, ok := symbol.(func() sdk.Writer)
+	if !ok {
+		return nil, fmt.Errorf("Go插件接口类型错误: %v 的类型为 %T, 应为 func() sdk.Writer", GoPluginSymbol, symbol)
+	}
+
+	writer := newWriter()
+	return NewSdkWriter(writer, sdk.InfoOf(writer)), nil
+}
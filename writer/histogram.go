@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// HistogramMaxValue 直方图可以精确统计的最大耗时, 超出的耗时按该值统计(最长耗时仍然准确)
+const HistogramMaxValue = time.Hour
+
+// DefaultHistogramPrecision 直方图默认的有效数字位数, 3表示误差不超过0.1%
+const DefaultHistogramPrecision = 3
+
+// Histogram HDR风格的耗时直方图, 单位纳秒
+// 按2的幂分桶, 每个桶内再线性划分为固定数量的子桶, 保证任意耗时的相对误差不超过 10^-precision
+// 内存占用只与精度有关, 与记录的数量无关; 平均值, 最短和最长耗时是精确值
+type Histogram struct {
+	precision     int
+	subBucketBits int // 子桶数量为 2^subBucketBits
+	halfCount     int // 子桶数量的一半
+	counts        []int64
+	totalCount    int64
+	sum           int64
+	min           int64
+	max           int64
+}
+
+// NewHistogram 创建直方图, precision为有效数字位数, 取值范围[1,4]
+// 精度为3时每个直方图约占用270KB内存, 精度每增加1内存约增加到原来的14倍
+func NewHistogram(precision int) (*Histogram, error) {
+	if precision < 1 || precision > 4 {
+		return nil, fmt.Errorf("直方图精度必须在1到4之间: %v", precision)
+	}
+	// 子桶数量至少为 2*10^precision, 保证相对误差不超过 10^-precision
+	subBucketBits := bits.Len64(uint64(2*math.Pow10(precision)) - 1)
+	h := &Histogram{
+		precision:     precision,
+		subBucketBits: subBucketBits,
+		halfCount:     1 << (subBucketBits - 1),
+		min:           math.MaxInt64,
+	}
+	h.counts = make([]int64, h.index(int64(HistogramMaxValue))+1)
+	return h, nil
+}
+
+// index 耗时对应的子桶下标
+// 小于子桶数量的耗时直接作为下标, 否则保留最高的subBucketBits位, 第shift个桶的下标从 (shift+1)*halfCount 开始
+func (h *Histogram) index(v int64) int {
+	if v < int64(2*h.halfCount) {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - h.subBucketBits
+	return shift*h.halfCount + int(v>>shift)
+}
+
+// highestEquivalentValue 子桶中的最大耗时
+func (h *Histogram) highestEquivalentValue(index int) int64 {
+	if index < 2*h.halfCount {
+		return int64(index)
+	}
+	shift := index/h.halfCount - 1
+	sub := int64(index - shift*h.halfCount)
+	return (sub+1)<<shift - 1
+}
+
+// Record 记录一次耗时
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+	h.sum += v
+	h.totalCount++
+	if v > int64(HistogramMaxValue) {
+		v = int64(HistogramMaxValue)
+	}
+	h.counts[h.index(v)]++
+}
+
+// Merge 将other中的记录合并到h, 两者精度必须相同
+func (h *Histogram) Merge(other *Histogram) {
+	if other.totalCount == 0 {
+		return
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.totalCount += other.totalCount
+	h.sum += other.sum
+	if other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Count 记录的数量
+func (h *Histogram) Count() int64 {
+	return h.totalCount
+}
+
+// Sum 所有耗时之和
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(h.sum)
+}
+
+// Min 最短耗时, 没有记录时为0
+func (h *Histogram) Min() time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+// Max 最长耗时
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max)
+}
+
+// Quantile 分位数, q取值范围[0,1], 与 stat.Quantile 的 stat.Empirical 相同, 取第 ceil(q*n) 个耗时
+// 返回所在子桶的最大耗时, 并限制在[最短耗时, 最长耗时]之间
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.totalCount == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.totalCount)))
+	if rank < 1 {
+		return time.Duration(h.min)
+	}
+	if rank >= h.totalCount {
+		return time.Duration(h.max)
+	}
+	cumulative := int64(0)
+	for i, c := range h.counts {
+		cumulative += c
+		if cumulative >= rank {
+			v := h.highestEquivalentValue(i)
+			if v < h.min {
+				v = h.min
+			}
+			if v > h.max {
+				v = h.max
+			}
+			return time.Duration(v)
+		}
+	}
+	return time.Duration(h.max)
+}
+
+// lowestEquivalentValue 子桶中的最小耗时
+func (h *Histogram) lowestEquivalentValue(index int) int64 {
+	if index < 2*h.halfCount {
+		return int64(index)
+	}
+	shift := index/h.halfCount - 1
+	sub := int64(index - shift*h.halfCount)
+	return sub << shift
+}
+
+// Sub 返回h比other多出的记录, other必须是h之前的快照, 用于统计一段时间内的耗时
+// 最短和最长耗时取所在子桶的边界, 为近似值
+func (h *Histogram) Sub(other *Histogram) *Histogram {
+	d := &Histogram{
+		precision:     h.precision,
+		subBucketBits: h.subBucketBits,
+		halfCount:     h.halfCount,
+		counts:        make([]int64, len(h.counts)),
+		sum:           h.sum - other.sum,
+		min:           math.MaxInt64,
+	}
+	for i := range h.counts {
+		c := h.counts[i] - other.counts[i]
+		if c <= 0 {
+			continue
+		}
+		if d.totalCount == 0 {
+			d.min = h.lowestEquivalentValue(i)
+		}
+		d.max = h.highestEquivalentValue(i)
+		d.counts[i] = c
+		d.totalCount += c
+	}
+	if d.totalCount == 0 {
+		return d
+	}
+	if d.min < h.min {
+		d.min = h.min
+	}
+	if d.max > h.max {
+		d.max = h.max
+	}
+	return d
+}
+
+// CumulativeCounts 耗时小于等于每个上限的记录数量, bounds必须递增, 用于输出Prometheus直方图
+// 与上限在同一子桶的耗时也计入该上限, 误差与精度相同
+func (h *Histogram) CumulativeCounts(bounds []time.Duration) []int64 {
+	rtn := make([]int64, len(bounds))
+	cumulative := int64(0)
+	i := 0
+	for j, bound := range bounds {
+		v := int64(bound)
+		if v > int64(HistogramMaxValue) {
+			v = int64(HistogramMaxValue)
+		}
+		for last := h.index(v); i <= last; i++ {
+			cumulative += h.counts[i]
+		}
+		rtn[j] = cumulative
+	}
+	return rtn
+}
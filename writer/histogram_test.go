@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestHistogram 记录values的直方图
+func newTestHistogram(t *testing.T, precision int, values ...time.Duration) *Histogram {
+	t.Helper()
+	h, err := NewHistogram(precision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		h.Record(v)
+	}
+	return h
+}
+
+// durations 从from到to, 间隔为step的耗时
+func durations(from time.Duration, to time.Duration, step time.Duration) []time.Duration {
+	rtn := make([]time.Duration, 0)
+	for d := from; d <= to; d += step {
+		rtn = append(rtn, d)
+	}
+	return rtn
+}
+
+func TestNewHistogramPrecision(t *testing.T) {
+	for _, tt := range []struct {
+		precision int
+		ok        bool
+	}{{0, false}, {1, true}, {3, true}, {4, true}, {5, false}} {
+		if _, err := NewHistogram(tt.precision); (err == nil) != tt.ok {
+			t.Errorf("精度%v: err=%v", tt.precision, err)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []time.Duration
+		q      float64
+		want   time.Duration
+		error  float64 // 允许的相对误差
+	}{
+		{"空", nil, 0.5, 0, 0},
+		{"q=0取最短", durations(1, 100, 1), 0, 1, 0},
+		{"中位数", durations(1, 100, 1), 0.5, 50, 0},
+		{"P99", durations(1, 100, 1), 0.99, 99, 0},
+		{"q=1取最长", durations(1, 100, 1), 1, 100, 0},
+		{"单个值", []time.Duration{time.Millisecond}, 0.5, time.Millisecond, 0},
+		{"负数按0", []time.Duration{-time.Second, 10}, 0.5, 0, 0},
+		{"大耗时中位数", durations(time.Microsecond, time.Millisecond, time.Microsecond), 0.5, 500 * time.Microsecond, 1e-3},
+		{"大耗时P999", durations(time.Microsecond, time.Millisecond, time.Microsecond), 0.999, 999 * time.Microsecond, 1e-3},
+		{"超出上限", []time.Duration{time.Second, 2 * time.Hour}, 0.5, time.Second, 1e-3},
+		{"超出上限取最长", []time.Duration{time.Second, 2 * time.Hour}, 1, 2 * time.Hour, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHistogram(t, DefaultHistogramPrecision, tt.values...)
+			got := h.Quantile(tt.q)
+			diff := float64(got - tt.want)
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > tt.error*float64(tt.want) {
+				t.Fatalf("Quantile(%v)=%v, 应为%v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []time.Duration
+	}{
+		{"交错", durations(1, 999, 2), durations(2, 1000, 2)},
+		{"不同量级", durations(time.Microsecond, time.Millisecond, time.Microsecond), durations(time.Second, 10*time.Second, time.Second)},
+		{"合并空直方图", durations(5, 50, 5), nil},
+		{"合并到空直方图", nil, durations(5, 50, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := newTestHistogram(t, DefaultHistogramPrecision, tt.a...)
+			merged.Merge(newTestHistogram(t, DefaultHistogramPrecision, tt.b...))
+			all := newTestHistogram(t, DefaultHistogramPrecision, append(append([]time.Duration(nil), tt.a...), tt.b...)...)
+
+			if merged.Count() != all.Count() || merged.Sum() != all.Sum() || merged.Min() != all.Min() || merged.Max() != all.Max() {
+				t.Fatalf("合并结果不一致: count=%v/%v, sum=%v/%v, min=%v/%v, max=%v/%v",
+					merged.Count(), all.Count(), merged.Sum(), all.Sum(), merged.Min(), all.Min(), merged.Max(), all.Max())
+			}
+			for _, q := range []float64{0, 0.5, 0.9, 0.99, 0.999, 1} {
+				if got, want := merged.Quantile(q), all.Quantile(q); got != want {
+					t.Errorf("Quantile(%v)=%v, 应为%v", q, got, want)
+				}
+			}
+		})
+	}
+}
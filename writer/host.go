@@ -0,0 +1,444 @@
+package main
+
+// #cgo CFLAGS: -I../plugin
+// #include "write_plugin.h"
+import "C"
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"writer/sdk"
+)
+
+// HostPluginScheme 插件宿主进程路径前缀, 如: --plugin="host://./libcwrite_plugin.so?host_restart=3"
+const HostPluginScheme = "host://"
+
+// HostStartTimeout 等待插件宿主进程加载插件并监听socket的超时时间
+const HostStartTimeout = 10 * time.Second
+
+// HostStopTimeout 登出后等待插件宿主进程退出的超时时间, 超时后强制结束
+const HostStopTimeout = 5 * time.Second
+
+// HostWriter 插件宿主进程
+// 写入程序启动一个 plugin_host 子进程加载插件, 通过Unix domain socket交换数据, 协议参考 writer/sdk/protocol.go
+// 插件崩溃只会导致宿主进程退出, 写入程序记录故障事件, 按配置重启宿主进程, 并保留已经统计的写入信息
+type HostWriter struct {
+	pluginPath string
+	socketPath string
+	maxRestart int
+
+	lock     *sync.RWMutex
+	conn     *hostConn // 当前的宿主进程, 宿主进程退出且不再重启时为nil
+	info     PluginInfo
+	param    string
+	loggedIn bool
+	restarts int
+	closing  bool // 为true表示正在登出, 宿主进程退出不视为故障
+}
+
+// hostConn 一个宿主进程及其连接
+type hostConn struct {
+	cmd    *exec.Cmd
+	conn   net.Conn
+	writer *SdkWriter
+	exited chan struct{} // 宿主进程退出后关闭
+	err    error         // 宿主进程的退出原因, exited关闭后有效
+}
+
+// HostParamPrefix 插件宿主进程参数的前缀, 其他参数原样传给宿主进程中加载的插件
+const HostParamPrefix = "host_"
+
+// NewHostWriter 启动插件宿主进程
+// 路径格式: 插件路径?host_restart=0&host_socket=/tmp/rtdb_writer_<pid>.sock
+// * host_restart: 宿主进程异常退出后最多重启的次数, 默认为0(不重启, 直接平滑退出并输出统计信息)
+// * host_socket: Unix domain socket路径
+// 不带 host_ 前缀的参数属于插件, 如 host://mock://?latency=fixed:1ms&host_restart=1 在宿主进程中加载 mock://?latency=fixed:1ms
+func NewHostWriter(path string) (*HostWriter, error) {
+	pluginPath, query, err := SplitHostParams(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(pluginPath, HostPluginScheme) {
+		return nil, fmt.Errorf("插件宿主进程不能嵌套: %v", path)
+	}
+
+	w := &HostWriter{
+		pluginPath: pluginPath,
+		socketPath: query.Get("host_socket"),
+		lock:       new(sync.RWMutex),
+	}
+	if w.socketPath == "" {
+		w.socketPath = filepath.Join(os.TempDir(), fmt.Sprintf("rtdb_writer_%v.sock", os.Getpid()))
+	}
+	if restart := query.Get("host_restart"); restart != "" {
+		if w.maxRestart, err = strconv.Atoi(restart); err != nil || w.maxRestart < 0 {
+			return nil, fmt.Errorf("插件宿主进程host_restart参数错误: %v", restart)
+		}
+	}
+
+	conn, err := w.start()
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+	w.info = conn.writer.Info()
+	go w.monitor(conn)
+	return w, nil
+}
+
+// SplitHostParams 从路径中分离插件宿主进程的参数, 返回去掉这些参数后的插件路径
+// 插件的参数保留原始写法, 未知的 host_ 参数返回错误
+func SplitHostParams(path string) (string, url.Values, error) {
+	pluginPath, rawQuery, ok := strings.Cut(path, "?")
+	query := make(url.Values)
+	if !ok {
+		return pluginPath, query, nil
+	}
+	pluginParams := make([]string, 0)
+	for _, param := range strings.Split(rawQuery, "&") {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.HasPrefix(key, HostParamPrefix) {
+			if param != "" {
+				pluginParams = append(pluginParams, param)
+			}
+			continue
+		}
+		if key != "host_restart" && key != "host_socket" {
+			return "", nil, fmt.Errorf("插件宿主进程参数未知: %v, 可选参数: host_restart, host_socket", key)
+		}
+		v, err := url.QueryUnescape(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("插件宿主进程参数解析失败: %v, %v", param, err)
+		}
+		query.Set(key, v)
+	}
+	if len(pluginParams) > 0 {
+		pluginPath += "?" + strings.Join(pluginParams, "&")
+	}
+	return pluginPath, query, nil
+}
+
+// start 启动宿主进程, 等待宿主进程监听socket后建立连接并查询插件信息
+func (w *HostWriter) start() (*hostConn, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("插件宿主进程启动失败: %v", err)
+	}
+	_ = os.Remove(w.socketPath)
+
+	cmd := exec.Command(exe, "plugin_host", "--plugin="+w.pluginPath, "--socket="+w.socketPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("插件宿主进程启动失败: %v", err)
+	}
+	hc := &hostConn{cmd: cmd, exited: make(chan struct{})}
+	go func() {
+		hc.err = cmd.Wait()
+		close(hc.exited)
+	}()
+	kill := func() {
+		_ = cmd.Process.Kill()
+		<-hc.exited
+	}
+
+	deadline := time.Now().Add(HostStartTimeout)
+	for hc.conn == nil {
+		conn, err := net.Dial("unix", w.socketPath)
+		if err == nil {
+			hc.conn = conn
+			break
+		}
+		if time.Now().After(deadline) {
+			kill()
+			return nil, fmt.Errorf("插件宿主进程启动超时: %v", err)
+		}
+		select {
+		case <-hc.exited:
+			return nil, fmt.Errorf("插件宿主进程启动失败: %v", hc.err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	client := sdk.NewClient(hc.conn, hc.conn)
+	info, err := client.QueryInfo()
+	if err != nil {
+		_ = hc.conn.Close()
+		kill()
+		return nil, fmt.Errorf("插件宿主进程查询插件信息失败: %v", err)
+	}
+	hc.writer = NewSdkWriter(client, info)
+	return hc, nil
+}
+
+// monitor 等待宿主进程退出, 非登出导致的退出记录为故障事件, 并按配置重启宿主进程
+// 重启失败或重启后重新登录失败时请求平滑退出
+func (w *HostWriter) monitor(hc *hostConn) {
+	<-hc.exited
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closing || w.conn != hc {
+		return
+	}
+	_ = hc.conn.Close()
+	RecordFaultEvent("插件宿主进程退出", fmt.Sprintf("pid: %v, %v", hc.cmd.Process.Pid, hc.err))
+
+	if w.restarts >= w.maxRestart {
+		w.conn = nil
+		RequestStop("插件宿主进程退出")
+		return
+	}
+	w.restarts++
+	conn, err := w.start()
+	if err != nil {
+		w.conn = nil
+		RecordFaultEvent("插件宿主进程重启失败", err.Error())
+		RequestStop("插件宿主进程重启失败")
+		return
+	}
+	if w.loggedIn {
+		if rtn := conn.writer.Login(w.param); rtn != 0 {
+			// 未登录的宿主进程无法继续写入, 与重启失败相同, 结束宿主进程并平滑退出
+			_ = conn.conn.Close()
+			_ = conn.cmd.Process.Kill()
+			<-conn.exited
+			w.conn = nil
+			RecordFaultEvent("插件宿主进程重启后登录失败", fmt.Sprintf("返回值: %v", rtn))
+			RequestStop("插件宿主进程重启后登录失败")
+			return
+		}
+	}
+	w.conn = conn
+	log.Printf("插件宿主进程重启成功(第%v次), pid: %v\n", w.restarts, conn.cmd.Process.Pid)
+	go w.monitor(conn)
+}
+
+// writer 当前宿主进程的插件, 宿主进程已退出时返回连接错误
+// 宿主进程重启期间会阻塞, 直到重启完成
+func (w *HostWriter) writer(op string, unitId int64) (*SdkWriter, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.conn == nil {
+		return nil, &WriteError{Op: op, UnitId: unitId, Code: WriteErrConnection, Message: "插件宿主进程已退出"}
+	}
+	return w.conn.writer, nil
+}
+
+func (w *HostWriter) Info() PluginInfo {
+	return w.info
+}
+
+func (w *HostWriter) Login(param string) int {
+	w.lock.Lock()
+	w.param = param
+	w.loggedIn = true
+	conn := w.conn
+	w.lock.Unlock()
+	if conn == nil {
+		return int(WriteErrConnection)
+	}
+	return conn.writer.Login(param)
+}
+
+// Logout 登出, 并等待宿主进程退出
+func (w *HostWriter) Logout() {
+	w.lock.Lock()
+	w.closing = true
+	conn := w.conn
+	w.lock.Unlock()
+	if conn == nil {
+		return
+	}
+
+	conn.writer.Logout()
+	_ = conn.conn.Close()
+	select {
+	case <-conn.exited:
+	case <-time.After(HostStopTimeout):
+		log.Println("插件宿主进程退出超时, 强制结束")
+		_ = conn.cmd.Process.Kill()
+		<-conn.exited
+	}
+}
+
+func (w *HostWriter) WriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error {
+	writer, err := w.writer("write_rt_analog", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteRtAnalog(magic, unitId, section, isFast)
+}
+
+func (w *HostWriter) WriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error {
+	writer, err := w.writer("write_rt_digital", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteRtDigital(magic, unitId, section, isFast)
+}
+
+func (w *HostWriter) WriteRtAnalogList(magic int32, unitId int64, sections []AnalogSection) error {
+	writer, err := w.writer("write_rt_analog_list", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteRtAnalogList(magic, unitId, sections)
+}
+
+func (w *HostWriter) WriteRtDigitalList(magic int32, unitId int64, sections []DigitalSection) error {
+	writer, err := w.writer("write_rt_digital_list", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteRtDigitalList(magic, unitId, sections)
+}
+
+func (w *HostWriter) WriteHisAnalog(magic int32, unitId int64, section AnalogSection) error {
+	writer, err := w.writer("write_his_analog", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteHisAnalog(magic, unitId, section)
+}
+
+func (w *HostWriter) WriteHisDigital(magic int32, unitId int64, section DigitalSection) error {
+	writer, err := w.writer("write_his_digital", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteHisDigital(magic, unitId, section)
+}
+
+func (w *HostWriter) WriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error {
+	writer, err := w.writer("write_static_analog", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteStaticAnalog(magic, unitId, section, typ)
+}
+
+func (w *HostWriter) WriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error {
+	writer, err := w.writer("write_static_digital", unitId)
+	if err != nil {
+		return err
+	}
+	return writer.WriteStaticDigital(magic, unitId, section, typ)
+}
+
+// PluginHost 宿主进程中的插件, 将插件后端适配为 sdk.Writer, 供 sdk.Serve 使用
+type PluginHost struct {
+	writer Writer
+}
+
+func (h *PluginHost) Info() sdk.PluginInfo {
+	return h.writer.Info()
+}
+
+func (h *PluginHost) Login(param string) int {
+	return h.writer.Login(param)
+}
+
+func (h *PluginHost) Logout() {
+	h.writer.Logout()
+}
+
+func (h *PluginHost) WriteRtAnalog(magic int32, unitId int64, time int64, data []sdk.Analog, isFast bool) error {
+	section := AnalogSection{Time: time, Data: FromSdkAnalogList(data)}
+	return ToSdkError(h.writer.WriteRtAnalog(magic, unitId, section, isFast))
+}
+
+func (h *PluginHost) WriteRtDigital(magic int32, unitId int64, time int64, data []sdk.Digital, isFast bool) error {
+	section := DigitalSection{Time: time, Data: FromSdkDigitalList(data)}
+	return ToSdkError(h.writer.WriteRtDigital(magic, unitId, section, isFast))
+}
+
+func (h *PluginHost) WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]sdk.Analog) error {
+	sections := make([]AnalogSection, len(times))
+	for i := range times {
+		sections[i] = AnalogSection{Time: times[i], Data: FromSdkAnalogList(data[i])}
+	}
+	return ToSdkError(h.writer.WriteRtAnalogList(magic, unitId, sections))
+}
+
+func (h *PluginHost) WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]sdk.Digital) error {
+	sections := make([]DigitalSection, len(times))
+	for i := range times {
+		sections[i] = DigitalSection{Time: times[i], Data: FromSdkDigitalList(data[i])}
+	}
+	return ToSdkError(h.writer.WriteRtDigitalList(magic, unitId, sections))
+}
+
+func (h *PluginHost) WriteHisAnalog(magic int32, unitId int64, time int64, data []sdk.Analog) error {
+	section := AnalogSection{Time: time, Data: FromSdkAnalogList(data)}
+	return ToSdkError(h.writer.WriteHisAnalog(magic, unitId, section))
+}
+
+func (h *PluginHost) WriteHisDigital(magic int32, unitId int64, time int64, data []sdk.Digital) error {
+	section := DigitalSection{Time: time, Data: FromSdkDigitalList(data)}
+	return ToSdkError(h.writer.WriteHisDigital(magic, unitId, section))
+}
+
+func (h *PluginHost) WriteStaticAnalog(magic int32, unitId int64, data []sdk.StaticAnalog, typ int64) error {
+	section := StaticAnalogSection{Data: make([]C.StaticAnalog, len(data))}
+	for i := range data {
+		section.Data[i] = FromSdkStaticAnalog(data[i])
+	}
+	return ToSdkError(h.writer.WriteStaticAnalog(magic, unitId, section, typ))
+}
+
+func (h *PluginHost) WriteStaticDigital(magic int32, unitId int64, data []sdk.StaticDigital, typ int64) error {
+	section := StaticDigitalSection{Data: make([]C.StaticDigital, len(data))}
+	for i := range data {
+		section.Data[i] = FromSdkStaticDigital(data[i])
+	}
+	return ToSdkError(h.writer.WriteStaticDigital(magic, unitId, section, typ))
+}
+
+// ToSdkError 将 *WriteError 转换为 *sdk.Error, 写入成功时返回nil
+func ToSdkError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	if writeErr, ok := err.(*WriteError); ok {
+		message = writeErr.Message
+	}
+	return &sdk.Error{Code: sdk.ErrorCode(WriteErrorCodeOf(err)), Message: message}
+}
+
+// ServePluginHost 插件宿主进程入口
+// 加载插件后监听socket, 只服务一个连接(写入程序), 连接断开后退出
+func ServePluginHost(pluginPath string, socketPath string) error {
+	if strings.HasPrefix(pluginPath, HostPluginScheme) {
+		return fmt.Errorf("插件宿主进程不能嵌套: %v", pluginPath)
+	}
+	writer, err := NewWriter(pluginPath)
+	if err != nil {
+		return err
+	}
+
+	_ = os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("插件宿主进程监听失败: %v, %v", socketPath, err)
+	}
+	defer func() { _ = os.Remove(socketPath) }()
+	conn, err := listener.Accept()
+	_ = listener.Close()
+	if err != nil {
+		return fmt.Errorf("插件宿主进程建立连接失败: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	log.Printf("插件宿主进程已启动, pid: %v, 插件: %v, %v\n", os.Getpid(), pluginPath, writer.Info())
+	return sdk.Serve(conn, conn, &PluginHost{writer: writer})
+}
@@ -1,8 +1,6 @@
 package main
 
 // #cgo CFLAGS: -I../plugin
-// #include <stdlib.h>
-// #include "dylib.h"
 // #include "write_plugin.h"
 import "C"
 import (
@@ -11,232 +9,268 @@ import (
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
-	"gonum.org/v1/gonum/stat"
+	"io"
 	"log"
-	"math/rand"
 	"os"
-	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
-	"unsafe"
 )
 
-// CacheSize  缓存队列大小
+// CacheSize  缓存队列大小, 周期性写入时可以通过 --cache_size 修改
 const CacheSize = 64
 
-// OverloadProtectionWriteDuration  过载保护持续时间, 2000毫秒(2秒)
+// OverloadProtectionWriteDuration  默认的过载保护持续时间, 2000毫秒(2秒)
 const OverloadProtectionWriteDuration = 2000
 
-// OverloadProtectionWritePeriodic 过载保护写入周期, 50毫秒
+// OverloadProtectionWritePeriodic 默认的过载保护写入周期, 50毫秒
 const OverloadProtectionWritePeriodic = 50
 
-// FastRegularWritePeriodic 快采点写入周期, 1毫秒
+// FastRegularWritePeriodic 默认的快采点写入周期, 1毫秒
 const FastRegularWritePeriodic = 1
 
-// NormalRegularWritePeriodic 普通点写入周期, 400毫秒
+// NormalRegularWritePeriodic 默认的普通点写入周期, 400毫秒
 const NormalRegularWritePeriodic = 400
 
 // WriteSectionInfo  每次写入断面, 记录基本信息
 type WriteSectionInfo struct {
 	UnitNumber   int64         // 机组数量
 	Time         int64         // 断面时间
+	Start        time.Time     // 开始写入断面的时间
+	Intended     time.Time     // 计划写入断面的时间, 只有周期性写入时有效
 	Duration     time.Duration // 写入断面消耗的时间
 	SectionCount int64         // 断面数量
 	PNumCount    int64         // PNum数量
-}
-
-var FastAnalogWriteSectionInfoList = make([]WriteSectionInfo, 0)
-var FastDigitalWriteSectionInfoList = make([]WriteSectionInfo, 0)
-var NormalAnalogWriteSectionInfoList = make([]WriteSectionInfo, 0)
-var NormalDigitalWriteSectionInfoList = make([]WriteSectionInfo, 0)
-var FastSleepDurationList = make([]time.Duration, 0)
-var NormalSleepDurationList = make([]time.Duration, 0)
-
-func DurationListToFloatList(durationList []time.Duration) []float64 {
-	rtn := make([]float64, 0)
-	for _, t := range durationList {
-		rtn = append(rtn, float64(t))
+	Errors       []error       // 写入失败的错误列表, 每个写入失败的机组对应一条, 为空表示写入成功
+}
+
+// LatencySummary 耗时统计结果
+type LatencySummary struct {
+	All   time.Duration // 总耗时
+	Count int           // 断面数量
+	PNum  int           // PNUM数量
+	Avg   time.Duration
+	Max   time.Duration
+	Min   time.Duration
+	P9999 time.Duration
+	P999  time.Duration
+	P99   time.Duration
+	P95   time.Duration
+	P50   time.Duration
+}
+
+func Summary(stats *LatencyStats, fastCache bool) LatencySummary {
+	h := stats.Histogram
+	rtn := LatencySummary{
+		All:   h.Sum(),
+		Count: int(stats.SectionCount),
+		PNum:  int(stats.PNumCount),
+		Max:   h.Max(),
+		Min:   h.Min(),
+		P9999: h.Quantile(0.9999),
+		P999:  h.Quantile(0.999),
+		P99:   h.Quantile(0.99),
+		P95:   h.Quantile(0.95),
+		P50:   h.Quantile(0.50),
+	}
+	if stats.SectionCount != 0 {
+		rtn.Avg = rtn.All / time.Duration(stats.SectionCount)
+	}
+
+	// 开启快采点缓存时, 每条记录为一次批量写入, 换算为单个断面的耗时
+	if fastCache {
+		batchSize := time.Duration(stats.BatchSize)
+		rtn.Max /= batchSize
+		rtn.Min /= batchSize
+		rtn.P9999 /= batchSize
+		rtn.P999 /= batchSize
+		rtn.P99 /= batchSize
+		rtn.P95 /= batchSize
+		rtn.P50 /= batchSize
 	}
+
 	return rtn
 }
 
-func Summary(analogList []WriteSectionInfo, digitalList []WriteSectionInfo, fastCache bool) (time.Duration, int, time.Duration, time.Duration, time.Duration, time.Duration, time.Duration, time.Duration, int) {
-	infoLen := len(analogList)
-	if len(digitalList) > infoLen {
-		infoLen = len(digitalList)
-	}
-	infoList := make([]WriteSectionInfo, infoLen)
-	for i, info := range analogList {
-		infoList[i] = WriteSectionInfo{
-			Duration:     info.Duration,
-			SectionCount: info.SectionCount,
-			PNumCount:    info.PNumCount,
-			Time:         info.Time,
-			UnitNumber:   info.UnitNumber,
-		}
+// FormatErrorCodes 按错误码顺序格式化错误分类统计
+func FormatErrorCodes(errorCodes map[WriteErrorCode]int) string {
+	if len(errorCodes) == 0 {
+		return "无"
 	}
-
-	for i, info := range digitalList {
-		infoList[i].Duration += info.Duration
-		infoList[i].PNumCount += info.PNumCount
+	codes := make([]WriteErrorCode, 0, len(errorCodes))
+	for code := range errorCodes {
+		codes = append(codes, code)
 	}
-
-	allDuration := time.Duration(0)
-	sectionCount := 0
-	durationList := make([]time.Duration, 0)
-	pnumCount := 0
-	for _, info := range infoList {
-		durationList = append(durationList, info.Duration)
-		allDuration += info.Duration
-		sectionCount += int(info.SectionCount)
-		pnumCount += int(info.PNumCount)
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i] < codes[j]
+	})
+	items := make([]string, 0, len(codes))
+	for _, code := range codes {
+		items = append(items, fmt.Sprintf("%v(%d): %v", code, int(code), errorCodes[code]))
 	}
+	return strings.Join(items, ", ")
+}
 
-	sort.Slice(durationList, func(i, j int) bool {
-		return durationList[i] < durationList[j]
-	})
-	dAvg := allDuration / time.Duration(sectionCount)
-	dMax := time.Duration(stat.Quantile(1.00, stat.Empirical, DurationListToFloatList(durationList), nil))
-	dMin := time.Duration(stat.Quantile(0.00, stat.Empirical, DurationListToFloatList(durationList), nil))
-	dP99 := time.Duration(stat.Quantile(0.99, stat.Empirical, DurationListToFloatList(durationList), nil))
-	dP95 := time.Duration(stat.Quantile(0.95, stat.Empirical, DurationListToFloatList(durationList), nil))
-	dP50 := time.Duration(stat.Quantile(0.50, stat.Empirical, DurationListToFloatList(durationList), nil))
+// LogFailureSummary 输出写入失败统计, prefix为日志前缀(如"快采点 - ")
+func LogFailureSummary(prefix string, stats *LatencyStats) {
+	log.Printf("%v失败断面数量: %v, 失败PNUM数量: %v, 错误分类: %v\n", prefix, stats.FailedSectionCount, stats.FailedPNumCount, FormatErrorCodes(stats.ErrorCodes))
+}
 
-	if fastCache {
-		dMax /= 100
-		dMin /= 100
-		dP99 /= 100
-		dP95 /= 100
-		dP50 /= 100
+// LogSkippedRows 输出跳过的CSV行数, 即无法读取, 列数错误或无法解析的行, 使用 --strict 时这些行会在登录前报错
+func LogSkippedRows(stats ...*WriteStats) {
+	skipped := int64(0)
+	for _, s := range stats {
+		skipped += s.SkippedRows
 	}
+	log.Printf("跳过的CSV行数: %v\n", skipped)
+}
 
-	return allDuration, sectionCount, dAvg, dMax, dMin, dP99, dP95, dP50, pnumCount
+// LogScheduleSummary 输出周期性写入的调度统计
+func LogScheduleSummary(prefix string, stats *WriteStats) {
+	schedule := stats.Schedule
+	log.Printf("%v写入次数: %v, 错过截止时间次数: %v, 最大超时: %v, 超时总时长: %v, 累计偏差: %v, 最大偏差: %v, 跳过周期数: %v, 合并周期数: %v\n",
+		prefix, schedule.Writes, schedule.Missed, schedule.MaxOverrun, schedule.Overrun, schedule.Drift, schedule.MaxDrift, schedule.Skipped, schedule.Coalesced,
+	)
 }
 
-func StaticSummary(magic int32, name string, start time.Time, end time.Time, analog []WriteSectionInfo, digital []WriteSectionInfo, logoutDuration time.Duration) {
+func StaticSummary(magic int32, name string, start time.Time, end time.Time, stats *WriteStats, logoutDuration time.Duration) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
-	log.Printf("总耗时: %v, 机组数量: %v, 写入pnum数量: %v\n", analog[0].Duration+digital[0].Duration+logoutDuration, analog[0].UnitNumber, analog[0].PNumCount+digital[0].PNumCount)
+	log.Printf("总耗时: %v, 机组数量: %v, 写入pnum数量: %v\n", stats.Total.Histogram.Sum()+logoutDuration, stats.UnitNumber, stats.Total.PNumCount)
+	LogSkippedRows(stats)
+	LogFailureSummary("", stats.Total)
+	LogFaultEvents()
 }
 
 func HisFastWriteSummary(
 	magic int32, name string, start time.Time, end time.Time,
-	normalAnalog []WriteSectionInfo, normalDigital []WriteSectionInfo,
-	logoutDuration time.Duration,
+	normal *WriteStats,
+	logoutDuration time.Duration, csvOptions CsvOptions,
 ) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
-	if len(normalAnalog) != 0 && len(normalDigital) != 0 {
-		nAll, nCount, nAvg, nMax, nMin, nP99, nP95, nP50, nPNum := Summary(normalAnalog, normalDigital, false)
-		log.Printf("总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v,\n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			nAll+logoutDuration, nCount, nPNum, nAvg, nMax, nMin, nP99, nP95, nP50,
+	LogCsvOptions(csvOptions)
+	LogSkippedRows(normal)
+	if !normal.IsEmpty() {
+		n := Summary(normal.Total, false)
+		log.Printf("总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v,\n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			n.All+logoutDuration, n.Count, n.PNum, n.Avg, n.Max, n.Min, n.P9999, n.P999, n.P99, n.P95, n.P50,
 		)
+		LogFailureSummary("", normal.Total)
 	}
+	LogFaultEvents()
 }
 
 func ParallelRtFastWriteSummary(
 	magic int32, name string, start time.Time, end time.Time,
-	fastAnalog []WriteSectionInfo, fastDigital []WriteSectionInfo,
-	normalAnalog []WriteSectionInfo, normalDigital []WriteSectionInfo,
-	logoutDuration time.Duration,
+	fast *WriteStats, normal *WriteStats,
+	logoutDuration time.Duration, csvOptions CsvOptions,
 ) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	LogCsvOptions(csvOptions)
+	LogSkippedRows(fast, normal)
 	allTime := time.Duration(0)
-	if len(fastAnalog) != 0 && len(fastDigital) != 0 {
-		fAll, fCount, fAvg, fMax, fMin, fP99, fP95, fP50, fPNum := Summary(fastAnalog, fastDigital, false)
-		if allTime < fAll {
-			allTime = fAll
+	if !fast.IsEmpty() {
+		f := Summary(fast.Total, false)
+		if allTime < f.All {
+			allTime = f.All
 		}
-		log.Printf("快采点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			fAll, fCount, fPNum, fAvg, fMax, fMin, fP99, fP95, fP50,
+		log.Printf("快采点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			f.All, f.Count, f.PNum, f.Avg, f.Max, f.Min, f.P9999, f.P999, f.P99, f.P95, f.P50,
 		)
+		LogFailureSummary("快采点 - ", fast.Total)
 	}
-	if len(normalAnalog) != 0 && len(normalDigital) != 0 {
-		nAll, nCount, nAvg, nMax, nMin, nP99, nP95, nP50, nPNum := Summary(normalAnalog, normalDigital, false)
-		if allTime < nAll {
-			allTime = nAll
+	if !normal.IsEmpty() {
+		n := Summary(normal.Total, false)
+		if allTime < n.All {
+			allTime = n.All
 		}
-		log.Printf("普通点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			nAll, nCount, nPNum, nAvg, nMax, nMin, nP99, nP95, nP50,
+		log.Printf("普通点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			n.All, n.Count, n.PNum, n.Avg, n.Max, n.Min, n.P9999, n.P999, n.P99, n.P95, n.P50,
 		)
+		LogFailureSummary("普通点 - ", normal.Total)
 	}
 	log.Printf("统计总耗时(刨除掉等待CSV读取时间): %v\n", allTime+logoutDuration)
 	log.Printf("实际总耗时(会算上等待CSV读取时间): %v\n", end.Sub(start)+logoutDuration)
+	LogFaultEvents()
 }
 
 func RtFastWriteSummary(
 	magic int32, name string, start time.Time, end time.Time,
-	fastAnalog []WriteSectionInfo, fastDigital []WriteSectionInfo,
-	normalAnalog []WriteSectionInfo, normalDigital []WriteSectionInfo,
-	logoutDuration time.Duration,
+	fast *WriteStats, normal *WriteStats,
+	logoutDuration time.Duration, csvOptions CsvOptions,
 ) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	LogCsvOptions(csvOptions)
+	LogSkippedRows(fast, normal)
 	all := time.Duration(0)
-	if len(fastAnalog) != 0 && len(fastDigital) != 0 {
-		fAll, fCount, fAvg, fMax, fMin, fP99, fP95, fP50, fPNum := Summary(fastAnalog, fastDigital, false)
-		log.Printf("快采点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			fAll, fCount, fPNum, fAvg, fMax, fMin, fP99, fP95, fP50,
+	if !fast.IsEmpty() {
+		f := Summary(fast.Total, false)
+		log.Printf("快采点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			f.All, f.Count, f.PNum, f.Avg, f.Max, f.Min, f.P9999, f.P999, f.P99, f.P95, f.P50,
 		)
-		all += fAll
+		LogFailureSummary("快采点 - ", fast.Total)
+		all += f.All
 	}
-	if len(normalAnalog) != 0 && len(normalDigital) != 0 {
-		nAll, nCount, nAvg, nMax, nMin, nP99, nP95, nP50, nPNum := Summary(normalAnalog, normalDigital, false)
-		log.Printf("普通点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			nAll, nCount, nPNum, nAvg, nMax, nMin, nP99, nP95, nP50,
+	if !normal.IsEmpty() {
+		n := Summary(normal.Total, false)
+		log.Printf("普通点 - 总耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			n.All, n.Count, n.PNum, n.Avg, n.Max, n.Min, n.P9999, n.P999, n.P99, n.P95, n.P50,
 		)
-		all += nAll
+		LogFailureSummary("普通点 - ", normal.Total)
+		all += n.All
 	}
 	log.Printf("写入总耗时: %v\n", all+logoutDuration)
+	LogFaultEvents()
 }
 
 func PeriodicWriteHisSummary(
 	magic int32, name string, start time.Time, end time.Time,
-	normalAnalog []WriteSectionInfo, normalDigital []WriteSectionInfo, normalSleepList []time.Duration, logoutDuration time.Duration,
+	normal *WriteStats, logoutDuration time.Duration, config PeriodicConfig, csvOptions CsvOptions,
 ) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
-	if len(normalAnalog) != 0 && len(normalDigital) != 0 {
-		nSleepSum := time.Duration(0)
-		for _, d := range normalSleepList {
-			nSleepSum += d
-		}
-		nAll, nCount, nAvg, nMax, nMin, nP99, nP95, nP50, nPNum := Summary(normalAnalog, normalDigital, false)
-		log.Printf("总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			nAll+logoutDuration, nSleepSum, nCount, nPNum, nAvg, nMax, nMin, nP99, nP95, nP50,
+	LogPeriodicConfig(config)
+	LogCsvOptions(csvOptions)
+	LogSkippedRows(normal)
+	if !normal.IsEmpty() {
+		n := Summary(normal.Total, false)
+		log.Printf("总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, 平均耗时: %v, \n\t\t最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			n.All+logoutDuration, normal.Sleep, n.Count, n.PNum, n.Avg, n.Max, n.Min, n.P9999, n.P999, n.P99, n.P95, n.P50,
 		)
+		LogFailureSummary("", normal.Total)
+		LogScheduleSummary("", normal)
 	}
+	LogFaultEvents()
 }
 
 func PeriodicWriteRtSummary(
 	magic int32, name string, start time.Time, end time.Time,
-	fastAnalog []WriteSectionInfo, fastDigital []WriteSectionInfo, fastSleepList []time.Duration,
-	normalAnalog []WriteSectionInfo, normalDigital []WriteSectionInfo, normalSleepList []time.Duration,
-	logoutDuration time.Duration, fastCache bool,
+	fast *WriteStats, normal *WriteStats,
+	logoutDuration time.Duration, fastCache bool, config PeriodicConfig, csvOptions CsvOptions,
 ) {
 	log.Printf("MAGIC: %v, %v - 开始时间: %v, 结束时间: %v\n", magic, name, start.Format(time.RFC3339), end.Format(time.RFC3339))
-
-	if len(fastAnalog) != 0 && len(fastDigital) != 0 {
-		fAll, fCount, fAvg, fMax, fMin, fP99, fP95, fP50, fPNum := Summary(fastAnalog, fastDigital, fastCache)
-		fSleepSum := time.Duration(0)
-		for _, d := range fastSleepList {
-			fSleepSum += d
-		}
-		log.Printf("快采点 - 总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, \n\t\t平均耗时: %v ,最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			fAll+logoutDuration, fSleepSum, fCount, fPNum, fAvg, fMax, fMin, fP99, fP95, fP50,
+	LogPeriodicConfig(config)
+	LogCsvOptions(csvOptions)
+	LogSkippedRows(fast, normal)
+
+	if !fast.IsEmpty() {
+		f := Summary(fast.Total, fastCache)
+		log.Printf("快采点 - 总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, \n\t\t平均耗时: %v ,最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			f.All+logoutDuration, fast.Sleep, f.Count, f.PNum, f.Avg, f.Max, f.Min, f.P9999, f.P999, f.P99, f.P95, f.P50,
 		)
+		LogFailureSummary("快采点 - ", fast.Total)
+		LogScheduleSummary("快采点 - ", fast)
 	}
 
-	if len(normalAnalog) != 0 && len(normalDigital) != 0 {
-		nSleepSum := time.Duration(0)
-		for _, d := range normalSleepList {
-			nSleepSum += d
-		}
-		nAll, nCount, nAvg, nMax, nMin, nP99, nP95, nP50, nPNum := Summary(normalAnalog, normalDigital, fastCache)
-		log.Printf("普通点 - 总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, \n\t\t平均耗时: %v ,最长耗时: %v, 最短耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
-			nAll+logoutDuration, nSleepSum, nCount, nPNum, nAvg, nMax, nMin, nP99, nP95, nP50,
+	if !normal.IsEmpty() {
+		n := Summary(normal.Total, fastCache)
+		log.Printf("普通点 - 总耗时: %v, 睡眠耗时: %v, 断面数量: %v, PNUM数量: %v, \n\t\t平均耗时: %v ,最长耗时: %v, 最短耗时: %v, P99.99耗时: %v, P99.9耗时: %v, P99耗时: %v, P95耗时: %v, 中位数耗时: %v\n",
+			n.All+logoutDuration, normal.Sleep, n.Count, n.PNum, n.Avg, n.Max, n.Min, n.P9999, n.P999, n.P99, n.P95, n.P50,
 		)
+		LogFailureSummary("普通点 - ", normal.Total)
+		LogScheduleSummary("普通点 - ", normal)
 	}
+	LogFaultEvents()
 }
 
 type Section struct {
@@ -246,14 +280,24 @@ type Section struct {
 	digital   DigitalSection
 }
 
+// time 断面的时间戳, 没有模拟量时使用数字量的时间戳
+func (s Section) time() int64 {
+	if s.analogOk {
+		return s.analog.Time
+	}
+	return s.digital.Time
+}
+
 type AnalogSection struct {
-	Time int64
-	Data []C.Analog
+	Time  int64
+	Data  []C.Analog
+	Units [][]C.Analog // 按机组读取CSV时每个机组各自的数据, 此时Data为空; 为nil时所有机组写入Data
 }
 
 type DigitalSection struct {
-	Time int64
-	Data []C.Digital
+	Time  int64
+	Data  []C.Digital
+	Units [][]C.Digital // 按机组读取CSV时每个机组各自的数据, 此时Data为空; 为nil时所有机组写入Data
 }
 
 type StaticAnalogSection struct {
@@ -273,9 +317,9 @@ func ParseAnalogRecord(record []string) (int64, C.Analog, error) {
 		return -1, analog, errors.New("continue HEAD")
 	}
 
-	// 去除尾行
+	// 列数错误, 如截断的尾行
 	if len(record) != 11 {
-		return -1, analog, errors.New("continue TAIL")
+		return -1, analog, errors.New(fmt.Sprintln("column count error", len(record)))
 	}
 
 	// 解析行
@@ -325,7 +369,7 @@ func ParseAnalogRecord(record []string) (int64, C.Analog, error) {
 	}
 
 	if len(record[9]) != 1 {
-		return -1, analog, errors.New(fmt.Sprintln("parse ms error", record[9]))
+		return -1, analog, errors.New(fmt.Sprintln("parse tew error", record[9]))
 	}
 	tew := record[9][0]
 
@@ -357,9 +401,9 @@ func ParseDigitalRecord(record []string) (int64, C.Digital, error) {
 		return -1, digital, errors.New("continue HEAD")
 	}
 
-	// 去除尾行
+	// 列数错误, 如截断的尾行
 	if len(record) != 11 {
-		return -1, digital, errors.New("continue TAIL")
+		return -1, digital, errors.New(fmt.Sprintln("column count error", len(record)))
 	}
 
 	ts, err := strconv.ParseInt(record[0], 10, 64)
@@ -400,7 +444,7 @@ func ParseDigitalRecord(record []string) (int64, C.Digital, error) {
 		return -1, digital, errors.New(fmt.Sprintln("parse ms error", record[8]))
 	}
 	if len(record[9]) != 1 {
-		return -1, digital, errors.New(fmt.Sprintln("parse ms error", record[9]))
+		return -1, digital, errors.New(fmt.Sprintln("parse tew error", record[9]))
 	}
 	tew := record[9][0]
 	cst, err := strconv.ParseInt(record[10], 10, 32)
@@ -431,9 +475,9 @@ func ParseStaticAnalogRecord(record []string) (C.StaticAnalog, error) {
 		return staticAnalog, errors.New("continue HEAD")
 	}
 
-	// 去除尾行
+	// 列数错误, 如截断的尾行
 	if len(record) != 17 {
-		return staticAnalog, errors.New("continue TAIL")
+		return staticAnalog, errors.New(fmt.Sprintln("column count error", len(record)))
 	}
 
 	pNum, err := strconv.ParseInt(record[0], 10, 32)
@@ -542,9 +586,9 @@ func ParseStaticDigitalRecord(record []string) (C.StaticDigital, error) {
 		return staticDigital, errors.New("continue HEAD")
 	}
 
-	// 去除尾行
+	// 列数错误, 如截断的尾行
 	if len(record) != 6 {
-		return staticDigital, errors.New("continue TAIL")
+		return staticDigital, errors.New(fmt.Sprintln("column count error", len(record)))
 	}
 
 	pNum, err := strconv.ParseInt(record[0], 10, 32)
@@ -579,25 +623,57 @@ func ParseStaticDigitalRecord(record []string) (C.StaticDigital, error) {
 	return staticDigital, nil
 }
 
-func ReadCsv(wg2 *sync.WaitGroup, analogFilePath string, digitalFilePath string, sectionCh chan Section, exitCh chan bool) {
+// ReadCsv 读取模拟量和数字量CSV文件, 合并为断面后发送到缓存队列, input用于统计读取进度, 可以为nil
+// options指定读取遍数和时间戳模式, 模拟量和数字量文件分别按各自的时间跨度平移, 两者的时间范围应当一致
+// 按机组读取CSV时每个机组的文件由各自的协程读取, 再按时间戳合并为一个断面
+func ReadCsv(wg2 *sync.WaitGroup, analogFilePath string, digitalFilePath string, sectionCh chan Section, exitCh chan bool, input *ProgressInput, options CsvOptions) {
 	defer wg2.Done()
 
-	rd1 := make(chan bool, 1)
-	rd2 := make(chan bool, 1)
+	exits := make([]chan bool, 0)
+	newExit := func() chan bool {
+		rd := make(chan bool, 1)
+		exits = append(exits, rd)
+		return rd
+	}
+
+	analogCh := make(chan AnalogSection, cap(sectionCh))
+	digitalCh := make(chan DigitalSection, cap(sectionCh))
+	wg := new(sync.WaitGroup)
+	analogPaths := options.csvPaths(analogFilePath)
+	analogChs := make([]chan AnalogSection, len(analogPaths))
+	for i, path := range analogPaths {
+		analogChs[i] = analogCh
+		if len(analogPaths) > 1 {
+			analogChs[i] = make(chan AnalogSection, cap(sectionCh))
+		}
+		wg.Add(1)
+		go ReadAnalogCsv(wg, path, analogChs[i], newExit(), input, options)
+	}
+	if len(analogPaths) > 1 {
+		go MergeAnalogUnits(analogChs, analogCh)
+	}
+	digitalPaths := options.csvPaths(digitalFilePath)
+	digitalChs := make([]chan DigitalSection, len(digitalPaths))
+	for i, path := range digitalPaths {
+		digitalChs[i] = digitalCh
+		if len(digitalPaths) > 1 {
+			digitalChs[i] = make(chan DigitalSection, cap(sectionCh))
+		}
+		wg.Add(1)
+		go ReadDigitalCsv(wg, path, digitalChs[i], newExit(), input, options)
+	}
+	if len(digitalPaths) > 1 {
+		go MergeDigitalUnits(digitalChs, digitalCh)
+	}
+
 	go func() {
 		<-exitCh
-		rd1 <- true
-		rd2 <- true
+		for _, rd := range exits {
+			rd <- true
+		}
 		log.Println("ReadCsv 收到平滑退出信号")
 	}()
 
-	analogCh := make(chan AnalogSection, CacheSize)
-	digitalCh := make(chan DigitalSection, CacheSize)
-	wg := new(sync.WaitGroup)
-	wg.Add(2)
-	go ReadAnalogCsv(wg, analogFilePath, analogCh, rd1)
-	go ReadDigitalCsv(wg, digitalFilePath, digitalCh, rd2)
-
 	for {
 		analogSection, ok1 := <-analogCh
 		digitalSection, ok2 := <-digitalCh
@@ -619,7 +695,8 @@ func ReadCsv(wg2 *sync.WaitGroup, analogFilePath string, digitalFilePath string,
 }
 
 // ReadAnalogCsv 读取CSV文件, 将其转换成 C.Analog 结构后发送到缓存队列
-func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, exitCh chan bool) {
+// 读完一遍后按options从头重新读取, 时间戳在上一遍的基础上平移数据集的时间跨度
+func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, exitCh chan bool, input *ProgressInput, options CsvOptions) {
 	defer wg.Done()
 
 	// 打开文件
@@ -630,7 +707,9 @@ func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, e
 	defer func() { _ = file.Close() }()
 
 	// CSV读取器
-	reader := csv.NewReader(NewCRFilterReader(bufio.NewReader(file)))
+	counted := input.Reader(file, options.Loop)
+	reader := NewCsvReader(counted)
+	shift := newTimeShift(options)
 
 	// 按行读取
 	dataList := make([]C.Analog, 0)
@@ -647,22 +726,37 @@ func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, e
 			if err != nil {
 				if err.Error() == "EOF" {
 					if len(dataList) != 0 {
-						ch <- AnalogSection{Time: tsFlag, Data: dataList}
+						ch <- AnalogSection{Time: shift.shift(tsFlag), Data: dataList}
 					}
-					close(ch)
-					return
+					if !shift.next() {
+						close(ch)
+						return
+					}
+					// 从头重新读取下一遍
+					if _, err := file.Seek(0, io.SeekStart); err != nil {
+						log.Printf("Error rewinding file: %s", err)
+						close(ch)
+						return
+					}
+					reader = NewCsvReader(counted)
+					dataList = make([]C.Analog, 0)
+					tsFlag = -1
+					continue
 				}
-				log.Printf("Error reading record: %s", err)
+				log.Printf("Error reading record: %v:%v: %s", filepath, CsvLine(reader, err), err)
+				input.Skip()
 				continue
 			}
 
 			ts, analog, err := ParseAnalogRecord(record)
 			if err != nil {
 				if !strings.Contains(err.Error(), "continue HEAD") {
-					log.Printf("Error parsing record: %s", err)
+					log.Printf("Error parsing record: %v:%v: %s", filepath, CsvLine(reader, nil), strings.TrimSpace(err.Error()))
+					input.Skip()
 				}
 				continue
 			}
+			shift.observe(ts)
 
 			// time 初始化
 			if tsFlag == -1 {
@@ -671,7 +765,7 @@ func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, e
 
 			// 如果出现的时间戳, 则更新timeFlag, 发送数据, 并且清空dataList
 			if tsFlag != ts {
-				ch <- AnalogSection{Time: tsFlag, Data: dataList}
+				ch <- AnalogSection{Time: shift.shift(tsFlag), Data: dataList}
 				tsFlag = ts
 				dataList = make([]C.Analog, 0)
 			}
@@ -683,7 +777,8 @@ func ReadAnalogCsv(wg *sync.WaitGroup, filepath string, ch chan AnalogSection, e
 }
 
 // ReadDigitalCsv 读取CSV文件, 将其转换成 C.Digital 结构后发送到缓存队列
-func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection, exitCh chan bool) {
+// 读完一遍后按options从头重新读取, 时间戳在上一遍的基础上平移数据集的时间跨度
+func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection, exitCh chan bool, input *ProgressInput, options CsvOptions) {
 	defer wg.Done()
 
 	// 打开文件
@@ -694,7 +789,9 @@ func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection,
 	defer func() { _ = file.Close() }()
 
 	// CSV读取器
-	reader := csv.NewReader(NewCRFilterReader(bufio.NewReader(file)))
+	counted := input.Reader(file, options.Loop)
+	reader := NewCsvReader(counted)
+	shift := newTimeShift(options)
 
 	// 按行读取
 	dataList := make([]C.Digital, 0)
@@ -711,22 +808,37 @@ func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection,
 			if err != nil {
 				if err.Error() == "EOF" {
 					if len(dataList) != 0 {
-						ch <- DigitalSection{Time: tsFlag, Data: dataList}
+						ch <- DigitalSection{Time: shift.shift(tsFlag), Data: dataList}
 					}
-					close(ch)
-					return
+					if !shift.next() {
+						close(ch)
+						return
+					}
+					// 从头重新读取下一遍
+					if _, err := file.Seek(0, io.SeekStart); err != nil {
+						log.Printf("Error rewinding file: %s", err)
+						close(ch)
+						return
+					}
+					reader = NewCsvReader(counted)
+					dataList = make([]C.Digital, 0)
+					tsFlag = -1
+					continue
 				}
-				log.Printf("Error reading record: %s", err)
+				log.Printf("Error reading record: %v:%v: %s", filepath, CsvLine(reader, err), err)
+				input.Skip()
 				continue
 			}
 
 			ts, digital, err := ParseDigitalRecord(record)
 			if err != nil {
 				if !strings.Contains(err.Error(), "continue HEAD") {
-					log.Printf("Error parsing record: %s", err)
+					log.Printf("Error parsing record: %v:%v: %s", filepath, CsvLine(reader, nil), strings.TrimSpace(err.Error()))
+					input.Skip()
 				}
 				continue
 			}
+			shift.observe(ts)
 
 			// time 初始化
 			if tsFlag == -1 {
@@ -736,7 +848,7 @@ func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection,
 			// 如果出现的时间戳, 则更新timeFlag, 发送数据, 并且清空dataList
 			if tsFlag != ts {
 				if len(dataList) != 0 {
-					ch <- DigitalSection{Time: tsFlag, Data: dataList}
+					ch <- DigitalSection{Time: shift.shift(tsFlag), Data: dataList}
 				}
 				tsFlag = ts
 				dataList = make([]C.Digital, 0)
@@ -749,8 +861,8 @@ func ReadDigitalCsv(wg *sync.WaitGroup, filepath string, ch chan DigitalSection,
 	}
 }
 
-// ReadStaticAnalogCsv 读取CSV文件, 将其转换成 []C.StaticAnalog 切片
-func ReadStaticAnalogCsv(filepath string) StaticAnalogSection {
+// ReadStaticAnalogCsv 读取CSV文件, 将其转换成 []C.StaticAnalog 切片, 同时返回跳过的行数
+func ReadStaticAnalogCsv(filepath string) (StaticAnalogSection, int64) {
 	// 打开文件
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -759,9 +871,10 @@ func ReadStaticAnalogCsv(filepath string) StaticAnalogSection {
 	defer func() { _ = file.Close() }()
 
 	// CSV读取器
-	reader := csv.NewReader(NewCRFilterReader(bufio.NewReader(file)))
+	reader := NewCsvReader(file)
 
 	dataList := make([]C.StaticAnalog, 0)
+	skipped := int64(0)
 	for {
 		// 读取一行, 判断是否为EOF
 		record, err := reader.Read()
@@ -769,14 +882,16 @@ func ReadStaticAnalogCsv(filepath string) StaticAnalogSection {
 			if err.Error() == "EOF" {
 				break
 			}
-			log.Printf("Error reading record: %s", err)
+			log.Printf("Error reading record: %v:%v: %s", filepath, CsvLine(reader, err), err)
+			skipped++
 			continue
 		}
 
 		staticAnalog, err := ParseStaticAnalogRecord(record)
 		if err != nil {
 			if !strings.Contains(err.Error(), "continue HEAD") {
-				log.Printf("Error parsing record: %s", err)
+				log.Printf("Error parsing record: %v:%v: %s", filepath, CsvLine(reader, nil), strings.TrimSpace(err.Error()))
+				skipped++
 			}
 			continue
 		}
@@ -784,11 +899,11 @@ func ReadStaticAnalogCsv(filepath string) StaticAnalogSection {
 		dataList = append(dataList, staticAnalog)
 	}
 
-	return StaticAnalogSection{Data: dataList}
+	return StaticAnalogSection{Data: dataList}, skipped
 }
 
-// ReadStaticDigitalCsv 读取CSV文件, 将其转换成 []C.StaticDigital 切片
-func ReadStaticDigitalCsv(filepath string) StaticDigitalSection {
+// ReadStaticDigitalCsv 读取CSV文件, 将其转换成 []C.StaticDigital 切片, 同时返回跳过的行数
+func ReadStaticDigitalCsv(filepath string) (StaticDigitalSection, int64) {
 	// 打开文件
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -797,9 +912,10 @@ func ReadStaticDigitalCsv(filepath string) StaticDigitalSection {
 	defer func() { _ = file.Close() }()
 
 	// CSV读取器
-	reader := csv.NewReader(NewCRFilterReader(bufio.NewReader(file)))
+	reader := NewCsvReader(file)
 
 	dataList := make([]C.StaticDigital, 0)
+	skipped := int64(0)
 	for {
 		// 读取一行, 判断是否为EOF
 		record, err := reader.Read()
@@ -807,14 +923,16 @@ func ReadStaticDigitalCsv(filepath string) StaticDigitalSection {
 			if err.Error() == "EOF" {
 				break
 			}
-			log.Printf("Error reading record: %s", err)
+			log.Printf("Error reading record: %v:%v: %s", filepath, CsvLine(reader, err), err)
+			skipped++
 			continue
 		}
 
 		staticDigital, err := ParseStaticDigitalRecord(record)
 		if err != nil {
 			if !strings.Contains(err.Error(), "continue HEAD") {
-				log.Printf("Error parsing record: %s", err)
+				log.Printf("Error parsing record: %v:%v: %s", filepath, CsvLine(reader, nil), strings.TrimSpace(err.Error()))
+				skipped++
 			}
 			continue
 		}
@@ -823,11 +941,13 @@ func ReadStaticDigitalCsv(filepath string) StaticDigitalSection {
 
 	}
 
-	return StaticDigitalSection{Data: dataList}
+	return StaticDigitalSection{Data: dataList}, skipped
 }
 
 // FastWriteRealtimeSection 极速写入实时断面
-func FastWriteRealtimeSection(magic int32, unitNumber int64, fastSectionCh chan Section, normalSectionCh chan Section, exitCh chan bool, randomAv bool) {
+func FastWriteRealtimeSection(collector *Collector, magic int32, unitNumber int64, fastSectionCh chan Section, normalSectionCh chan Section, exitCh chan bool, transform *ValueTransform, csvOptions CsvOptions) {
+	recorder := collector.NewRecorder()
+	fastStamper, normalStamper := csvOptions.NewStamper(), csvOptions.NewStamper()
 	fastClose := false
 	normalClose := false
 	for {
@@ -858,29 +978,35 @@ func FastWriteRealtimeSection(magic int32, unitNumber int64, fastSectionCh chan
 				}
 				continue
 			}
+			section = fastStamper.StampSection(section)
+			section = transform.Section(magic, unitNumber, section, true, true)
+			var analogErrs, digitalErrs []error
 			wt1 := time.Now()
 			if section.analogOk {
-				GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, true, randomAv)
+				analogErrs = GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, true)
 			}
 			wt2 := time.Now()
 			if section.digitalOk {
-				GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, true)
+				digitalErrs = GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, true)
 			}
 			wt3 := time.Now()
 
-			FastAnalogWriteSectionInfoList = append(FastAnalogWriteSectionInfoList, WriteSectionInfo{
+			recorder.Fast.Record(WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.analog.Time,
+				Start:        wt1,
 				Duration:     wt2.Sub(wt1),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.analog.Data)),
-			})
-			FastDigitalWriteSectionInfoList = append(FastDigitalWriteSectionInfoList, WriteSectionInfo{
+				PNumCount:    section.analog.PNumCount(),
+				Errors:       analogErrs,
+			}, WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.digital.Time,
+				Start:        wt2,
 				Duration:     wt3.Sub(wt2),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.digital.Data)),
+				PNumCount:    section.digital.PNumCount(),
+				Errors:       digitalErrs,
 			})
 		case section, ok := <-normalSectionCh:
 			if !ok {
@@ -890,36 +1016,44 @@ func FastWriteRealtimeSection(magic int32, unitNumber int64, fastSectionCh chan
 				}
 				continue
 			}
+			section = normalStamper.StampSection(section)
+			section = transform.Section(magic, unitNumber, section, true, false)
+			var analogErrs, digitalErrs []error
 			wt1 := time.Now()
 			if section.analogOk {
-				GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, false, randomAv)
+				analogErrs = GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, false)
 			}
 			wt2 := time.Now()
 			if section.digitalOk {
-				GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, false)
+				digitalErrs = GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, false)
 			}
 			wt3 := time.Now()
 
-			NormalAnalogWriteSectionInfoList = append(NormalAnalogWriteSectionInfoList, WriteSectionInfo{
+			recorder.Normal.Record(WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.analog.Time,
+				Start:        wt1,
 				Duration:     wt2.Sub(wt1),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.analog.Data)),
-			})
-			NormalDigitalWriteSectionInfoList = append(NormalDigitalWriteSectionInfoList, WriteSectionInfo{
+				PNumCount:    section.analog.PNumCount(),
+				Errors:       analogErrs,
+			}, WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.digital.Time,
+				Start:        wt2,
 				Duration:     wt3.Sub(wt2),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.digital.Data)),
+				PNumCount:    section.digital.PNumCount(),
+				Errors:       digitalErrs,
 			})
 		}
 	}
 }
 
 // FastWriteHisSection 极速写入历史断面
-func FastWriteHisSection(magic int32, unitNumber int64, sectionCh chan Section, exitCh chan bool, randomAv bool) {
+func FastWriteHisSection(collector *Collector, magic int32, unitNumber int64, sectionCh chan Section, exitCh chan bool, transform *ValueTransform, csvOptions CsvOptions) {
+	recorder := collector.NewRecorder()
+	stamper := csvOptions.NewStamper()
 	for {
 		select {
 		case <-exitCh:
@@ -933,28 +1067,34 @@ func FastWriteHisSection(magic int32, unitNumber int64, sectionCh chan Section,
 			if !ok {
 				return
 			}
+			section = stamper.StampSection(section)
+			section = transform.Section(magic, unitNumber, section, false, false)
+			var analogErrs, digitalErrs []error
 			wt1 := time.Now()
 			if section.analogOk {
-				GlobalPlugin.WriteHisAnalog(magic, unitNumber, section.analog, randomAv)
+				analogErrs = GlobalPlugin.WriteHisAnalog(magic, unitNumber, section.analog)
 			}
 			wt2 := time.Now()
 			if section.digitalOk {
-				GlobalPlugin.WriteHisDigital(magic, unitNumber, section.digital)
+				digitalErrs = GlobalPlugin.WriteHisDigital(magic, unitNumber, section.digital)
 			}
 			wt3 := time.Now()
-			NormalAnalogWriteSectionInfoList = append(NormalAnalogWriteSectionInfoList, WriteSectionInfo{
+			recorder.Normal.Record(WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.analog.Time,
+				Start:        wt1,
 				Duration:     wt2.Sub(wt1),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.analog.Data)),
-			})
-			NormalDigitalWriteSectionInfoList = append(NormalDigitalWriteSectionInfoList, WriteSectionInfo{
+				PNumCount:    section.analog.PNumCount(),
+				Errors:       analogErrs,
+			}, WriteSectionInfo{
 				UnitNumber:   unitNumber,
 				Time:         section.digital.Time,
+				Start:        wt2,
 				Duration:     wt3.Sub(wt2),
 				SectionCount: 1,
-				PNumCount:    int64(len(section.digital.Data)),
+				PNumCount:    section.digital.PNumCount(),
+				Errors:       digitalErrs,
 			})
 		}
 	}
@@ -965,8 +1105,11 @@ func FastWriteHisSection(magic int32, unitNumber int64, sectionCh chan Section,
 // overloadProtectionWriteDuration 过载保护持续时间, 单位毫秒
 // overloadProtectionWritePeriodic 过载保护写入周期, 单位毫秒
 // regularWritePeriodic 常规写入周期, 单位毫秒
-// 返回值: 总时间, 写入时间, 睡眠时间
+// fastCacheBatchSize 开启快采点缓存时, 每次批量写入的断面数量
+// schedule 调度参数, 按绝对时间调度, 写入耗时超出写入周期时按追赶策略处理
+// csvOptions now和rebase模式下写入时修改断面的时间戳
 func AsyncPeriodicWriteSection(
+	collector *Collector,
 	magic int32,
 	unitNumber int64,
 	wg *sync.WaitGroup,
@@ -977,14 +1120,33 @@ func AsyncPeriodicWriteSection(
 	isRt bool,
 	isFast bool,
 	fastCache bool,
+	fastCacheBatchSize int,
 	exitCh chan bool,
-	randomAv bool,
+	transform *ValueTransform,
+	schedule ScheduleOptions,
+	csvOptions CsvOptions,
 ) {
 	defer func() {
 		wg.Done()
 	}()
+	recorder := collector.NewRecorder()
+	stats := recorder.Normal
+	if isFast {
+		stats = recorder.Fast
+	}
+
+	// 只有实时快采点支持批量写入, 其他情况下合并写入退化为连续写入
+	supportList := isRt && isFast && GlobalPlugin.Info().SupportList
+	if schedule.CatchUp == CatchUpCoalesce && !supportList {
+		log.Println("只有实时快采点并且插件支持批量写入时才能合并写入, 追赶策略coalesce按burst处理")
+		schedule.CatchUp = CatchUpBurst
+	}
+	scheduler := NewScheduler(schedule)
+	stamper := csvOptions.NewStamper()
 
 	sum := 0
+	batchSize := GlobalPlugin.BatchSize(fastCacheBatchSize)
+	count := 1 // 本次写入的周期数, 大于1表示合并了错过的周期
 	for {
 		select {
 		case <-exitCh:
@@ -995,214 +1157,192 @@ func AsyncPeriodicWriteSection(
 				}
 			}
 		default:
+			// 本次写入的周期和断面数量, 过载保护期间使用过载保护写入周期
+			period := time.Duration(regularWritePeriodic) * time.Millisecond
+			size := count
 			if fastCache {
-				analogList := make([]AnalogSection, 0)
-				digitalList := make([]DigitalSection, 0)
-				isEOF := false
-				for {
-					section, ok := <-sectionCh
-					if !ok {
-						isEOF = true
-						break
-					}
-					if section.analogOk {
-						analogList = append(analogList, section.analog)
-					}
-					if section.digitalOk {
-						digitalList = append(digitalList, section.digital)
-					}
-					if len(analogList) == 100 || len(digitalList) == 100 {
-						break
-					}
-				}
-
-				duration := time.Duration(0)
-				if len(analogList) != 0 || len(digitalList) != 0 {
-					t1 := time.Now()
-					if len(analogList) != 0 {
-						GlobalPlugin.WriteRtAnalogList(magic, unitNumber, analogList, randomAv)
-					}
-					t2 := time.Now()
-					if len(digitalList) != 0 {
-						GlobalPlugin.WriteRtDigitalList(magic, unitNumber, digitalList)
-					}
-					t3 := time.Now()
-					duration = t3.Sub(t1)
-
-					aPCount := 0
-					for _, analog := range analogList {
-						aPCount = aPCount + len(analog.Data)
-					}
-					dPCount := 0
-					for _, digital := range digitalList {
-						dPCount = dPCount + len(digital.Data)
-					}
-					FastAnalogWriteSectionInfoList = append(FastAnalogWriteSectionInfoList, WriteSectionInfo{
-						UnitNumber:   unitNumber,
-						Time:         analogList[0].Time,
-						Duration:     t2.Sub(t1),
-						SectionCount: int64(len(analogList)),
-						PNumCount:    int64(aPCount),
-					})
-					FastDigitalWriteSectionInfoList = append(FastDigitalWriteSectionInfoList, WriteSectionInfo{
-						UnitNumber:   unitNumber,
-						Time:         analogList[0].Time,
-						Duration:     t3.Sub(t2),
-						SectionCount: int64(len(digitalList)),
-						PNumCount:    int64(dPCount),
-					})
-				}
+				period *= time.Duration(batchSize)
+				size = GlobalPlugin.BatchSize(batchSize * count)
+			} else if sum < overloadProtectionWriteDuration {
+				sum += overloadProtectionWritePeriodic
+				period = time.Duration(overloadProtectionWritePeriodic) * time.Millisecond
+			}
 
-				// 全部写完, 退出循环
-				if isEOF {
-					return
-				}
+			// 等待到计划时间
+			intended, sleepDuration := scheduler.Wait()
+			if sleepDuration > 0 {
+				stats.RecordSleep(sleepDuration)
+			}
 
-				// 睡眠
-				if duration < time.Duration(regularWritePeriodic)*time.Millisecond*100 {
-					sleepDuration := time.Duration(regularWritePeriodic)*time.Millisecond*100 - duration
-					if isFast {
-						FastSleepDurationList = append(FastSleepDurationList, sleepDuration)
-					} else {
-						NormalSleepDurationList = append(NormalSleepDurationList, sleepDuration)
-					}
-					time.Sleep(sleepDuration)
-				}
-			} else {
-				// 写入数据
-				start := time.Now()
+			// 写入数据
+			start := time.Now()
+			sections := make([]Section, 0, size)
+			isEOF := false
+			for len(sections) < size {
 				section, ok := <-sectionCh
 				if !ok {
-					return
+					isEOF = true
+					break
 				}
-				if isRt {
-					wt1 := time.Now()
-					if section.analogOk {
-						GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, isFast, randomAv)
-					}
-					wt2 := time.Now()
-					if section.digitalOk {
-						GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, isFast)
-					}
-					wt3 := time.Now()
-					if isFast {
-						FastAnalogWriteSectionInfoList = append(FastAnalogWriteSectionInfoList, WriteSectionInfo{
-							UnitNumber:   unitNumber,
-							Time:         section.analog.Time,
-							Duration:     wt2.Sub(wt1),
-							SectionCount: 1,
-							PNumCount:    int64(len(section.analog.Data)),
-						})
-						FastDigitalWriteSectionInfoList = append(FastDigitalWriteSectionInfoList, WriteSectionInfo{
-							UnitNumber:   unitNumber,
-							Time:         section.digital.Time,
-							Duration:     wt3.Sub(wt2),
-							SectionCount: 1,
-							PNumCount:    int64(len(section.digital.Data)),
-						})
-					} else {
-						NormalAnalogWriteSectionInfoList = append(NormalAnalogWriteSectionInfoList, WriteSectionInfo{
-							UnitNumber:   unitNumber,
-							Time:         section.analog.Time,
-							Duration:     wt2.Sub(wt1),
-							SectionCount: 1,
-							PNumCount:    int64(len(section.analog.Data)),
-						})
-						NormalDigitalWriteSectionInfoList = append(NormalDigitalWriteSectionInfoList, WriteSectionInfo{
-							UnitNumber:   unitNumber,
-							Time:         section.digital.Time,
-							Duration:     wt3.Sub(wt2),
-							SectionCount: 1,
-							PNumCount:    int64(len(section.digital.Data)),
-						})
-					}
+				sections = append(sections, section)
+			}
+			if len(sections) != 0 {
+				stamper.Stamp(sections)
+				if fastCache || len(sections) > 1 {
+					WritePeriodicSectionList(stats, magic, unitNumber, sections, transform, intended)
 				} else {
-					wt1 := time.Now()
-					if section.analogOk {
-						GlobalPlugin.WriteHisAnalog(magic, unitNumber, section.analog, randomAv)
-					}
-					wt2 := time.Now()
-					if section.digitalOk {
-						GlobalPlugin.WriteHisDigital(magic, unitNumber, section.digital)
-					}
-					wt3 := time.Now()
-
-					NormalAnalogWriteSectionInfoList = append(NormalAnalogWriteSectionInfoList, WriteSectionInfo{
-						UnitNumber:   unitNumber,
-						Time:         section.analog.Time,
-						Duration:     wt2.Sub(wt1),
-						SectionCount: 1,
-						PNumCount:    int64(len(section.analog.Data)),
-					})
-					NormalDigitalWriteSectionInfoList = append(NormalDigitalWriteSectionInfoList, WriteSectionInfo{
-						UnitNumber:   unitNumber,
-						Time:         section.digital.Time,
-						Duration:     wt3.Sub(wt2),
-						SectionCount: 1,
-						PNumCount:    int64(len(section.digital.Data)),
-					})
+					WritePeriodicSection(stats, magic, unitNumber, sections[0], isRt, isFast, transform, intended)
 				}
+			}
 
-				duration := time.Now().Sub(start)
-
-				// 睡眠剩余时间
-				if sum < overloadProtectionWriteDuration {
-					sum += overloadProtectionWritePeriodic
+			// 全部写完, 退出循环
+			if isEOF {
+				return
+			}
 
-					if duration < time.Duration(overloadProtectionWritePeriodic)*time.Millisecond {
-						sleepDuration := time.Duration(overloadProtectionWritePeriodic)*time.Millisecond - duration
-						if isFast {
-							FastSleepDurationList = append(FastSleepDurationList, sleepDuration)
-						} else {
-							NormalSleepDurationList = append(NormalSleepDurationList, sleepDuration)
-						}
-						time.Sleep(sleepDuration)
-					}
-				} else {
-					if duration < time.Duration(regularWritePeriodic)*time.Millisecond {
-						sleepDuration := time.Duration(regularWritePeriodic)*time.Millisecond - duration
-						if isFast {
-							FastSleepDurationList = append(FastSleepDurationList, sleepDuration)
-						} else {
-							NormalSleepDurationList = append(NormalSleepDurationList, sleepDuration)
-						}
-						time.Sleep(sleepDuration)
-					}
-				}
+			duration := time.Since(start)
+			stats.RecordSchedule(intended, start, duration, period)
+			var skipped int
+			count, skipped = scheduler.Advance(period)
+			if count > 1 || skipped > 0 {
+				stats.RecordCatchUp(skipped, count-1)
 			}
 		}
 	}
 }
 
+// WritePeriodicSection 周期性写入一个断面
+func WritePeriodicSection(stats *WriteStats, magic int32, unitNumber int64, section Section, isRt bool, isFast bool, transform *ValueTransform, intended time.Time) {
+	section = transform.Section(magic, unitNumber, section, isRt, isFast)
+	var analogErrs, digitalErrs []error
+	wt1 := time.Now()
+	if section.analogOk {
+		if isRt {
+			analogErrs = GlobalPlugin.WriteRtAnalog(magic, unitNumber, section.analog, isFast)
+		} else {
+			analogErrs = GlobalPlugin.WriteHisAnalog(magic, unitNumber, section.analog)
+		}
+	}
+	wt2 := time.Now()
+	if section.digitalOk {
+		if isRt {
+			digitalErrs = GlobalPlugin.WriteRtDigital(magic, unitNumber, section.digital, isFast)
+		} else {
+			digitalErrs = GlobalPlugin.WriteHisDigital(magic, unitNumber, section.digital)
+		}
+	}
+	wt3 := time.Now()
+
+	stats.Record(WriteSectionInfo{
+		UnitNumber:   unitNumber,
+		Time:         section.analog.Time,
+		Start:        wt1,
+		Intended:     intended,
+		Duration:     wt2.Sub(wt1),
+		SectionCount: 1,
+		PNumCount:    section.analog.PNumCount(),
+		Errors:       analogErrs,
+	}, WriteSectionInfo{
+		UnitNumber:   unitNumber,
+		Time:         section.digital.Time,
+		Start:        wt2,
+		Intended:     intended,
+		Duration:     wt3.Sub(wt2),
+		SectionCount: 1,
+		PNumCount:    section.digital.PNumCount(),
+		Errors:       digitalErrs,
+	})
+}
+
+// WritePeriodicSectionList 批量写入多个实时快采点断面, 用于快采点缓存和合并写入
+func WritePeriodicSectionList(stats *WriteStats, magic int32, unitNumber int64, sections []Section, transform *ValueTransform, intended time.Time) {
+	analogList := make([]AnalogSection, 0)
+	digitalList := make([]DigitalSection, 0)
+	for _, section := range sections {
+		section = transform.Section(magic, unitNumber, section, true, true)
+		if section.analogOk {
+			analogList = append(analogList, section.analog)
+		}
+		if section.digitalOk {
+			digitalList = append(digitalList, section.digital)
+		}
+	}
+
+	var analogErrs, digitalErrs []error
+	t1 := time.Now()
+	if len(analogList) != 0 {
+		analogErrs = GlobalPlugin.WriteRtAnalogList(magic, unitNumber, analogList)
+	}
+	t2 := time.Now()
+	if len(digitalList) != 0 {
+		digitalErrs = GlobalPlugin.WriteRtDigitalList(magic, unitNumber, digitalList)
+	}
+	t3 := time.Now()
+
+	aPCount := 0
+	for _, analog := range analogList {
+		aPCount = aPCount + int(analog.PNumCount())
+	}
+	dPCount := 0
+	for _, digital := range digitalList {
+		dPCount = dPCount + int(digital.PNumCount())
+	}
+	stats.Record(WriteSectionInfo{
+		UnitNumber:   unitNumber,
+		Time:         sections[0].analog.Time,
+		Start:        t1,
+		Intended:     intended,
+		Duration:     t2.Sub(t1),
+		SectionCount: int64(len(analogList)),
+		PNumCount:    int64(aPCount),
+		Errors:       analogErrs,
+	}, WriteSectionInfo{
+		UnitNumber:   unitNumber,
+		Time:         sections[0].analog.Time,
+		Start:        t2,
+		Intended:     intended,
+		Duration:     t3.Sub(t2),
+		SectionCount: int64(len(digitalList)),
+		PNumCount:    int64(dPCount),
+		Errors:       digitalErrs,
+	})
+}
+
 // StaticWrite 静态写入
-func StaticWrite(magic int32, unitNumber int64, analogPath string, digitalPath string, typ int64) {
+func StaticWrite(collector *Collector, magic int32, unitNumber int64, analogPath string, digitalPath string, typ int64) {
+	recorder := collector.NewRecorder()
 	t1 := time.Now()
-	analogSection := ReadStaticAnalogCsv(analogPath)
-	GlobalPlugin.WriteStaticAnalog(magic, unitNumber, analogSection, typ)
+	analogSection, analogSkipped := ReadStaticAnalogCsv(analogPath)
+	analogErrs := GlobalPlugin.WriteStaticAnalog(magic, unitNumber, analogSection, typ)
 	t2 := time.Now()
-	digitalSection := ReadStaticDigitalCsv(digitalPath)
-	GlobalPlugin.WriteStaticDigital(magic, unitNumber, digitalSection, typ)
+	digitalSection, digitalSkipped := ReadStaticDigitalCsv(digitalPath)
+	digitalErrs := GlobalPlugin.WriteStaticDigital(magic, unitNumber, digitalSection, typ)
 	t3 := time.Now()
-	FastAnalogWriteSectionInfoList = append(FastAnalogWriteSectionInfoList, WriteSectionInfo{
+	recorder.Fast.Skip(analogSkipped + digitalSkipped)
+	recorder.Fast.Record(WriteSectionInfo{
 		UnitNumber:   unitNumber,
 		Time:         -1,
+		Start:        t1,
 		Duration:     t2.Sub(t1),
 		SectionCount: 1,
 		PNumCount:    int64(len(analogSection.Data)),
-	})
-	FastDigitalWriteSectionInfoList = append(FastDigitalWriteSectionInfoList, WriteSectionInfo{
+		Errors:       analogErrs,
+	}, WriteSectionInfo{
 		UnitNumber:   unitNumber,
 		Time:         -1,
+		Start:        t2,
 		Duration:     t3.Sub(t2),
 		SectionCount: 1,
 		PNumCount:    int64(len(digitalSection.Data)),
+		Errors:       digitalErrs,
 	})
 }
 
-func FastWriteRtOnlyFast(magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, randomAv bool) {
+func FastWriteRtOnlyFast(collector *Collector, magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, transform *ValueTransform, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	go func() {
@@ -1218,19 +1358,20 @@ func FastWriteRtOnlyFast(magic int32, unitNumber int64, fastAnalogCsvPath string
 	close(normalSectionCh)
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
-	go ReadCsv(wg, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1)
+	go ReadCsv(wg, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1, collector.Input("fast", "快采点", fastSectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2 * time.Second)
 
-	FastWriteRealtimeSection(magic, unitNumber, fastSectionCh, normalSectionCh, done, randomAv)
+	FastWriteRealtimeSection(collector, magic, unitNumber, fastSectionCh, normalSectionCh, done, transform, csvOptions)
 	wg.Wait()
 }
 
-func FastWriteRtOnlyNormal(magic int32, unitNumber int64, normalAnalogCsvPath string, normalDigitalCsvPath string, randomAv bool) {
+func FastWriteRtOnlyNormal(collector *Collector, magic int32, unitNumber int64, normalAnalogCsvPath string, normalDigitalCsvPath string, transform *ValueTransform, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	go func() {
@@ -1246,33 +1387,34 @@ func FastWriteRtOnlyNormal(magic int32, unitNumber int64, normalAnalogCsvPath st
 	normalSectionCh := make(chan Section, CacheSize)
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
-	go ReadCsv(wg, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd1)
+	go ReadCsv(wg, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd1, collector.Input("normal", "普通点", normalSectionCh), csvOptions)
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2 * time.Second)
 
-	FastWriteRealtimeSection(magic, unitNumber, fastSectionCh, normalSectionCh, done, randomAv)
+	FastWriteRealtimeSection(collector, magic, unitNumber, fastSectionCh, normalSectionCh, done, transform, csvOptions)
 	wg.Wait()
 }
 
-func ParallelFastWriteRt(magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, randomAv bool) {
+func ParallelFastWriteRt(collector *Collector, magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, transform *ValueTransform, csvOptions CsvOptions) {
 	wg := new(sync.WaitGroup)
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		FastWriteRtOnlyFast(magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, randomAv)
+		FastWriteRtOnlyFast(collector, magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, transform, csvOptions)
 	}()
 	go func() {
 		defer wg.Done()
-		FastWriteRtOnlyNormal(magic, unitNumber, normalAnalogCsvPath, normalDigitalCsvPath, randomAv)
+		FastWriteRtOnlyNormal(collector, magic, unitNumber, normalAnalogCsvPath, normalDigitalCsvPath, transform, csvOptions)
 	}()
 	wg.Wait()
 }
 
 // FastWriteRt 极速写入实时值
-func FastWriteRt(magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, randomAv bool) {
+func FastWriteRt(collector *Collector, magic int32, unitNumber int64, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, transform *ValueTransform, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	rd2 := make(chan bool, 1)
@@ -1289,19 +1431,20 @@ func FastWriteRt(magic int32, unitNumber int64, fastAnalogCsvPath string, fastDi
 	normalSectionCh := make(chan Section, CacheSize)
 	wg := new(sync.WaitGroup)
 	wg.Add(2)
-	go ReadCsv(wg, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1)
-	go ReadCsv(wg, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd2)
+	go ReadCsv(wg, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1, collector.Input("fast", "快采点", fastSectionCh), csvOptions)
+	go ReadCsv(wg, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd2, collector.Input("normal", "普通点", normalSectionCh), csvOptions)
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2 * time.Second)
 
-	FastWriteRealtimeSection(magic, unitNumber, fastSectionCh, normalSectionCh, done, randomAv)
+	FastWriteRealtimeSection(collector, magic, unitNumber, fastSectionCh, normalSectionCh, done, transform, csvOptions)
 	wg.Wait()
 }
 
-func PeriodicWriteRtOnlyFast(magic int32, unitNumber int64, overloadProtectionFlag bool, fastAnalogCsvPath string, fastDigitalCsvPath string, fastCache bool, randomAv bool) {
+func PeriodicWriteRtOnlyFast(collector *Collector, magic int32, unitNumber int64, overloadProtectionFlag bool, fastAnalogCsvPath string, fastDigitalCsvPath string, fastCache bool, transform *ValueTransform, config PeriodicConfig, schedule ScheduleOptions, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done1 := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	go func() {
@@ -1310,28 +1453,29 @@ func PeriodicWriteRtOnlyFast(magic int32, unitNumber int64, overloadProtectionFl
 		rd1 <- true
 	}()
 
-	fastSectionCh := make(chan Section, CacheSize)
+	fastSectionCh := make(chan Section, config.CacheSize)
 	wgRead := new(sync.WaitGroup)
 	wgRead.Add(1)
-	go ReadCsv(wgRead, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1)
+	go ReadCsv(wgRead, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1, collector.Input("fast", "快采点", fastSectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2000 * time.Millisecond)
 	wgWrite := new(sync.WaitGroup)
 	wgWrite.Add(1)
 	if overloadProtectionFlag {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, done1, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, config.FastCacheBatchSize, done1, transform, schedule, csvOptions)
 	} else {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, done1, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, config.FastCacheBatchSize, done1, transform, schedule, csvOptions)
 	}
 	wgWrite.Wait()
 	wgRead.Wait()
 }
 
-func PeriodicWriteRtOnlyNormal(magic int32, unitNumber int64, overloadProtectionFlag bool, normalAnalogCsvPath string, normalDigitalCsvPath string, fastCache bool, randomAv bool) {
+func PeriodicWriteRtOnlyNormal(collector *Collector, magic int32, unitNumber int64, overloadProtectionFlag bool, normalAnalogCsvPath string, normalDigitalCsvPath string, fastCache bool, transform *ValueTransform, config PeriodicConfig, schedule ScheduleOptions, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done1 := make(chan bool, 1)
 	done2 := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
@@ -1342,29 +1486,30 @@ func PeriodicWriteRtOnlyNormal(magic int32, unitNumber int64, overloadProtection
 		rd1 <- true
 	}()
 
-	normalSectionCh := make(chan Section, CacheSize)
+	normalSectionCh := make(chan Section, config.CacheSize)
 	wgRead := new(sync.WaitGroup)
 	wgRead.Add(1)
-	go ReadCsv(wgRead, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd1)
+	go ReadCsv(wgRead, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd1, collector.Input("normal", "普通点", normalSectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2000 * time.Millisecond)
 	wgWrite := new(sync.WaitGroup)
 	wgWrite.Add(1)
 	if overloadProtectionFlag {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, OverloadProtectionWriteDuration, OverloadProtectionWritePeriodic, NormalRegularWritePeriodic, normalSectionCh, true, false, false, done2, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, config.OverloadProtectionWriteDuration, config.OverloadProtectionWritePeriodic, config.NormalRegularWritePeriodic, normalSectionCh, true, false, false, 0, done2, transform, schedule, csvOptions)
 	} else {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, NormalRegularWritePeriodic, normalSectionCh, true, false, false, done2, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.NormalRegularWritePeriodic, normalSectionCh, true, false, false, 0, done2, transform, schedule, csvOptions)
 	}
 	wgWrite.Wait()
 	wgRead.Wait()
 }
 
 // PeriodicWriteRt 周期性写入实时值
-func PeriodicWriteRt(magic int32, unitNumber int64, overloadProtectionFlag bool, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, fastCache bool, randomAv bool) {
+func PeriodicWriteRt(collector *Collector, magic int32, unitNumber int64, overloadProtectionFlag bool, fastAnalogCsvPath string, fastDigitalCsvPath string, normalAnalogCsvPath string, normalDigitalCsvPath string, fastCache bool, transform *ValueTransform, config PeriodicConfig, schedule ScheduleOptions, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done1 := make(chan bool, 1)
 	done2 := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
@@ -1377,33 +1522,34 @@ func PeriodicWriteRt(magic int32, unitNumber int64, overloadProtectionFlag bool,
 		rd2 <- true
 	}()
 
-	fastSectionCh := make(chan Section, CacheSize)
-	normalSectionCh := make(chan Section, CacheSize)
+	fastSectionCh := make(chan Section, config.CacheSize)
+	normalSectionCh := make(chan Section, config.CacheSize)
 	wgRead := new(sync.WaitGroup)
 	wgRead.Add(2)
-	go ReadCsv(wgRead, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1)
-	go ReadCsv(wgRead, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd2)
+	go ReadCsv(wgRead, fastAnalogCsvPath, fastDigitalCsvPath, fastSectionCh, rd1, collector.Input("fast", "快采点", fastSectionCh), csvOptions)
+	go ReadCsv(wgRead, normalAnalogCsvPath, normalDigitalCsvPath, normalSectionCh, rd2, collector.Input("normal", "普通点", normalSectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2000 * time.Millisecond)
 	wgWrite := new(sync.WaitGroup)
 	wgWrite.Add(2)
 	if overloadProtectionFlag {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, done1, randomAv)
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, OverloadProtectionWriteDuration, OverloadProtectionWritePeriodic, NormalRegularWritePeriodic, normalSectionCh, true, false, false, done2, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, config.FastCacheBatchSize, done1, transform, schedule, csvOptions)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, config.OverloadProtectionWriteDuration, config.OverloadProtectionWritePeriodic, config.NormalRegularWritePeriodic, normalSectionCh, true, false, false, 0, done2, transform, schedule, csvOptions)
 	} else {
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, done1, randomAv)
-		go AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, NormalRegularWritePeriodic, normalSectionCh, true, false, false, done2, randomAv)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.FastRegularWritePeriodic, fastSectionCh, true, true, fastCache, config.FastCacheBatchSize, done1, transform, schedule, csvOptions)
+		go AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.NormalRegularWritePeriodic, normalSectionCh, true, false, false, 0, done2, transform, schedule, csvOptions)
 	}
 	wgWrite.Wait()
 	wgRead.Wait()
 }
 
 // FastWriteHis 极速写历史
-func FastWriteHis(magic int32, unitNumber int64, analogCsvPath string, digitalCsvPath string, randomAv bool) {
+func FastWriteHis(collector *Collector, magic int32, unitNumber int64, analogCsvPath string, digitalCsvPath string, transform *ValueTransform, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	go func() {
@@ -1415,19 +1561,20 @@ func FastWriteHis(magic int32, unitNumber int64, analogCsvPath string, digitalCs
 	sectionCh := make(chan Section, CacheSize)
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
-	go ReadCsv(wg, analogCsvPath, digitalCsvPath, sectionCh, rd1)
+	go ReadCsv(wg, analogCsvPath, digitalCsvPath, sectionCh, rd1, collector.Input("normal", "历史点", sectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2000 * time.Millisecond)
-	FastWriteHisSection(magic, unitNumber, sectionCh, done, randomAv)
+	FastWriteHisSection(collector, magic, unitNumber, sectionCh, done, transform, csvOptions)
 	wg.Wait()
 }
 
 // PeriodicWriteHis 周期性写历史
-func PeriodicWriteHis(magic int32, unitNumber int64, analogCsvPath string, digitalCsvPath string, randomAv bool) {
+func PeriodicWriteHis(collector *Collector, magic int32, unitNumber int64, analogCsvPath string, digitalCsvPath string, transform *ValueTransform, config PeriodicConfig, schedule ScheduleOptions, csvOptions CsvOptions) {
 	// 平滑退出
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	NotifyStop(sigs)
+	defer StopNotify(sigs)
 	done := make(chan bool, 1)
 	rd1 := make(chan bool, 1)
 	go func() {
@@ -1436,35 +1583,21 @@ func PeriodicWriteHis(magic int32, unitNumber int64, analogCsvPath string, digit
 		rd1 <- true
 	}()
 
-	normalSectionCh := make(chan Section, CacheSize)
+	normalSectionCh := make(chan Section, config.CacheSize)
 	wgRead := new(sync.WaitGroup)
 	wgRead.Add(1)
-	go ReadCsv(wgRead, analogCsvPath, digitalCsvPath, normalSectionCh, rd1)
+	go ReadCsv(wgRead, analogCsvPath, digitalCsvPath, normalSectionCh, rd1, collector.Input("normal", "历史点", normalSectionCh), csvOptions)
 
 	// 睡眠2秒, 等待协程加载缓存
 	time.Sleep(2000 * time.Millisecond)
 
 	wgWrite := new(sync.WaitGroup)
 	wgWrite.Add(1)
-	AsyncPeriodicWriteSection(magic, unitNumber, wgWrite, 0, 0, NormalRegularWritePeriodic, normalSectionCh, false, false, false, done, randomAv)
+	AsyncPeriodicWriteSection(collector, magic, unitNumber, wgWrite, 0, 0, config.NormalRegularWritePeriodic, normalSectionCh, false, false, false, 0, done, transform, schedule, csvOptions)
 	wgWrite.Wait()
 	wgRead.Wait()
 }
 
-func RandAnalogSection(section AnalogSection) AnalogSection {
-	ss := AnalogSection{
-		Time: section.Time,
-		Data: make([]C.Analog, 0),
-	}
-	for _, d := range section.Data {
-		ss.Data = append(ss.Data, d)
-	}
-	for i := 0; i < len(ss.Data); i++ {
-		ss.Data[i].av += C.float(float32(rand.Intn(30)))
-	}
-	return ss
-}
-
 // GlobalID 拼接GlobalID
 // +-------+---------+-----------+---------+-------+-------+
 // | 32bit |  8 bit  |   1bit    |  1 bit  | 1 bit | 21bit |
@@ -1537,242 +1670,390 @@ func InitStaticDigitalGlobalID(magic int32, unitId int64, isFast bool, isRt bool
 	return ss
 }
 
+// ErrorMessageSize 插件错误信息缓冲区大小
+const ErrorMessageSize = 256
+
+// MaxLoggedWriteErrors 最多输出的写入错误日志条数, 超出部分只计入统计
+const MaxLoggedWriteErrors = 100
+
+// WriteErrorCode 插件写入接口返回的错误码, 与 plugin/write_plugin.h 中的 WRITE_ERR_* 一一对应
+type WriteErrorCode int
+
+const (
+	WriteOk             WriteErrorCode = 0 // 写入成功
+	WriteErrUnknown     WriteErrorCode = 1 // 未知错误
+	WriteErrConnection  WriteErrorCode = 2 // 连接错误
+	WriteErrTimeout     WriteErrorCode = 3 // 写入超时
+	WriteErrInvalidData WriteErrorCode = 4 // 数据错误
+	WriteErrOverload    WriteErrorCode = 5 // 数据库过载
+	WriteErrUnsupported WriteErrorCode = 6 // 插件不支持该接口
+)
+
+func (code WriteErrorCode) String() string {
+	switch code {
+	case WriteOk:
+		return "成功"
+	case WriteErrUnknown:
+		return "未知错误"
+	case WriteErrConnection:
+		return "连接错误"
+	case WriteErrTimeout:
+		return "写入超时"
+	case WriteErrInvalidData:
+		return "数据错误"
+	case WriteErrOverload:
+		return "数据库过载"
+	case WriteErrUnsupported:
+		return "接口不支持"
+	default:
+		return fmt.Sprintf("错误码%d", int(code))
+	}
+}
+
+// WriteError 插件写入失败时返回的错误
+type WriteError struct {
+	Op      string         // 插件接口名称, 如 write_rt_analog
+	UnitId  int64          // 机组ID
+	Code    WriteErrorCode // 插件返回的错误码
+	Message string         // 插件通过 last_error 返回的错误信息, 可能为空
+}
+
+func (e *WriteError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%v(unit_id: %v) 写入失败: %v", e.Op, e.UnitId, e.Code)
+	}
+	return fmt.Sprintf("%v(unit_id: %v) 写入失败: %v, %v", e.Op, e.UnitId, e.Code, e.Message)
+}
+
+// NewWriteError 根据插件返回的错误码构造错误, 写入成功时返回nil
+func NewWriteError(op string, unitId int64, code C.int, errBuf []C.char) error {
+	if code == C.WRITE_OK {
+		return nil
+	}
+	return &WriteError{
+		Op:      op,
+		UnitId:  unitId,
+		Code:    WriteErrorCode(code),
+		Message: C.GoString(&errBuf[0]),
+	}
+}
+
+// WriteErrorCodeOf 获取错误对应的错误码, 非插件返回的错误视为未知错误
+func WriteErrorCodeOf(err error) WriteErrorCode {
+	var writeErr *WriteError
+	if errors.As(err, &writeErr) {
+		return writeErr.Code
+	}
+	return WriteErrUnknown
+}
+
+var loggedWriteErrors = 0
+var loggedWriteErrorsLock = new(sync.Mutex)
+
+// CollectWriteErrors 去除写入成功(nil)的结果, 并输出错误日志
+func CollectWriteErrors(errs []error) []error {
+	var rtn []error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		rtn = append(rtn, err)
+
+		loggedWriteErrorsLock.Lock()
+		loggedWriteErrors++
+		if loggedWriteErrors <= MaxLoggedWriteErrors {
+			log.Println(err)
+		}
+		if loggedWriteErrors == MaxLoggedWriteErrors {
+			log.Printf("写入错误超过%v条, 后续错误只计入统计, 不再输出日志\n", MaxLoggedWriteErrors)
+		}
+		loggedWriteErrorsLock.Unlock()
+	}
+	return rtn
+}
+
 // WritePlugin 写入插件
-// 用于加载插件, 内部调用了 plugin/dylib.h 头文件, 这个头文件封装了C的动态库加载函数
+// 封装插件后端, 负责多机组并发写入, 初始化GlobalID, 以及插件不可重入时的串行调用; 数值模型在写入计时开始前由 ValueTransform.Section 施加
+// 按机组读取CSV时每个机组写入各自的数据, 机组在该断面没有数据时跳过
 type WritePlugin struct {
-	handle C.DYLIB_HANDLE
+	writer Writer
+	info   PluginInfo
+	lock   *sync.Mutex // 插件不可重入时用于串行调用插件接口, 可重入时为nil
+	calls  *PluginCallStats
 }
 
-func NewWritePlugin(path string) *WritePlugin {
-	return &WritePlugin{
-		handle: C.load_library(C.CString(path)),
+// NewWritePlugin 根据插件路径加载插件, 路径格式参考 NewWriter
+func NewWritePlugin(path string) (*WritePlugin, error) {
+	writer, err := NewWriter(path)
+	if err != nil {
+		return nil, err
 	}
+	return NewWritePluginFromWriter(writer), nil
 }
 
-func (df *WritePlugin) Login(param string) int {
-	if param == "" {
-		return int(C.dy_login(df.handle, nil))
-	} else {
-		cParam := C.CString(param)
-		defer C.free(unsafe.Pointer(cParam))
-		return int(C.dy_login(df.handle, cParam))
+// NewWritePluginFromWriter 使用指定的插件后端创建写入插件
+func NewWritePluginFromWriter(writer Writer) *WritePlugin {
+	plugin := &WritePlugin{writer: writer, info: writer.Info(), calls: NewPluginCallStats()}
+	if !plugin.info.Reentrant {
+		plugin.lock = new(sync.Mutex)
+	}
+	return plugin
+}
+
+// Info 插件信息
+func (df *WritePlugin) Info() PluginInfo {
+	return df.info
+}
+
+// BatchSize 根据插件支持的最大批量写入断面数量, 计算实际的批量写入断面数量
+func (df *WritePlugin) BatchSize(size int) int {
+	if df.info.MaxBatchSize > 0 && size > df.info.MaxBatchSize {
+		return df.info.MaxBatchSize
+	}
+	return size
+}
+
+// acquire 插件不可重入时, 获取插件调用锁
+func (df *WritePlugin) acquire() {
+	if df.lock != nil {
+		df.lock.Lock()
 	}
 }
 
+// release 插件不可重入时, 释放插件调用锁
+func (df *WritePlugin) release() {
+	if df.lock != nil {
+		df.lock.Unlock()
+	}
+}
+
+func (df *WritePlugin) Login(param string) int {
+	df.acquire()
+	defer df.release()
+	return df.writer.Login(param)
+}
+
 func (df *WritePlugin) Logout() {
-	C.dy_logout(df.handle)
+	df.acquire()
+	defer df.release()
+	df.writer.Logout()
 }
 
-func (df *WritePlugin) WriteRtAnalog(magic int32, unitNumber int64, section AnalogSection, isFast bool, randomAv bool) {
+func (df *WritePlugin) WriteRtAnalog(magic int32, unitNumber int64, section AnalogSection, isFast bool) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteRtAnalog(magic, 0, section, isFast, randomAv)
+		errs[0] = df.SyncWriteRtAnalog(magic, 0, section, isFast)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteRtAnalog(wg, magic, i, section, isFast, randomAv)
+			go df.AsyncWriteRtAnalog(wg, magic, i, section, isFast, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteRtDigital(magic int32, unitNumber int64, section DigitalSection, isFast bool) {
+func (df *WritePlugin) WriteRtDigital(magic int32, unitNumber int64, section DigitalSection, isFast bool) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteRtDigital(magic, 0, section, isFast)
+		errs[0] = df.SyncWriteRtDigital(magic, 0, section, isFast)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteRtDigital(wg, magic, i, section, isFast)
+			go df.AsyncWriteRtDigital(wg, magic, i, section, isFast, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteRtAnalogList(magic int32, unitNumber int64, sections []AnalogSection, randomAv bool) {
+func (df *WritePlugin) WriteRtAnalogList(magic int32, unitNumber int64, sections []AnalogSection) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteRtAnalogList(magic, 0, sections, randomAv)
+		errs[0] = df.SyncWriteRtAnalogList(magic, 0, sections)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteRtAnalogList(wg, magic, i, sections, randomAv)
+			go df.AsyncWriteRtAnalogList(wg, magic, i, sections, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteRtDigitalList(magic int32, unitNumber int64, sections []DigitalSection) {
+func (df *WritePlugin) WriteRtDigitalList(magic int32, unitNumber int64, sections []DigitalSection) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteRtDigitalList(magic, 0, sections)
+		errs[0] = df.SyncWriteRtDigitalList(magic, 0, sections)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteRtDigitalList(wg, magic, i, sections)
+			go df.AsyncWriteRtDigitalList(wg, magic, i, sections, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteHisAnalog(magic int32, unitNumber int64, section AnalogSection, randomAv bool) {
+func (df *WritePlugin) WriteHisAnalog(magic int32, unitNumber int64, section AnalogSection) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteHisAnalog(magic, 0, section, randomAv)
+		errs[0] = df.SyncWriteHisAnalog(magic, 0, section)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteHisAnalog(wg, magic, i, section, randomAv)
+			go df.AsyncWriteHisAnalog(wg, magic, i, section, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteHisDigital(magic int32, unitNumber int64, section DigitalSection) {
+func (df *WritePlugin) WriteHisDigital(magic int32, unitNumber int64, section DigitalSection) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteHisDigital(magic, 0, section)
+		errs[0] = df.SyncWriteHisDigital(magic, 0, section)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteHisDigital(wg, magic, i, section)
+			go df.AsyncWriteHisDigital(wg, magic, i, section, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteStaticAnalog(magic int32, unitNumber int64, section StaticAnalogSection, typ int64) {
+func (df *WritePlugin) WriteStaticAnalog(magic int32, unitNumber int64, section StaticAnalogSection, typ int64) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteStaticAnalog(magic, 0, section, typ)
+		errs[0] = df.SyncWriteStaticAnalog(magic, 0, section, typ)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteStaticAnalog(wg, magic, i, section, typ)
+			go df.AsyncWriteStaticAnalog(wg, magic, i, section, typ, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) WriteStaticDigital(magic int32, unitNumber int64, section StaticDigitalSection, typ int64) {
+func (df *WritePlugin) WriteStaticDigital(magic int32, unitNumber int64, section StaticDigitalSection, typ int64) []error {
+	errs := make([]error, unitNumber)
 	if unitNumber == 1 {
-		df.SyncWriteStaticDigital(magic, 0, section, typ)
+		errs[0] = df.SyncWriteStaticDigital(magic, 0, section, typ)
 	} else {
 		wg := new(sync.WaitGroup)
 		wg.Add(int(unitNumber))
 		for i := int64(0); i < unitNumber; i++ {
-			go df.AsyncWriteStaticDigital(wg, magic, i, section, typ)
+			go df.AsyncWriteStaticDigital(wg, magic, i, section, typ, &errs[i])
 		}
 		wg.Wait()
 	}
+	return CollectWriteErrors(errs)
 }
 
-func (df *WritePlugin) SyncWriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool, randomAv bool) {
-	if randomAv {
-		section = RandAnalogSection(section)
+func (df *WritePlugin) SyncWriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error {
+	section = section.Unit(unitId)
+	if len(section.Data) == 0 {
+		return nil
 	}
 	section = InitAnalogGlobalID(magic, unitId, isFast, true, section)
-	C.dy_write_rt_analog(df.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Analog)(&section.Data[0]), C.int64_t(len(section.Data)), C.bool(isFast))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteRtAnalog(magic, unitId, section, isFast)
+	df.calls.Record("write_rt_analog", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) {
+func (df *WritePlugin) SyncWriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error {
+	section = section.Unit(unitId)
+	if len(section.Data) == 0 {
+		return nil
+	}
 	section = InitDigitalGlobalID(magic, unitId, isFast, true, section)
-	C.dy_write_rt_digital(df.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Digital)(&section.Data[0]), C.int64_t(len(section.Data)), C.bool(isFast))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteRtDigital(magic, unitId, section, isFast)
+	df.calls.Record("write_rt_digital", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteRtAnalogList(magic int32, unitId int64, oldSections []AnalogSection, randomAv bool) {
+func (df *WritePlugin) SyncWriteRtAnalogList(magic int32, unitId int64, oldSections []AnalogSection) error {
 	sections := make([]AnalogSection, 0)
 	for i := 0; i < len(oldSections); i++ {
-		sections = append(sections, InitAnalogGlobalID(magic, unitId, true, true, oldSections[i]))
-	}
-	if randomAv {
-		for i := 0; i < len(sections); i++ {
-			sections[i] = RandAnalogSection(sections[i])
+		if section := oldSections[i].Unit(unitId); len(section.Data) != 0 {
+			sections = append(sections, InitAnalogGlobalID(magic, unitId, true, true, section))
 		}
 	}
-
-	// 初始化 C 数组
-	timeList := make([]C.int64_t, 0)
-	analogArrayList := make([]*C.Analog, 0)
-	countList := make([]C.int64_t, 0)
-
-	for i := range sections {
-		timeList = append(timeList, C.int64_t(sections[i].Time))
-
-		// 分配 C 内存并将 Go 数据复制到 C 内存中
-		analogData := C.malloc(C.size_t(len(sections[i].Data)) * C.size_t(unsafe.Sizeof(C.Analog{})))
-		if analogData == nil {
-			panic("C.malloc failed")
-		}
-		for j := range sections[i].Data {
-			(*[1 << 30]C.Analog)(analogData)[j] = C.Analog(sections[i].Data[j])
-		}
-		analogArrayList = append(analogArrayList, (*C.Analog)(analogData))
-		countList = append(countList, C.int64_t(len(sections[i].Data)))
+	if len(sections) == 0 {
+		return nil
 	}
 
-	// 调用 C 函数，传递结构体指针数组
-	C.dy_write_rt_analog_list(df.handle, C.int32_t(magic), C.int64_t(unitId), &timeList[0], &analogArrayList[0], &countList[0], C.int64_t(len(sections)))
-
-	// 释放 C 分配的内存
-	for i := range analogArrayList {
-		if analogArrayList[i] != nil {
-			C.free(unsafe.Pointer(analogArrayList[i]))
-		}
-	}
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteRtAnalogList(magic, unitId, sections)
+	df.calls.Record("write_rt_analog_list", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteRtDigitalList(magic int32, unitId int64, oldSections []DigitalSection) {
+func (df *WritePlugin) SyncWriteRtDigitalList(magic int32, unitId int64, oldSections []DigitalSection) error {
 	sections := make([]DigitalSection, 0)
 	for i := 0; i < len(oldSections); i++ {
-		sections = append(sections, InitDigitalGlobalID(magic, unitId, true, true, oldSections[i]))
-	}
-
-	// 初始化 C 数组
-	timeList := make([]C.int64_t, 0)
-	digitalArrayList := make([]*C.Digital, 0)
-	countList := make([]C.int64_t, 0)
-
-	for i := range sections {
-		timeList = append(timeList, C.int64_t(sections[i].Time))
-
-		// 分配 C 内存并将 Go 数据复制到 C 内存中
-		digitalData := C.malloc(C.size_t(len(sections[i].Data)) * C.size_t(unsafe.Sizeof(C.Digital{})))
-		if digitalData == nil {
-			panic("C.malloc failed")
-		}
-		for j := range sections[i].Data {
-			(*[1 << 30]C.Digital)(digitalData)[j] = C.Digital(sections[i].Data[j])
+		if section := oldSections[i].Unit(unitId); len(section.Data) != 0 {
+			sections = append(sections, InitDigitalGlobalID(magic, unitId, true, true, section))
 		}
-		digitalArrayList = append(digitalArrayList, (*C.Digital)(digitalData))
-		countList = append(countList, C.int64_t(len(sections[i].Data)))
 	}
-
-	// 调用 C 函数，传递结构体指针数组
-	C.dy_write_rt_digital_list(df.handle, C.int32_t(magic), C.int64_t(unitId), &timeList[0], &digitalArrayList[0], &countList[0], C.int64_t(len(sections)))
-
-	// 释放 C 分配的内存
-	for i := range digitalArrayList {
-		if digitalArrayList[i] != nil {
-			C.free(unsafe.Pointer(digitalArrayList[i]))
-		}
+	if len(sections) == 0 {
+		return nil
 	}
+
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteRtDigitalList(magic, unitId, sections)
+	df.calls.Record("write_rt_digital_list", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteHisAnalog(magic int32, unitId int64, section AnalogSection, randomAv bool) {
-	if randomAv {
-		section = RandAnalogSection(section)
+func (df *WritePlugin) SyncWriteHisAnalog(magic int32, unitId int64, section AnalogSection) error {
+	section = section.Unit(unitId)
+	if len(section.Data) == 0 {
+		return nil
 	}
 	section = InitAnalogGlobalID(magic, unitId, false, false, section)
-	C.dy_write_his_analog(df.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Analog)(&section.Data[0]), C.int64_t(len(section.Data)))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteHisAnalog(magic, unitId, section)
+	df.calls.Record("write_his_analog", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteHisDigital(magic int32, unitId int64, section DigitalSection) {
+func (df *WritePlugin) SyncWriteHisDigital(magic int32, unitId int64, section DigitalSection) error {
+	section = section.Unit(unitId)
+	if len(section.Data) == 0 {
+		return nil
+	}
 	section = InitDigitalGlobalID(magic, unitId, false, false, section)
-	C.dy_write_his_digital(df.handle, C.int32_t(magic), C.int64_t(unitId), C.int64_t(section.Time), (*C.Digital)(&section.Data[0]), C.int64_t(len(section.Data)))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteHisDigital(magic, unitId, section)
+	df.calls.Record("write_his_digital", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) {
+func (df *WritePlugin) SyncWriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error {
 	if typ == 0 {
 		section = InitStaticAnalogGlobalID(magic, unitId, true, true, section)
 	} else if typ == 1 {
@@ -1782,10 +2063,15 @@ func (df *WritePlugin) SyncWriteStaticAnalog(magic int32, unitId int64, section
 	} else {
 		panic("未知type: 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点")
 	}
-	C.dy_write_static_analog(df.handle, C.int32_t(magic), C.int64_t(unitId), (*C.StaticAnalog)(&section.Data[0]), C.int64_t(len(section.Data)), C.int64_t(typ))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteStaticAnalog(magic, unitId, section, typ)
+	df.calls.Record("write_static_analog", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) SyncWriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) {
+func (df *WritePlugin) SyncWriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error {
 	if typ == 0 {
 		section = InitStaticDigitalGlobalID(magic, unitId, true, true, section)
 	} else if typ == 1 {
@@ -1795,53 +2081,100 @@ func (df *WritePlugin) SyncWriteStaticDigital(magic int32, unitId int64, section
 	} else {
 		panic("未知type: 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点")
 	}
-	C.dy_write_static_digital(df.handle, C.int32_t(magic), C.int64_t(unitId), (*C.StaticDigital)(&section.Data[0]), C.int64_t(len(section.Data)), C.int64_t(typ))
+	df.acquire()
+	defer df.release()
+	start := time.Now()
+	err := df.writer.WriteStaticDigital(magic, unitId, section, typ)
+	df.calls.Record("write_static_digital", time.Since(start), err)
+	return err
 }
 
-func (df *WritePlugin) AsyncWriteRtAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section AnalogSection, isFast bool, randomAv bool) {
+func (df *WritePlugin) AsyncWriteRtAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section AnalogSection, isFast bool, err *error) {
 	defer wg.Done()
-	df.SyncWriteRtAnalog(magic, unitId, section, isFast, randomAv)
+	*err = df.SyncWriteRtAnalog(magic, unitId, section, isFast)
 }
 
-func (df *WritePlugin) AsyncWriteRtDigital(wg *sync.WaitGroup, magic int32, unitId int64, section DigitalSection, isFast bool) {
+func (df *WritePlugin) AsyncWriteRtDigital(wg *sync.WaitGroup, magic int32, unitId int64, section DigitalSection, isFast bool, err *error) {
 	defer wg.Done()
-	df.SyncWriteRtDigital(magic, unitId, section, isFast)
+	*err = df.SyncWriteRtDigital(magic, unitId, section, isFast)
 }
 
-func (df *WritePlugin) AsyncWriteRtAnalogList(wg *sync.WaitGroup, magic int32, unitId int64, sections []AnalogSection, randomAv bool) {
+func (df *WritePlugin) AsyncWriteRtAnalogList(wg *sync.WaitGroup, magic int32, unitId int64, sections []AnalogSection, err *error) {
 	defer wg.Done()
-	df.SyncWriteRtAnalogList(magic, unitId, sections, randomAv)
+	*err = df.SyncWriteRtAnalogList(magic, unitId, sections)
 }
 
-func (df *WritePlugin) AsyncWriteRtDigitalList(wg *sync.WaitGroup, magic int32, unitId int64, sections []DigitalSection) {
+func (df *WritePlugin) AsyncWriteRtDigitalList(wg *sync.WaitGroup, magic int32, unitId int64, sections []DigitalSection, err *error) {
 	defer wg.Done()
-	df.SyncWriteRtDigitalList(magic, unitId, sections)
+	*err = df.SyncWriteRtDigitalList(magic, unitId, sections)
 }
 
-func (df *WritePlugin) AsyncWriteHisAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section AnalogSection, randomAv bool) {
+func (df *WritePlugin) AsyncWriteHisAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section AnalogSection, err *error) {
 	defer wg.Done()
-	df.SyncWriteHisAnalog(magic, unitId, section, randomAv)
+	*err = df.SyncWriteHisAnalog(magic, unitId, section)
 }
 
-func (df *WritePlugin) AsyncWriteHisDigital(wg *sync.WaitGroup, magic int32, unitId int64, section DigitalSection) {
+func (df *WritePlugin) AsyncWriteHisDigital(wg *sync.WaitGroup, magic int32, unitId int64, section DigitalSection, err *error) {
 	defer wg.Done()
-	df.SyncWriteHisDigital(magic, unitId, section)
+	*err = df.SyncWriteHisDigital(magic, unitId, section)
 }
 
-func (df *WritePlugin) AsyncWriteStaticAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section StaticAnalogSection, typ int64) {
+func (df *WritePlugin) AsyncWriteStaticAnalog(wg *sync.WaitGroup, magic int32, unitId int64, section StaticAnalogSection, typ int64, err *error) {
 	defer wg.Done()
-	df.SyncWriteStaticAnalog(magic, unitId, section, typ)
+	*err = df.SyncWriteStaticAnalog(magic, unitId, section, typ)
 }
 
-func (df *WritePlugin) AsyncWriteStaticDigital(wg *sync.WaitGroup, magic int32, unitId int64, section StaticDigitalSection, typ int64) {
+func (df *WritePlugin) AsyncWriteStaticDigital(wg *sync.WaitGroup, magic int32, unitId int64, section StaticDigitalSection, typ int64, err *error) {
 	defer wg.Done()
-	df.SyncWriteStaticDigital(magic, unitId, section, typ)
+	*err = df.SyncWriteStaticDigital(magic, unitId, section, typ)
 }
 
 var GlobalPlugin *WritePlugin = nil
 
-func InitGlobalPlugin(path string) {
-	GlobalPlugin = NewWritePlugin(path)
+func InitGlobalPlugin(path string) error {
+	plugin, err := NewWritePlugin(path)
+	if err != nil {
+		return err
+	}
+	GlobalPlugin = plugin
+	log.Printf("插件加载成功: %v, %v\n", path, GlobalPlugin.Info())
+	return nil
+}
+
+// LoadPlugin 写入命令加载插件, 执行测试场景时插件已经由场景加载, 直接返回
+func LoadPlugin(path string) error {
+	if scenarioSession != nil {
+		return nil
+	}
+	return InitGlobalPlugin(path)
+}
+
+// LoginPlugin 写入命令登录插件
+// 执行测试场景时所有阶段共用一次登录, 只有param与当前登录的param不同时才重新登录
+func LoginPlugin(param string) int {
+	if scenarioSession != nil {
+		return scenarioSession.Login(param)
+	}
+	return GlobalPlugin.Login(param)
+}
+
+// FailCommand 写入命令在开始写入前失败: 输出错误, 单独执行时以非0退出;
+// 执行测试场景时直接返回, 由场景记录该阶段未完成写入, 所有阶段结束后以非0退出
+func FailCommand(v ...interface{}) {
+	log.Println(v...)
+	if scenarioSession == nil {
+		os.Exit(1)
+	}
+}
+
+// LogoutPlugin 写入命令登出插件, 返回登出耗时; 执行测试场景时由场景在所有阶段结束后登出, 返回0
+func LogoutPlugin() time.Duration {
+	if scenarioSession != nil {
+		return 0
+	}
+	start := time.Now()
+	GlobalPlugin.Logout()
+	return time.Since(start)
 }
 
 // CrFilterReader 是一个自定义的 io.Reader，用于去除数据流中的 \r 字符
@@ -1849,26 +2182,65 @@ type CrFilterReader struct {
 	reader *bufio.Reader
 }
 
+// NewCsvReader 数据集的CSV读取器, 行尾统一为'\n', 不检查每行的列数, 列数由各个解析函数检查
+func NewCsvReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(NewCRFilterReader(bufio.NewReader(r)))
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// CsvLine 出错的行号, err为reader返回的错误, 为nil时返回上一次读取的行的行号
+func CsvLine(reader *csv.Reader, err error) int {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine
+	}
+	if err != nil {
+		return 0
+	}
+	line, _ := reader.FieldPos(0)
+	return line
+}
+
 // NewCRFilterReader 返回一个包装了 bufio.Reader 的 crFilterReader
 func NewCRFilterReader(r *bufio.Reader) *CrFilterReader {
 	return &CrFilterReader{reader: r}
 }
 
-// Read 实现了 io.Reader 接口，替换数据流中的 '\r' 为 '\n'
-// 备注: 因解析CSV文件时, 发现文件格式不标准, 有的CSV文件是以 "\r\r" 作为分隔符的, 所以统一替换成 '\n'
+// Read 实现了 io.Reader 接口，将数据流中的行尾统一替换为 '\n'
+// 备注: 因解析CSV文件时, 发现文件格式不标准, 有的CSV文件是以 "\r\r" 或 "\r\r\n" 作为分隔符的, 所以统一替换成 '\n'
+// 连续的 '\r' 与其后的 '\n' 合并为一个 '\n', 保证CSV读取器的行号与文件中的物理行一致
 func (r *CrFilterReader) Read(p []byte) (int, error) {
-	n, err := r.reader.Read(p)
-	if err != nil {
-		return n, err
-	}
+	for {
+		n, err := r.reader.Read(p)
+		if err != nil {
+			return n, err
+		}
 
-	for i := 0; i < n; i++ {
-		if p[i] == '\r' {
-			p[i] = '\n'
+		w := 0
+		for i := 0; i < n; i++ {
+			c := p[i]
+			if c == '\r' {
+				next, ok := byte(0), false
+				if i+1 < n {
+					next, ok = p[i+1], true
+				} else if b, err := r.reader.Peek(1); err == nil {
+					next, ok = b[0], true
+				}
+				// 后面还有行尾字符时由后面的字符输出换行
+				if ok && (next == '\r' || next == '\n') {
+					continue
+				}
+				c = '\n'
+			}
+			p[w] = c
+			w++
+		}
+		// 读到的全部是被合并的 '\r' 时继续读取, 避免返回0字节
+		if w > 0 || n == 0 {
+			return w, nil
 		}
 	}
-
-	return n, nil
 }
 
 var rootCmd = &cobra.Command{
@@ -1898,29 +2270,134 @@ var staticWrite = &cobra.Command{
 		typ, _ := cmd.Flags().GetInt64("type")
 		param, _ := cmd.Flags().GetString("param")
 		magic, _ := cmd.Flags().GetInt32("magic")
+		histogramPrecision, _ := cmd.Flags().GetInt("histogram_precision")
+		tracePath, _ := cmd.Flags().GetString("trace")
+		metricsAddr, _ := cmd.Flags().GetString("metrics_addr")
+		if err := CheckStrictCsv(cmd, CsvOptions{}); err != nil {
+			FailCommand(err)
+			return
+		}
+
+		// 初始化写入统计
+		collector, err := NewCollector(histogramPrecision, tracePath)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		// 提前返回时也停止指标服务, 关闭写入明细输出
+		defer collector.Close()
 
 		// 加载动态库
-		InitGlobalPlugin(pluginPath)
+		if err := LoadPlugin(pluginPath); err != nil {
+			FailCommand(err)
+			return
+		}
+		if !GlobalPlugin.Info().SupportStatic {
+			FailCommand("插件不支持写静态值")
+			return
+		}
+
+		// 指标服务
+		if err := collector.ServeMetrics(metricsAddr, GlobalPlugin); err != nil {
+			FailCommand(err)
+			return
+		}
 
 		// 登入
-		if rtn := GlobalPlugin.Login(param); rtn != 0 {
-			log.Println("登陆失败: ", rtn)
+		if rtn := LoginPlugin(param); rtn != 0 {
+			FailCommand("登陆失败: ", rtn)
 			return
 		}
 		start := time.Now()
 
 		// 输出统计值
 		defer func() {
-			logoutStart := time.Now()
-			GlobalPlugin.Logout()
-			logoutDuration := time.Since(logoutStart)
+			logoutDuration := LogoutPlugin()
 
 			log.Println("logout time: ", logoutDuration)
-			StaticSummary(magic, "静态写入", start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, logoutDuration)
+			end := time.Now()
+			fast, normal := collector.Merge()
+			StaticSummary(magic, "静态写入", start, end, fast, logoutDuration)
+			WriteReport(cmd, magic, "静态写入", start, end, fast, normal, logoutDuration, false, nil)
 		}()
 
 		// 静态写入
-		StaticWrite(magic, unitNumber, staticAnalogCsvPath, staticDigitalCsvPath, typ)
+		StaticWrite(collector, magic, unitNumber, staticAnalogCsvPath, staticDigitalCsvPath, typ)
+	},
+}
+
+var runScenario = &cobra.Command{
+	Use:   "run scenario.yaml",
+	Short: "Run a multi-phase test scenario, all phases share one plugin login",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		scenario, err := LoadScenario(args[0])
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		if err := RunScenario(scenario); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var genDataset = &cobra.Command{
+	Use:   "gen",
+	Short: "Generate REALTIME_FAST, REALTIME_NORMAL, HISTORY_NORMAL analog/digital csv files and the matching STATIC csv files",
+	Run: func(cmd *cobra.Command, args []string) {
+		options, err := NewGenOptions(cmd)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		if err := Generate(options); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var validateCsv = &cobra.Command{
+	Use:   "validate [csv file or directory...]",
+	Short: "Validate csv files against the column layouts and report problems with line numbers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		maxIssues, _ := cmd.Flags().GetInt("max_issues")
+
+		if err := ValidateCsvFiles(args, maxIssues); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var pluginHost = &cobra.Command{
+	Use:   "plugin_host",
+	Short: "Load plugin in a separate process and serve it over a unix domain socket",
+	Run: func(cmd *cobra.Command, args []string) {
+		pluginPath, _ := cmd.Flags().GetString("plugin")
+		socketPath, _ := cmd.Flags().GetString("socket")
+
+		if err := ServePluginHost(pluginPath, socketPath); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stubServer = &cobra.Command{
+	Use:   "stub_server",
+	Short: "Serve the network write protocol locally and forward requests to a plugin",
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, _ := cmd.Flags().GetString("listen")
+		pluginPath, _ := cmd.Flags().GetString("plugin")
+
+		if err := ServeStub(listen, pluginPath); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	},
 }
 
@@ -1934,55 +2411,103 @@ var rtFastWrite = &cobra.Command{
 		normalAnalogCsvPath, _ := cmd.Flags().GetString("rt_normal_analog")
 		normalDigitalCsvPath, _ := cmd.Flags().GetString("rt_normal_digital")
 		unitNumber, _ := cmd.Flags().GetInt64("unit_number")
-		randomAv, _ := cmd.Flags().GetBool("random_av")
+		transform, err := NewValueTransform(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
 		param, _ := cmd.Flags().GetString("param")
 		mode, _ := cmd.Flags().GetInt64("mode")
 		magic, _ := cmd.Flags().GetInt32("magic")
+		histogramPrecision, _ := cmd.Flags().GetInt("histogram_precision")
+		tracePath, _ := cmd.Flags().GetString("trace")
+		metricsAddr, _ := cmd.Flags().GetString("metrics_addr")
+		progressInterval, _ := cmd.Flags().GetDuration("progress")
+		limit, err := NewStopLimit(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		csvOptions, err := NewCsvOptions(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		if err := CheckStrictCsv(cmd, csvOptions); err != nil {
+			FailCommand(err)
+			return
+		}
 		parallelWriting, _ := cmd.Flags().GetBool("parallel_writing")
 
+		// 初始化写入统计
+		collector, err := NewCollector(histogramPrecision, tracePath)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		// 提前返回时也停止指标服务, 关闭写入明细输出
+		defer collector.Close()
+
 		// 加载动态库
-		InitGlobalPlugin(pluginPath)
+		if err := LoadPlugin(pluginPath); err != nil {
+			FailCommand(err)
+			return
+		}
+
+		// 指标服务
+		if err := collector.ServeMetrics(metricsAddr, GlobalPlugin); err != nil {
+			FailCommand(err)
+			return
+		}
 
 		// 登入
-		if rtn := GlobalPlugin.Login(param); rtn != 0 {
-			log.Println("登陆失败: ", rtn)
+		if rtn := LoginPlugin(param); rtn != 0 {
+			FailCommand("登陆失败: ", rtn)
 			return
 		}
 		start := time.Now()
+		collector.StartStopLimit(limit)
+		collector.StartProgress(progressInterval, false)
 		defer func() {
-			logoutStart := time.Now()
-			GlobalPlugin.Logout()
-			logoutDuration := time.Since(logoutStart)
+			logoutDuration := LogoutPlugin()
 			log.Println("logout time: ", logoutDuration)
+			end := time.Now()
+			fast, normal := collector.Merge()
+			name := ""
 			if mode == 0 {
 				if parallelWriting {
-					ParallelRtFastWriteSummary(magic, "极速写入实时值(快采点,普通点并行)", start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, logoutDuration)
+					name = "极速写入实时值(快采点,普通点并行)"
+					ParallelRtFastWriteSummary(magic, name, start, end, fast, normal, logoutDuration, csvOptions)
 				} else {
-					RtFastWriteSummary(magic, "极速写入实时值(快采点,普通点串行)", start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, logoutDuration)
+					name = "极速写入实时值(快采点,普通点串行)"
+					RtFastWriteSummary(magic, name, start, end, fast, normal, logoutDuration, csvOptions)
 				}
 			} else if mode == 1 {
-				RtFastWriteSummary(magic, "极速写入实时值(只写快采点)", start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, logoutDuration)
+				name = "极速写入实时值(只写快采点)"
+				RtFastWriteSummary(magic, name, start, end, fast, normal, logoutDuration, csvOptions)
 			} else if mode == 2 {
-				RtFastWriteSummary(magic, "极速写入实时值(只写普通点)", start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, logoutDuration)
+				name = "极速写入实时值(只写普通点)"
+				RtFastWriteSummary(magic, name, start, end, fast, normal, logoutDuration, csvOptions)
 			} else {
 				panic("mode must be 0 or 1 or 2")
 			}
+			WriteReport(cmd, magic, name, start, end, fast, normal, logoutDuration, false, nil)
 		}()
 
 		// 极速写入实时值
 		if mode == 0 {
 			// 写快采 + 普通
 			if parallelWriting {
-				ParallelFastWriteRt(magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, randomAv)
+				ParallelFastWriteRt(collector, magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, transform, csvOptions)
 			} else {
-				FastWriteRt(magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, randomAv)
+				FastWriteRt(collector, magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, transform, csvOptions)
 			}
 		} else if mode == 1 {
 			// 只写快采
-			FastWriteRtOnlyFast(magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, randomAv)
+			FastWriteRtOnlyFast(collector, magic, unitNumber, fastAnalogCsvPath, fastDigitalCsvPath, transform, csvOptions)
 		} else if mode == 2 {
 			// 只写普通
-			FastWriteRtOnlyNormal(magic, unitNumber, normalAnalogCsvPath, normalDigitalCsvPath, randomAv)
+			FastWriteRtOnlyNormal(collector, magic, unitNumber, normalAnalogCsvPath, normalDigitalCsvPath, transform, csvOptions)
 		} else {
 			panic("mode must be 0 or 1 or 2")
 		}
@@ -1997,29 +2522,76 @@ var hisFastWrite = &cobra.Command{
 		analogCsvPath, _ := cmd.Flags().GetString("his_normal_analog")
 		digitalCsvPath, _ := cmd.Flags().GetString("his_normal_digital")
 		unitNumber, _ := cmd.Flags().GetInt64("unit_number")
-		randomAv, _ := cmd.Flags().GetBool("random_av")
+		transform, err := NewValueTransform(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
 		param, _ := cmd.Flags().GetString("param")
 		magic, _ := cmd.Flags().GetInt32("magic")
+		histogramPrecision, _ := cmd.Flags().GetInt("histogram_precision")
+		tracePath, _ := cmd.Flags().GetString("trace")
+		metricsAddr, _ := cmd.Flags().GetString("metrics_addr")
+		progressInterval, _ := cmd.Flags().GetDuration("progress")
+		limit, err := NewStopLimit(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		csvOptions, err := NewCsvOptions(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		if err := CheckStrictCsv(cmd, csvOptions); err != nil {
+			FailCommand(err)
+			return
+		}
+
+		// 初始化写入统计
+		collector, err := NewCollector(histogramPrecision, tracePath)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		// 提前返回时也停止指标服务, 关闭写入明细输出
+		defer collector.Close()
 
 		// 加载动态库
-		InitGlobalPlugin(pluginPath)
+		if err := LoadPlugin(pluginPath); err != nil {
+			FailCommand(err)
+			return
+		}
+		if !GlobalPlugin.Info().SupportHis {
+			FailCommand("插件不支持写历史值")
+			return
+		}
+
+		// 指标服务
+		if err := collector.ServeMetrics(metricsAddr, GlobalPlugin); err != nil {
+			FailCommand(err)
+			return
+		}
 
 		// 登入
-		if rtn := GlobalPlugin.Login(param); rtn != 0 {
-			log.Println("登陆失败: ", rtn)
+		if rtn := LoginPlugin(param); rtn != 0 {
+			FailCommand("登陆失败: ", rtn)
 			return
 		}
 		start := time.Now()
+		collector.StartStopLimit(limit)
+		collector.StartProgress(progressInterval, false)
 		defer func() {
-			logoutStart := time.Now()
-			GlobalPlugin.Logout()
-			logoutDuration := time.Since(logoutStart)
+			logoutDuration := LogoutPlugin()
 			log.Println("logout time: ", logoutDuration)
-			HisFastWriteSummary(magic, "极速写入历史值", start, time.Now(), NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, logoutDuration)
+			end := time.Now()
+			fast, normal := collector.Merge()
+			HisFastWriteSummary(magic, "极速写入历史值", start, end, normal, logoutDuration, csvOptions)
+			WriteReport(cmd, magic, "极速写入历史值", start, end, fast, normal, logoutDuration, false, nil)
 		}()
 
 		// 极速写入历史
-		FastWriteHis(magic, unitNumber, analogCsvPath, digitalCsvPath, randomAv)
+		FastWriteHis(collector, magic, unitNumber, analogCsvPath, digitalCsvPath, transform, csvOptions)
 	},
 }
 
@@ -2030,30 +2602,89 @@ var hisPeriodicWrite = &cobra.Command{
 		pluginPath, _ := cmd.Flags().GetString("plugin")
 		analogCsvPath, _ := cmd.Flags().GetString("his_normal_analog")
 		digitalCsvPath, _ := cmd.Flags().GetString("his_normal_digital")
-		randomAv, _ := cmd.Flags().GetBool("random_av")
+		transform, err := NewValueTransform(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
 		unitNumber, _ := cmd.Flags().GetInt64("unit_number")
 		param, _ := cmd.Flags().GetString("param")
 		magic, _ := cmd.Flags().GetInt32("magic")
+		histogramPrecision, _ := cmd.Flags().GetInt("histogram_precision")
+		tracePath, _ := cmd.Flags().GetString("trace")
+		metricsAddr, _ := cmd.Flags().GetString("metrics_addr")
+		catchUp, _ := cmd.Flags().GetString("catch_up")
+		spin, _ := cmd.Flags().GetDuration("spin")
+		schedule := ScheduleOptions{CatchUp: catchUp, Spin: spin}
+		if err := CheckCatchUp(catchUp); err != nil {
+			FailCommand(err)
+			return
+		}
+		config, err := LoadPeriodicConfig(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		progressInterval, _ := cmd.Flags().GetDuration("progress")
+		limit, err := NewStopLimit(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		csvOptions, err := NewCsvOptions(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		if err := CheckStrictCsv(cmd, csvOptions); err != nil {
+			FailCommand(err)
+			return
+		}
+
+		// 初始化写入统计
+		collector, err := NewCollector(histogramPrecision, tracePath)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		// 提前返回时也停止指标服务, 关闭写入明细输出
+		defer collector.Close()
 
 		// 加载动态库
-		InitGlobalPlugin(pluginPath)
+		if err := LoadPlugin(pluginPath); err != nil {
+			FailCommand(err)
+			return
+		}
+		if !GlobalPlugin.Info().SupportHis {
+			FailCommand("插件不支持写历史值")
+			return
+		}
+
+		// 指标服务
+		if err := collector.ServeMetrics(metricsAddr, GlobalPlugin); err != nil {
+			FailCommand(err)
+			return
+		}
 
 		// 登入
-		if rtn := GlobalPlugin.Login(param); rtn != 0 {
-			log.Println("登陆失败: ", rtn)
+		if rtn := LoginPlugin(param); rtn != 0 {
+			FailCommand("登陆失败: ", rtn)
 			return
 		}
 		start := time.Now()
+		collector.StartStopLimit(limit)
+		collector.StartProgress(progressInterval, false)
 		defer func() {
-			logoutStart := time.Now()
-			GlobalPlugin.Logout()
-			logoutDuration := time.Since(logoutStart)
+			logoutDuration := LogoutPlugin()
 			log.Println("logout time: ", logoutDuration)
-			PeriodicWriteHisSummary(magic, "周期性写入历史值", start, time.Now(), NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, NormalSleepDurationList, logoutDuration)
+			end := time.Now()
+			fast, normal := collector.Merge()
+			PeriodicWriteHisSummary(magic, "周期性写入历史值", start, end, normal, logoutDuration, config, csvOptions)
+			WriteReport(cmd, magic, "周期性写入历史值", start, end, fast, normal, logoutDuration, false, &config)
 		}()
 
 		// 周期性写入
-		PeriodicWriteHis(magic, unitNumber, analogCsvPath, digitalCsvPath, randomAv)
+		PeriodicWriteHis(collector, magic, unitNumber, analogCsvPath, digitalCsvPath, transform, config, schedule, csvOptions)
 	},
 }
 
@@ -2069,24 +2700,80 @@ var rtPeriodicWrite = &cobra.Command{
 		normalDigitalCsvPath, _ := cmd.Flags().GetString("rt_normal_digital")
 		unitNumber, _ := cmd.Flags().GetInt64("unit_number")
 		fastCache, _ := cmd.Flags().GetBool("fast_cache")
-		randomAv, _ := cmd.Flags().GetBool("random_av")
+		transform, err := NewValueTransform(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
 		param, _ := cmd.Flags().GetString("param")
 		mode, _ := cmd.Flags().GetInt64("mode")
 		magic, _ := cmd.Flags().GetInt32("magic")
+		histogramPrecision, _ := cmd.Flags().GetInt("histogram_precision")
+		tracePath, _ := cmd.Flags().GetString("trace")
+		metricsAddr, _ := cmd.Flags().GetString("metrics_addr")
+		catchUp, _ := cmd.Flags().GetString("catch_up")
+		spin, _ := cmd.Flags().GetDuration("spin")
+		schedule := ScheduleOptions{CatchUp: catchUp, Spin: spin}
+		if err := CheckCatchUp(catchUp); err != nil {
+			FailCommand(err)
+			return
+		}
+		config, err := LoadPeriodicConfig(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		progressInterval, _ := cmd.Flags().GetDuration("progress")
+		limit, err := NewStopLimit(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		csvOptions, err := NewCsvOptions(cmd)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		if err := CheckStrictCsv(cmd, csvOptions); err != nil {
+			FailCommand(err)
+			return
+		}
+
+		// 初始化写入统计
+		collector, err := NewCollector(histogramPrecision, tracePath)
+		if err != nil {
+			FailCommand(err)
+			return
+		}
+		// 提前返回时也停止指标服务, 关闭写入明细输出
+		defer collector.Close()
 
 		// 加载动态库
-		InitGlobalPlugin(pluginPath)
+		if err := LoadPlugin(pluginPath); err != nil {
+			FailCommand(err)
+			return
+		}
+		if fastCache && !GlobalPlugin.Info().SupportList {
+			log.Println("插件不支持批量写入, 关闭快采点缓存")
+			fastCache = false
+		}
+
+		// 指标服务
+		if err := collector.ServeMetrics(metricsAddr, GlobalPlugin); err != nil {
+			FailCommand(err)
+			return
+		}
 
 		// 登入
-		if rtn := GlobalPlugin.Login(param); rtn != 0 {
-			log.Println("登陆失败: ", rtn)
+		if rtn := LoginPlugin(param); rtn != 0 {
+			FailCommand("登陆失败: ", rtn)
 			return
 		}
 		start := time.Now()
+		collector.StartStopLimit(limit)
+		collector.StartProgress(progressInterval, fastCache)
 		defer func() {
-			logoutStart := time.Now()
-			GlobalPlugin.Logout()
-			logoutDuration := time.Since(logoutStart)
+			logoutDuration := LogoutPlugin()
 			log.Println("logout time: ", logoutDuration)
 
 			name := ""
@@ -2100,24 +2787,27 @@ var rtPeriodicWrite = &cobra.Command{
 				name = "周期性写入实时值(关闭载保护, 开启快采点缓存)"
 			}
 
+			end := time.Now()
+			fast, normal := collector.Merge()
 			if mode == 0 {
-				PeriodicWriteRtSummary(magic, name, start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, FastSleepDurationList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, NormalSleepDurationList, logoutDuration, fastCache)
+				PeriodicWriteRtSummary(magic, name, start, end, fast, normal, logoutDuration, fastCache, config, csvOptions)
 			} else if mode == 1 {
-				PeriodicWriteRtSummary(magic, name, start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, FastSleepDurationList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, NormalSleepDurationList, logoutDuration, fastCache)
+				PeriodicWriteRtSummary(magic, name, start, end, fast, normal, logoutDuration, fastCache, config, csvOptions)
 			} else if mode == 2 {
-				PeriodicWriteRtSummary(magic, name, start, time.Now(), FastAnalogWriteSectionInfoList, FastDigitalWriteSectionInfoList, FastSleepDurationList, NormalAnalogWriteSectionInfoList, NormalDigitalWriteSectionInfoList, NormalSleepDurationList, logoutDuration, fastCache)
+				PeriodicWriteRtSummary(magic, name, start, end, fast, normal, logoutDuration, fastCache, config, csvOptions)
 			} else {
 				panic("mode must be 0 or 1 or 2")
 			}
+			WriteReport(cmd, magic, name, start, end, fast, normal, logoutDuration, fastCache, &config)
 		}()
 
 		// 周期性写入
 		if mode == 0 {
-			PeriodicWriteRt(magic, unitNumber, overloadProtection, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, fastCache, randomAv)
+			PeriodicWriteRt(collector, magic, unitNumber, overloadProtection, fastAnalogCsvPath, fastDigitalCsvPath, normalAnalogCsvPath, normalDigitalCsvPath, fastCache, transform, config, schedule, csvOptions)
 		} else if mode == 1 {
-			PeriodicWriteRtOnlyFast(magic, unitNumber, overloadProtection, fastAnalogCsvPath, fastDigitalCsvPath, fastCache, randomAv)
+			PeriodicWriteRtOnlyFast(collector, magic, unitNumber, overloadProtection, fastAnalogCsvPath, fastDigitalCsvPath, fastCache, transform, config, schedule, csvOptions)
 		} else if mode == 2 {
-			PeriodicWriteRtOnlyNormal(magic, unitNumber, overloadProtection, normalAnalogCsvPath, normalDigitalCsvPath, fastCache, randomAv)
+			PeriodicWriteRtOnlyNormal(collector, magic, unitNumber, overloadProtection, normalAnalogCsvPath, normalDigitalCsvPath, fastCache, transform, config, schedule, csvOptions)
 		} else {
 			panic("mode must be 0 or 1 or 2")
 		}
@@ -2130,29 +2820,43 @@ func init() {
 	rootCmd.AddCommand(versionCmd)
 
 	rootCmd.AddCommand(staticWrite)
-	staticWrite.Flags().StringP("plugin", "", "", "plugin path")
+	staticWrite.Flags().StringP("plugin", "", "", "plugin path, mock://表示使用内置mock插件, goplugin://表示Go插件, exec://表示外部进程插件, host://表示在插件宿主进程中加载插件, http://表示网络插件")
 	staticWrite.Flags().StringP("static_analog", "", "", "static analog csv path")
 	staticWrite.Flags().StringP("static_digital", "", "", "static digital csv path")
 	staticWrite.Flags().Int64P("unit_number", "", 1, "unit number")
 	staticWrite.Flags().Int64P("type", "", 0, "0代表实时快采集点, 1代表实时普通点, 2代表历史普通点")
 	staticWrite.Flags().StringP("param", "", "", "custom param")
 	staticWrite.Flags().Int32P("magic", "", 0, "魔数, 默认为0")
+	staticWrite.Flags().StringP("report", "", "", "测试报告输出路径, 以.json结尾输出JSON格式, 以.csv结尾输出CSV格式, 为空表示不输出")
+	staticWrite.Flags().StringP("trace", "", "", "断面写入明细输出路径, 以.csv结尾输出CSV格式, 否则输出JSON Lines格式, 为空表示不输出")
+	staticWrite.Flags().Int("histogram_precision", DefaultHistogramPrecision, "耗时统计的有效数字位数, 取值范围[1,4], 越大越精确, 占用内存越多")
+	staticWrite.Flags().Bool("strict", false, "严格模式, 登录前检查所有CSV文件, 存在无法读取, 列数错误或无法解析的行时输出文件和行号并退出; 默认跳过这些行并在统计中输出跳过的行数")
+	staticWrite.Flags().String("metrics_addr", "", "Prometheus指标的监听地址, 如:9100, 写入过程中通过/metrics抓取, 为空表示不提供")
 
 	rootCmd.AddCommand(rtFastWrite)
-	rtFastWrite.Flags().StringP("plugin", "", "", "plugin path")
+	rtFastWrite.Flags().StringP("plugin", "", "", "plugin path, mock://表示使用内置mock插件, goplugin://表示Go插件, exec://表示外部进程插件, host://表示在插件宿主进程中加载插件, http://表示网络插件")
 	rtFastWrite.Flags().StringP("rt_fast_analog", "", "", "realtime fast analog csv path")
 	rtFastWrite.Flags().StringP("rt_fast_digital", "", "", "realtime fast digital csv path")
 	rtFastWrite.Flags().StringP("rt_normal_analog", "", "", "realtime normal analog csv path")
 	rtFastWrite.Flags().StringP("rt_normal_digital", "", "", "realtime normal digital csv path")
 	rtFastWrite.Flags().Int64P("unit_number", "", 1, "unit number")
 	rtFastWrite.Flags().StringP("param", "", "", "custom param")
-	rtFastWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30]的随机数浮动")
+	rtFastWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30)的随机数浮动, 等同于--value_model=uniform:0:30")
 	rtFastWrite.Flags().Int32P("magic", "", 0, "魔数, 默认为0")
 	rtFastWrite.Flags().Int64("mode", 0, "写入模式: 0表示写快采点+普通点, 1表示只写快采点, 2表示只写普通点")
 	rtFastWrite.Flags().BoolP("parallel_writing", "", false, "为true时, 快采点和普通点会分别由两个协程进行并行写入")
+	rtFastWrite.Flags().StringP("report", "", "", "测试报告输出路径, 以.json结尾输出JSON格式, 以.csv结尾输出CSV格式, 为空表示不输出")
+	rtFastWrite.Flags().StringP("trace", "", "", "断面写入明细输出路径, 以.csv结尾输出CSV格式, 否则输出JSON Lines格式, 为空表示不输出")
+	rtFastWrite.Flags().Int("histogram_precision", DefaultHistogramPrecision, "耗时统计的有效数字位数, 取值范围[1,4], 越大越精确, 占用内存越多")
+	rtFastWrite.Flags().Bool("strict", false, "严格模式, 登录前检查所有CSV文件, 存在无法读取, 列数错误或无法解析的行时输出文件和行号并退出; 默认跳过这些行并在统计中输出跳过的行数")
+	rtFastWrite.Flags().String("metrics_addr", "", "Prometheus指标的监听地址, 如:9100, 写入过程中通过/metrics抓取, 为空表示不提供")
+	rtFastWrite.Flags().Duration("progress", DefaultProgressInterval, "写入进度的输出间隔, 为0时不输出")
+	AddStopLimitFlags(rtFastWrite)
+	AddValueTransformFlags(rtFastWrite)
+	AddCsvFlags(rtFastWrite)
 
 	rootCmd.AddCommand(rtPeriodicWrite)
-	rtPeriodicWrite.Flags().StringP("plugin", "", "", "plugin path")
+	rtPeriodicWrite.Flags().StringP("plugin", "", "", "plugin path, mock://表示使用内置mock插件, goplugin://表示Go插件, exec://表示外部进程插件, host://表示在插件宿主进程中加载插件, http://表示网络插件")
 	rtPeriodicWrite.Flags().BoolP("overload_protection", "", false, "overload protection flag")
 	rtPeriodicWrite.Flags().StringP("rt_fast_analog", "", "", "realtime fast analog csv path")
 	rtPeriodicWrite.Flags().StringP("rt_fast_digital", "", "", "realtime fast digital csv path")
@@ -2160,28 +2864,77 @@ func init() {
 	rtPeriodicWrite.Flags().StringP("rt_normal_digital", "", "", "realtime normal digital csv path")
 	rtPeriodicWrite.Flags().Int64P("unit_number", "", 1, "unit number")
 	rtPeriodicWrite.Flags().BoolP("fast_cache", "", false, "fast cache")
-	rtPeriodicWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30]的随机数浮动")
+	rtPeriodicWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30)的随机数浮动, 等同于--value_model=uniform:0:30")
 	rtPeriodicWrite.Flags().StringP("param", "", "", "custom param")
 	rtPeriodicWrite.Flags().Int32P("magic", "", 0, "魔数, 默认为0")
 	rtPeriodicWrite.Flags().Int64("mode", 0, "写入模式: 0表示写快采点+普通点, 1表示只写快采点, 2表示只写普通点")
+	rtPeriodicWrite.Flags().StringP("report", "", "", "测试报告输出路径, 以.json结尾输出JSON格式, 以.csv结尾输出CSV格式, 为空表示不输出")
+	rtPeriodicWrite.Flags().StringP("trace", "", "", "断面写入明细输出路径, 以.csv结尾输出CSV格式, 否则输出JSON Lines格式, 为空表示不输出")
+	rtPeriodicWrite.Flags().Int("histogram_precision", DefaultHistogramPrecision, "耗时统计的有效数字位数, 取值范围[1,4], 越大越精确, 占用内存越多")
+	rtPeriodicWrite.Flags().String("catch_up", DefaultCatchUp, "写入耗时超出写入周期时的追赶策略: burst表示连续写入直到追上计划时间, skip表示跳过错过的周期, coalesce表示将错过的周期合并为一次批量写入(只支持实时快采点)")
+	rtPeriodicWrite.Flags().Duration("spin", 0, "距离计划写入时间不超过该时长时忙等而不是睡眠, 用于亚毫秒级的调度精度, 为0时不忙等")
+	rtPeriodicWrite.Flags().Bool("strict", false, "严格模式, 登录前检查所有CSV文件, 存在无法读取, 列数错误或无法解析的行时输出文件和行号并退出; 默认跳过这些行并在统计中输出跳过的行数")
+	rtPeriodicWrite.Flags().String("metrics_addr", "", "Prometheus指标的监听地址, 如:9100, 写入过程中通过/metrics抓取, 为空表示不提供")
+	rtPeriodicWrite.Flags().Duration("progress", DefaultProgressInterval, "写入进度的输出间隔, 为0时不输出")
+	AddStopLimitFlags(rtPeriodicWrite)
+	AddValueTransformFlags(rtPeriodicWrite)
+	AddCsvFlags(rtPeriodicWrite)
+	AddPeriodicConfigFlags(rtPeriodicWrite, true)
 
 	rootCmd.AddCommand(hisFastWrite)
-	hisFastWrite.Flags().StringP("plugin", "", "", "plugin path")
+	hisFastWrite.Flags().StringP("plugin", "", "", "plugin path, mock://表示使用内置mock插件, goplugin://表示Go插件, exec://表示外部进程插件, host://表示在插件宿主进程中加载插件, http://表示网络插件")
 	hisFastWrite.Flags().StringP("his_normal_analog", "", "", "history normal analog csv path")
 	hisFastWrite.Flags().StringP("his_normal_digital", "", "", "history normal digital csv path")
 	hisFastWrite.Flags().Int64P("unit_number", "", 1, "unit number")
-	hisFastWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30]的随机数浮动")
+	hisFastWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30)的随机数浮动, 等同于--value_model=uniform:0:30")
 	hisFastWrite.Flags().Int32P("magic", "", 0, "魔数, 默认为0")
 	hisFastWrite.Flags().StringP("param", "", "", "custom param")
+	hisFastWrite.Flags().StringP("report", "", "", "测试报告输出路径, 以.json结尾输出JSON格式, 以.csv结尾输出CSV格式, 为空表示不输出")
+	hisFastWrite.Flags().StringP("trace", "", "", "断面写入明细输出路径, 以.csv结尾输出CSV格式, 否则输出JSON Lines格式, 为空表示不输出")
+	hisFastWrite.Flags().Int("histogram_precision", DefaultHistogramPrecision, "耗时统计的有效数字位数, 取值范围[1,4], 越大越精确, 占用内存越多")
+	hisFastWrite.Flags().Bool("strict", false, "严格模式, 登录前检查所有CSV文件, 存在无法读取, 列数错误或无法解析的行时输出文件和行号并退出; 默认跳过这些行并在统计中输出跳过的行数")
+	hisFastWrite.Flags().String("metrics_addr", "", "Prometheus指标的监听地址, 如:9100, 写入过程中通过/metrics抓取, 为空表示不提供")
+	hisFastWrite.Flags().Duration("progress", DefaultProgressInterval, "写入进度的输出间隔, 为0时不输出")
+	AddStopLimitFlags(hisFastWrite)
+	AddValueTransformFlags(hisFastWrite)
+	AddCsvFlags(hisFastWrite)
 
 	rootCmd.AddCommand(hisPeriodicWrite)
-	hisPeriodicWrite.Flags().StringP("plugin", "", "", "plugin path")
+	hisPeriodicWrite.Flags().StringP("plugin", "", "", "plugin path, mock://表示使用内置mock插件, goplugin://表示Go插件, exec://表示外部进程插件, host://表示在插件宿主进程中加载插件, http://表示网络插件")
 	hisPeriodicWrite.Flags().StringP("his_normal_analog", "", "", "history normal analog csv path")
 	hisPeriodicWrite.Flags().StringP("his_normal_digital", "", "", "history normal digital csv path")
 	hisPeriodicWrite.Flags().Int64P("unit_number", "", 1, "unit number")
-	hisPeriodicWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30]的随机数浮动")
+	hisPeriodicWrite.Flags().BoolP("random_av", "", false, "为true表示给av值加一个[0,30)的随机数浮动, 等同于--value_model=uniform:0:30")
 	hisPeriodicWrite.Flags().Int32P("magic", "", 0, "魔数, 默认为0")
 	hisPeriodicWrite.Flags().StringP("param", "", "", "custom param")
+	hisPeriodicWrite.Flags().StringP("report", "", "", "测试报告输出路径, 以.json结尾输出JSON格式, 以.csv结尾输出CSV格式, 为空表示不输出")
+	hisPeriodicWrite.Flags().StringP("trace", "", "", "断面写入明细输出路径, 以.csv结尾输出CSV格式, 否则输出JSON Lines格式, 为空表示不输出")
+	hisPeriodicWrite.Flags().Int("histogram_precision", DefaultHistogramPrecision, "耗时统计的有效数字位数, 取值范围[1,4], 越大越精确, 占用内存越多")
+	hisPeriodicWrite.Flags().String("catch_up", DefaultCatchUp, "写入耗时超出写入周期时的追赶策略: burst表示连续写入直到追上计划时间, skip表示跳过错过的周期, coalesce表示将错过的周期合并为一次批量写入(只支持实时快采点)")
+	hisPeriodicWrite.Flags().Duration("spin", 0, "距离计划写入时间不超过该时长时忙等而不是睡眠, 用于亚毫秒级的调度精度, 为0时不忙等")
+	hisPeriodicWrite.Flags().Bool("strict", false, "严格模式, 登录前检查所有CSV文件, 存在无法读取, 列数错误或无法解析的行时输出文件和行号并退出; 默认跳过这些行并在统计中输出跳过的行数")
+	hisPeriodicWrite.Flags().String("metrics_addr", "", "Prometheus指标的监听地址, 如:9100, 写入过程中通过/metrics抓取, 为空表示不提供")
+	hisPeriodicWrite.Flags().Duration("progress", DefaultProgressInterval, "写入进度的输出间隔, 为0时不输出")
+	AddStopLimitFlags(hisPeriodicWrite)
+	AddValueTransformFlags(hisPeriodicWrite)
+	AddCsvFlags(hisPeriodicWrite)
+	AddPeriodicConfigFlags(hisPeriodicWrite, false)
+
+	rootCmd.AddCommand(runScenario)
+
+	rootCmd.AddCommand(genDataset)
+	AddGenFlags(genDataset)
+
+	rootCmd.AddCommand(validateCsv)
+	AddValidateFlags(validateCsv)
+
+	rootCmd.AddCommand(pluginHost)
+	pluginHost.Flags().StringP("plugin", "", "", "plugin path")
+	pluginHost.Flags().StringP("socket", "", "", "unix domain socket path")
+
+	rootCmd.AddCommand(stubServer)
+	stubServer.Flags().StringP("listen", "", "127.0.0.1:8080", "listen address")
+	stubServer.Flags().StringP("plugin", "", "mock://", "plugin path, 默认使用内置mock插件")
 }
 
 func Execute() {
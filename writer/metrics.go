@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsContentType Prometheus文本格式
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsBuckets Prometheus直方图的分桶上限
+var MetricsBuckets = []time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// PluginCallStats 插件接口的调用统计, 按接口名称(write_rt_analog, write_rt_analog_list等)分类
+// 每个机组的每次调用记录一次, 不包括不可重入插件等待调用锁的时间
+type PluginCallStats struct {
+	lock *sync.Mutex
+	ops  map[string]*PluginCall
+}
+
+// PluginCall 一个插件接口的调用统计
+type PluginCall struct {
+	Histogram *Histogram             // 调用耗时
+	Errors    map[WriteErrorCode]int // 每种错误码出现的次数
+}
+
+func NewPluginCallStats() *PluginCallStats {
+	return &PluginCallStats{lock: new(sync.Mutex), ops: make(map[string]*PluginCall)}
+}
+
+// Record 记录一次插件接口调用
+func (s *PluginCallStats) Record(op string, d time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	call, ok := s.ops[op]
+	if !ok {
+		histogram, _ := NewHistogram(DefaultHistogramPrecision)
+		call = &PluginCall{Histogram: histogram, Errors: make(map[WriteErrorCode]int)}
+		s.ops[op] = call
+	}
+	call.Histogram.Record(d)
+	if err != nil {
+		call.Errors[WriteErrorCodeOf(err)]++
+	}
+}
+
+// Metrics Prometheus指标, 通过 --metrics_addr 提供给Prometheus抓取
+// 每次抓取时合并当前的写入统计, 写入结束后停止服务
+type Metrics struct {
+	collector *Collector
+	plugin    *WritePlugin
+	server    *http.Server
+}
+
+// ServeMetrics 在addr上提供 /metrics 接口
+func ServeMetrics(addr string, collector *Collector, plugin *WritePlugin) (*Metrics, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("指标服务监听失败: %v, %v", addr, err)
+	}
+	m := &Metrics{collector: collector, plugin: plugin}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	m.server = &http.Server{Handler: mux}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("指标服务异常退出: ", err)
+		}
+	}()
+	log.Printf("指标服务已启动: http://%v/metrics\n", listener.Addr())
+	return m, nil
+}
+
+// Close 停止指标服务
+func (m *Metrics) Close() {
+	_ = m.server.Close()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", MetricsContentType)
+	_, _ = w.Write([]byte(m.Text()))
+}
+
+// Text 输出Prometheus文本格式的指标
+func (m *Metrics) Text() string {
+	b := new(metricsBuilder)
+	m.writePluginCalls(b)
+
+	fast, normal := m.collector.merge()
+	kinds := []struct {
+		kind  string
+		stats *WriteStats
+	}{{"fast", fast}, {"normal", normal}}
+
+	b.header("rtdb_writer_section_write_duration_seconds", "histogram", "断面写入耗时, 包括所有机组, point为analog或digital")
+	for _, k := range kinds {
+		b.histogram("rtdb_writer_section_write_duration_seconds", fmt.Sprintf(`kind="%v",point="analog"`, k.kind), k.stats.Analog.Histogram)
+		b.histogram("rtdb_writer_section_write_duration_seconds", fmt.Sprintf(`kind="%v",point="digital"`, k.kind), k.stats.Digital.Histogram)
+	}
+	values := []struct {
+		name  string
+		typ   string
+		help  string
+		value func(s *WriteStats) float64
+	}{
+		{"rtdb_writer_sections_total", "counter", "已写入的断面数量", func(s *WriteStats) float64 { return float64(s.Total.SectionCount) }},
+		{"rtdb_writer_pnums_total", "counter", "已写入的PNUM数量", func(s *WriteStats) float64 { return float64(s.Total.PNumCount) }},
+		{"rtdb_writer_failed_sections_total", "counter", "写入失败(丢失)的断面数量", func(s *WriteStats) float64 { return float64(s.Total.FailedSectionCount) }},
+		{"rtdb_writer_failed_pnums_total", "counter", "写入失败(丢失)的PNUM数量", func(s *WriteStats) float64 { return float64(s.Total.FailedPNumCount) }},
+		{"rtdb_writer_sleep_seconds_total", "counter", "周期性写入的睡眠时长", func(s *WriteStats) float64 { return s.Sleep.Seconds() }},
+		{"rtdb_writer_scheduler_lag_seconds_total", "counter", "周期性写入时, 写入耗时超出写入周期的总时长", func(s *WriteStats) float64 { return s.Schedule.Overrun.Seconds() }},
+		{"rtdb_writer_scheduler_overruns_total", "counter", "周期性写入时, 错过截止时间(写入耗时超出写入周期)的次数", func(s *WriteStats) float64 { return float64(s.Schedule.Missed) }},
+		{"rtdb_writer_scheduler_max_overrun_seconds", "gauge", "周期性写入时, 写入耗时超出写入周期的最大时长", func(s *WriteStats) float64 { return s.Schedule.MaxOverrun.Seconds() }},
+		{"rtdb_writer_scheduler_drift_seconds", "gauge", "周期性写入时, 实际写入时间与计划写入时间的累计偏差", func(s *WriteStats) float64 { return s.Schedule.Drift.Seconds() }},
+	}
+	for _, v := range values {
+		b.header(v.name, v.typ, v.help)
+		for _, k := range kinds {
+			b.sample(v.name, fmt.Sprintf(`kind="%v"`, k.kind), v.value(k.stats))
+		}
+	}
+
+	// CSV读取
+	inputs := make([]InputSummary, 0)
+	inputKinds := make([]string, 0)
+	for _, k := range kinds {
+		if input, ok := m.collector.inputSummary(k.kind); ok {
+			inputs = append(inputs, input)
+			inputKinds = append(inputKinds, k.kind)
+		}
+	}
+	gauges := []struct {
+		name  string
+		typ   string
+		help  string
+		value func(input InputSummary) float64
+	}{
+		{"rtdb_writer_csv_backlog_sections", "gauge", "缓存队列中已读取未写入的断面数量", func(input InputSummary) float64 { return float64(input.Length) }},
+		{"rtdb_writer_csv_backlog_capacity", "gauge", "缓存队列大小", func(input InputSummary) float64 { return float64(input.Capacity) }},
+		{"rtdb_writer_csv_read_bytes_total", "counter", "已读取的CSV字节数", func(input InputSummary) float64 { return float64(input.Read) }},
+		{"rtdb_writer_csv_size_bytes", "gauge", "CSV文件总大小, 循环读取时乘以读取遍数", func(input InputSummary) float64 { return float64(input.Total) }},
+	}
+	for _, g := range gauges {
+		b.header(g.name, g.typ, g.help)
+		for i, input := range inputs {
+			b.sample(g.name, fmt.Sprintf(`kind="%v"`, inputKinds[i]), g.value(input))
+		}
+	}
+	return b.String()
+}
+
+// writePluginCalls 输出插件接口的调用统计
+func (m *Metrics) writePluginCalls(b *metricsBuilder) {
+	s := m.plugin.calls
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ops := make([]string, 0, len(s.ops))
+	for op := range s.ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	b.header("rtdb_writer_plugin_call_duration_seconds", "histogram", "插件接口的调用耗时, 每个机组的每次调用记录一次")
+	for _, op := range ops {
+		b.histogram("rtdb_writer_plugin_call_duration_seconds", fmt.Sprintf(`op="%v"`, op), s.ops[op].Histogram)
+	}
+	b.header("rtdb_writer_plugin_call_errors_total", "counter", "插件接口调用失败的次数, code为错误码")
+	for _, op := range ops {
+		codes := make([]int, 0, len(s.ops[op].Errors))
+		for code := range s.ops[op].Errors {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			b.sample("rtdb_writer_plugin_call_errors_total", fmt.Sprintf(`op="%v",code="%v"`, op, code), float64(s.ops[op].Errors[WriteErrorCode(code)]))
+		}
+	}
+}
+
+// metricsBuilder 按Prometheus文本格式拼接指标
+type metricsBuilder struct {
+	strings.Builder
+}
+
+func (b *metricsBuilder) header(name string, typ string, help string) {
+	fmt.Fprintf(b, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
+
+func (b *metricsBuilder) sample(name string, labels string, value float64) {
+	fmt.Fprintf(b, "%v{%v} %v\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// histogram 按 MetricsBuckets 输出直方图, 耗时单位为秒
+func (b *metricsBuilder) histogram(name string, labels string, h *Histogram) {
+	counts := h.CumulativeCounts(MetricsBuckets)
+	for i, bound := range MetricsBuckets {
+		b.sample(name+"_bucket", fmt.Sprintf(`%v,le="%v"`, labels, strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)), float64(counts[i]))
+	}
+	b.sample(name+"_bucket", labels+`,le="+Inf"`, float64(h.Count()))
+	b.sample(name+"_sum", labels, h.Sum().Seconds())
+	b.sample(name+"_count", labels, float64(h.Count()))
+}
@@ -0,0 +1,421 @@
+package main
+
+// #cgo CFLAGS: -I../plugin
+// #include "write_plugin.h"
+import "C"
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockPluginScheme mock插件路径前缀, 如: --plugin=mock://?latency=normal:2ms:500us&fail_rate=0.01
+const MockPluginScheme = "mock://"
+
+// MockCall mock插件记录的一次插件接口调用
+type MockCall struct {
+	Op        string         `json:"op"`                   // 插件接口名称, 如 write_rt_analog
+	Magic     int32          `json:"magic"`                // 魔数
+	UnitId    int64          `json:"unit_id"`              // 机组ID
+	Time      []int64        `json:"time,omitempty"`       // 断面时间, 批量写入时包含多个断面
+	Count     []int64        `json:"count"`                // 每个断面包含值的数量
+	IsFast    bool           `json:"is_fast,omitempty"`    // 是否为快采点
+	Type      int64          `json:"type,omitempty"`       // 静态值类型
+	GlobalIDs []int64        `json:"global_ids,omitempty"` // 所有值的GlobalID, 只有记录调用时生成
+	Start     time.Time      `json:"start"`                // 调用开始时间
+	Latency   time.Duration  `json:"latency"`              // 模拟的写入延迟
+	Code      WriteErrorCode `json:"code"`                 // 模拟的错误码
+}
+
+// MockLatency 模拟的写入延迟分布
+// none: 无延迟
+// fixed:D 固定延迟D
+// uniform:MIN:MAX 在[MIN, MAX)之间均匀分布
+// normal:MEAN:STDDEV 正态分布, 小于0时按0处理
+// exp:MEAN 指数分布
+type MockLatency struct {
+	Kind   string
+	Params []time.Duration
+}
+
+// ParseMockLatency 解析延迟分布, 如 normal:2ms:500us
+func ParseMockLatency(s string) (MockLatency, error) {
+	items := strings.Split(s, ":")
+	latency := MockLatency{Kind: items[0]}
+	for _, item := range items[1:] {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			return latency, fmt.Errorf("mock插件延迟分布参数错误: %v, %v", s, err)
+		}
+		latency.Params = append(latency.Params, d)
+	}
+
+	paramCount := map[string]int{"none": 0, "fixed": 1, "uniform": 2, "normal": 2, "exp": 1}
+	count, ok := paramCount[latency.Kind]
+	if !ok {
+		return latency, fmt.Errorf("mock插件延迟分布未知: %v, 可选值: none, fixed, uniform, normal, exp", s)
+	}
+	if count != len(latency.Params) {
+		return latency, fmt.Errorf("mock插件延迟分布参数数量错误: %v, 需要%v个参数", s, count)
+	}
+	return latency, nil
+}
+
+// Sample 按分布生成一个延迟
+func (l MockLatency) Sample(rnd *rand.Rand) time.Duration {
+	d := time.Duration(0)
+	switch l.Kind {
+	case "fixed":
+		d = l.Params[0]
+	case "uniform":
+		if l.Params[1] > l.Params[0] {
+			d = l.Params[0] + time.Duration(rnd.Int63n(int64(l.Params[1]-l.Params[0])))
+		} else {
+			d = l.Params[0]
+		}
+	case "normal":
+		d = time.Duration(rnd.NormFloat64()*float64(l.Params[1]) + float64(l.Params[0]))
+	case "exp":
+		d = time.Duration(rnd.ExpFloat64() * float64(l.Params[0]))
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// MockOpStat mock插件单个接口的调用统计
+type MockOpStat struct {
+	CallCount    int64         // 调用次数
+	FailedCount  int64         // 失败次数
+	SectionCount int64         // 断面数量
+	PNumCount    int64         // PNUM数量
+	Latency      time.Duration // 模拟延迟总和
+}
+
+// MockPlugin 内置的mock插件, 不依赖真实数据库, 用于自测写入程序
+// 记录每一次接口调用, 并且可以模拟写入延迟和写入失败
+type MockPlugin struct {
+	latency  MockLatency
+	failRate float64
+	failCode WriteErrorCode
+	info     PluginInfo
+
+	rndLock *sync.Mutex
+	rnd     *rand.Rand
+
+	recordLock *sync.Mutex
+	memory     bool   // 为true时在内存中记录所有调用
+	recordPath string // 调用记录文件, 为空时不记录到文件
+	calls      []MockCall
+	file       *os.File
+	writer     *bufio.Writer
+	stats      map[string]*MockOpStat
+}
+
+// NewMockPlugin 根据插件路径创建mock插件
+// 路径格式: mock://?latency=none&fail_rate=0&fail_code=1&record=none&seed=0&vendor=mock&reentrant=true&support_list=true&max_batch_size=0
+// * latency: 延迟分布, 参考 MockLatency
+// * fail_rate: 写入失败概率, [0, 1]
+// * fail_code: 写入失败时返回的错误码
+// * record: 调用记录方式, none表示不记录, memory表示记录在内存中(调用记录会一直增长, 只适合短时间运行), 其他值表示记录到该路径的文件中(每行一个JSON)
+// * seed: 随机数种子
+// * vendor, reentrant, support_list, max_batch_size: 模拟的插件信息
+func NewMockPlugin(path string) (*MockPlugin, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("mock插件路径解析失败: %v, %v", path, err)
+	}
+	query := u.Query()
+	get := func(key string, def string) string {
+		if v := query.Get(key); v != "" {
+			return v
+		}
+		return def
+	}
+
+	mock := &MockPlugin{
+		failCode: WriteErrUnknown,
+		info: PluginInfo{
+			ABIVersion:    int(C.WRITE_PLUGIN_ABI_VERSION),
+			Vendor:        get("vendor", "mock"),
+			SupportHis:    true,
+			SupportStatic: true,
+		},
+		rndLock:    new(sync.Mutex),
+		recordLock: new(sync.Mutex),
+		stats:      make(map[string]*MockOpStat),
+	}
+
+	if mock.latency, err = ParseMockLatency(get("latency", "none")); err != nil {
+		return nil, err
+	}
+	if mock.failRate, err = strconv.ParseFloat(get("fail_rate", "0"), 64); err != nil || mock.failRate < 0 || mock.failRate > 1 {
+		return nil, fmt.Errorf("mock插件fail_rate错误: %v, 取值范围[0, 1]", query.Get("fail_rate"))
+	}
+	failCode, err := strconv.Atoi(get("fail_code", "1"))
+	if err != nil || failCode == int(WriteOk) {
+		return nil, fmt.Errorf("mock插件fail_code错误: %v, 不能为0", query.Get("fail_code"))
+	}
+	mock.failCode = WriteErrorCode(failCode)
+	seed, err := strconv.ParseInt(get("seed", "0"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("mock插件seed错误: %v", query.Get("seed"))
+	}
+	mock.rnd = rand.New(rand.NewSource(seed))
+	if mock.info.Reentrant, err = strconv.ParseBool(get("reentrant", "true")); err != nil {
+		return nil, fmt.Errorf("mock插件reentrant错误: %v", query.Get("reentrant"))
+	}
+	if mock.info.SupportList, err = strconv.ParseBool(get("support_list", "true")); err != nil {
+		return nil, fmt.Errorf("mock插件support_list错误: %v", query.Get("support_list"))
+	}
+	if mock.info.MaxBatchSize, err = strconv.Atoi(get("max_batch_size", "0")); err != nil || mock.info.MaxBatchSize < 0 {
+		return nil, fmt.Errorf("mock插件max_batch_size错误: %v", query.Get("max_batch_size"))
+	}
+
+	switch record := get("record", "none"); record {
+	case "memory":
+		mock.memory = true
+	case "none":
+	default:
+		file, err := os.Create(record)
+		if err != nil {
+			return nil, fmt.Errorf("mock插件调用记录文件创建失败: %v", err)
+		}
+		mock.file = file
+		mock.recordPath = record
+		mock.writer = bufio.NewWriter(file)
+	}
+
+	return mock, nil
+}
+
+// Info 模拟的插件信息
+func (m *MockPlugin) Info() PluginInfo {
+	return m.info
+}
+
+// Calls 内存中记录的所有调用, 只有 record=memory 时有效
+func (m *MockPlugin) Calls() []MockCall {
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+func (m *MockPlugin) Login(param string) int {
+	log.Printf("mock插件登录, param: %v\n", param)
+	return 0
+}
+
+// Logout 登出, 输出各接口的调用统计, 并关闭调用记录文件
+func (m *MockPlugin) Logout() {
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+
+	ops := make([]string, 0, len(m.stats))
+	for op := range m.stats {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		stat := m.stats[op]
+		log.Printf("mock插件 - %v: 调用次数: %v, 失败次数: %v, 断面数量: %v, PNUM数量: %v, 模拟延迟: %v\n",
+			op, stat.CallCount, stat.FailedCount, stat.SectionCount, stat.PNumCount, stat.Latency)
+	}
+
+	if m.file != nil {
+		if err := m.writer.Flush(); err != nil {
+			log.Println("mock插件调用记录写入失败:", err)
+		}
+		_ = m.file.Close()
+		m.file = nil
+		m.writer = nil
+	}
+}
+
+// call 模拟一次接口调用: 按延迟分布睡眠, 按失败概率返回错误, 并记录调用
+func (m *MockPlugin) call(call MockCall) error {
+	m.rndLock.Lock()
+	call.Latency = m.latency.Sample(m.rnd)
+	failed := m.failRate > 0 && m.rnd.Float64() < m.failRate
+	m.rndLock.Unlock()
+
+	call.Start = time.Now()
+	if call.Latency > 0 {
+		time.Sleep(call.Latency)
+	}
+	if failed {
+		call.Code = m.failCode
+	}
+
+	m.record(call)
+
+	if failed {
+		return &WriteError{Op: call.Op, UnitId: call.UnitId, Code: call.Code, Message: "mock failure"}
+	}
+	return nil
+}
+
+// record 记录调用, 写入文件时在加锁前完成JSON编码, 减少持锁时间
+func (m *MockPlugin) record(call MockCall) {
+	var line []byte
+	if m.recordPath != "" {
+		var err error
+		if line, err = json.Marshal(call); err != nil {
+			log.Println("mock插件调用记录编码失败:", err)
+		}
+	}
+
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+
+	stat, ok := m.stats[call.Op]
+	if !ok {
+		stat = new(MockOpStat)
+		m.stats[call.Op] = stat
+	}
+	stat.CallCount++
+	if call.Code != WriteOk {
+		stat.FailedCount++
+	}
+	stat.SectionCount += int64(len(call.Count))
+	for _, count := range call.Count {
+		stat.PNumCount += count
+	}
+	stat.Latency += call.Latency
+
+	if m.memory {
+		m.calls = append(m.calls, call)
+	}
+	if m.writer != nil && line != nil {
+		line = append(line, '\n')
+		if _, err := m.writer.Write(line); err != nil {
+			log.Println("mock插件调用记录写入失败:", err)
+		}
+	}
+}
+
+func (m *MockPlugin) WriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error {
+	return m.call(MockCall{
+		Op: "write_rt_analog", Magic: magic, UnitId: unitId, IsFast: isFast,
+		Time:      []int64{section.Time},
+		Count:     []int64{int64(len(section.Data))},
+		GlobalIDs: m.analogGlobalIDs(nil, section),
+	})
+}
+
+func (m *MockPlugin) WriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error {
+	return m.call(MockCall{
+		Op: "write_rt_digital", Magic: magic, UnitId: unitId, IsFast: isFast,
+		Time:      []int64{section.Time},
+		Count:     []int64{int64(len(section.Data))},
+		GlobalIDs: m.digitalGlobalIDs(nil, section),
+	})
+}
+
+func (m *MockPlugin) WriteRtAnalogList(magic int32, unitId int64, sections []AnalogSection) error {
+	call := MockCall{Op: "write_rt_analog_list", Magic: magic, UnitId: unitId, IsFast: true}
+	for _, section := range sections {
+		call.Time = append(call.Time, section.Time)
+		call.Count = append(call.Count, int64(len(section.Data)))
+		call.GlobalIDs = m.analogGlobalIDs(call.GlobalIDs, section)
+	}
+	return m.call(call)
+}
+
+func (m *MockPlugin) WriteRtDigitalList(magic int32, unitId int64, sections []DigitalSection) error {
+	call := MockCall{Op: "write_rt_digital_list", Magic: magic, UnitId: unitId, IsFast: true}
+	for _, section := range sections {
+		call.Time = append(call.Time, section.Time)
+		call.Count = append(call.Count, int64(len(section.Data)))
+		call.GlobalIDs = m.digitalGlobalIDs(call.GlobalIDs, section)
+	}
+	return m.call(call)
+}
+
+func (m *MockPlugin) WriteHisAnalog(magic int32, unitId int64, section AnalogSection) error {
+	return m.call(MockCall{
+		Op: "write_his_analog", Magic: magic, UnitId: unitId,
+		Time:      []int64{section.Time},
+		Count:     []int64{int64(len(section.Data))},
+		GlobalIDs: m.analogGlobalIDs(nil, section),
+	})
+}
+
+func (m *MockPlugin) WriteHisDigital(magic int32, unitId int64, section DigitalSection) error {
+	return m.call(MockCall{
+		Op: "write_his_digital", Magic: magic, UnitId: unitId,
+		Time:      []int64{section.Time},
+		Count:     []int64{int64(len(section.Data))},
+		GlobalIDs: m.digitalGlobalIDs(nil, section),
+	})
+}
+
+func (m *MockPlugin) WriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error {
+	call := MockCall{
+		Op: "write_static_analog", Magic: magic, UnitId: unitId, Type: typ,
+		Count: []int64{int64(len(section.Data))},
+	}
+	if m.recording() {
+		call.GlobalIDs = make([]int64, 0, len(section.Data))
+		for _, d := range section.Data {
+			call.GlobalIDs = append(call.GlobalIDs, int64(d.global_id))
+		}
+	}
+	return m.call(call)
+}
+
+func (m *MockPlugin) WriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error {
+	call := MockCall{
+		Op: "write_static_digital", Magic: magic, UnitId: unitId, Type: typ,
+		Count: []int64{int64(len(section.Data))},
+	}
+	if m.recording() {
+		call.GlobalIDs = make([]int64, 0, len(section.Data))
+		for _, d := range section.Data {
+			call.GlobalIDs = append(call.GlobalIDs, int64(d.global_id))
+		}
+	}
+	return m.call(call)
+}
+
+// recording 是否记录调用, 不记录时不需要生成GlobalID, 避免在写入路径上分配内存
+func (m *MockPlugin) recording() bool {
+	return m.memory || m.recordPath != ""
+}
+
+// analogGlobalIDs 将断面中所有值的GlobalID追加到globalIDs, 不记录调用时直接返回globalIDs
+func (m *MockPlugin) analogGlobalIDs(globalIDs []int64, section AnalogSection) []int64 {
+	if !m.recording() {
+		return globalIDs
+	}
+	for _, d := range section.Data {
+		globalIDs = append(globalIDs, int64(d.global_id))
+	}
+	return globalIDs
+}
+
+// digitalGlobalIDs 将断面中所有值的GlobalID追加到globalIDs, 不记录调用时直接返回globalIDs
+func (m *MockPlugin) digitalGlobalIDs(globalIDs []int64, section DigitalSection) []int64 {
+	if !m.recording() {
+		return globalIDs
+	}
+	for _, d := range section.Data {
+		globalIDs = append(globalIDs, int64(d.global_id))
+	}
+	return globalIDs
+}
+
+// IsMockPlugin 判断插件路径是否为mock插件
+func IsMockPlugin(path string) bool {
+	return strings.HasPrefix(path, MockPluginScheme)
+}
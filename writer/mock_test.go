@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMockPluginStats 通过mock插件周期性写入, 模拟的延迟和失败应体现在写入统计中
+func TestMockPluginStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		latency  time.Duration  // 每次调用的最小耗时
+		failCode WriteErrorCode // 写入失败时的错误码, WriteOk表示全部写入成功
+	}{
+		{"无延迟", "", 0, WriteOk},
+		{"固定延迟", "latency=fixed:2ms", 2 * time.Millisecond, WriteOk},
+		{"均匀分布延迟", "latency=uniform:1ms:3ms&seed=1", time.Millisecond, WriteOk},
+		{"全部失败", "fail_rate=1&fail_code=5", 0, WriteErrOverload},
+		{"延迟并失败", "latency=fixed:1ms&fail_rate=1", time.Millisecond, WriteErrUnknown},
+	}
+	const sectionCount = 5
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock, err := NewMockPlugin("mock://?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func(plugin *WritePlugin) { GlobalPlugin = plugin }(GlobalPlugin)
+			GlobalPlugin = NewWritePluginFromWriter(mock)
+
+			stats, err := NewWriteStats("fast", DefaultHistogramPrecision, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < sectionCount; i++ {
+				ts := int64(1000 + i)
+				section := Section{
+					analogOk: true, analog: testAnalogSection(t, ts, 1, 2, 3),
+					digitalOk: true, digital: testDigitalSection(t, ts, 1, 2),
+				}
+				WritePeriodicSection(stats, 0, 1, section, true, true, nil, time.Now())
+			}
+
+			failedSections, failedAnalog, failedDigital := int64(0), int64(0), int64(0)
+			if tt.failCode != WriteOk {
+				failedSections, failedAnalog, failedDigital = sectionCount, 3*sectionCount, 2*sectionCount
+			}
+			for _, s := range []struct {
+				name      string
+				stats     *LatencyStats
+				pNums     int64
+				failed    int64
+				codeCount int
+			}{
+				{"模拟量", stats.Analog, 3 * sectionCount, failedAnalog, sectionCount},
+				{"数字量", stats.Digital, 2 * sectionCount, failedDigital, sectionCount},
+				{"合计", stats.Total, 5 * sectionCount, failedAnalog + failedDigital, 2 * sectionCount},
+			} {
+				summary := Summary(s.stats, false)
+				if summary.Count != sectionCount || summary.PNum != int(s.pNums) {
+					t.Fatalf("%v的断面数量为%v, PNUM数量为%v, 应为%v, %v", s.name, summary.Count, summary.PNum, sectionCount, s.pNums)
+				}
+				if summary.Min < tt.latency {
+					t.Fatalf("%v的最小耗时为%v, 应不小于模拟延迟%v", s.name, summary.Min, tt.latency)
+				}
+				if s.stats.FailedSectionCount != failedSections || s.stats.FailedPNumCount != s.failed {
+					t.Fatalf("%v的失败断面数量为%v, 失败PNUM数量为%v, 应为%v, %v",
+						s.name, s.stats.FailedSectionCount, s.stats.FailedPNumCount, failedSections, s.failed)
+				}
+				if tt.failCode == WriteOk {
+					if len(s.stats.ErrorCodes) != 0 {
+						t.Fatalf("%v不应有错误码: %v", s.name, s.stats.ErrorCodes)
+					}
+				} else if len(s.stats.ErrorCodes) != 1 || s.stats.ErrorCodes[tt.failCode] != s.codeCount {
+					t.Fatalf("%v的错误码为%v, 应为%v次%v", s.name, s.stats.ErrorCodes, s.codeCount, tt.failCode)
+				}
+			}
+			// 合计的耗时为模拟量和数字量耗时之和
+			if total := Summary(stats.Total, false).Min; total < 2*tt.latency {
+				t.Fatalf("合计的最小耗时为%v, 应不小于%v", total, 2*tt.latency)
+			}
+
+			for _, op := range []string{"write_rt_analog", "write_rt_digital"} {
+				stat := mock.stats[op]
+				if stat == nil || stat.CallCount != sectionCount || stat.SectionCount != sectionCount {
+					t.Fatalf("mock插件%v的调用统计错误: %+v", op, stat)
+				}
+				if tt.failCode != WriteOk && stat.FailedCount != sectionCount || tt.failCode == WriteOk && stat.FailedCount != 0 {
+					t.Fatalf("mock插件%v的失败次数为%v", op, stat.FailedCount)
+				}
+				if stat.Latency < sectionCount*tt.latency {
+					t.Fatalf("mock插件%v的模拟延迟为%v, 应不小于%v", op, stat.Latency, sectionCount*tt.latency)
+				}
+			}
+		})
+	}
+}
+
+// TestMockPluginRecord 只有记录调用时才生成GlobalID
+func TestMockPluginRecord(t *testing.T) {
+	section := testAnalogSection(t, 1000, 1, 2, 3)
+	want := make([]int64, 0)
+	for _, a := range section.Data {
+		want = append(want, int64(a.global_id))
+	}
+
+	// 不记录调用时GlobalID为nil
+	mock, err := NewMockPlugin("mock://?record=none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globalIDs := mock.analogGlobalIDs(nil, section); globalIDs != nil {
+		t.Fatalf("不记录调用时不应生成GlobalID: %v", globalIDs)
+	}
+
+	// 记录在内存中
+	mock, err = NewMockPlugin("mock://?record=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.WriteRtAnalog(0, 0, section, true); err != nil {
+		t.Fatal(err)
+	}
+	if calls := mock.Calls(); len(calls) != 1 || len(calls[0].GlobalIDs) != len(want) {
+		t.Fatalf("内存中的调用记录错误: %+v", calls)
+	}
+
+	// 记录到文件
+	path := filepath.Join(t.TempDir(), "calls.jsonl")
+	mock, err = NewMockPlugin("mock://?record=" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.WriteRtAnalogList(0, 0, []AnalogSection{section, section}); err != nil {
+		t.Fatal(err)
+	}
+	mock.Logout()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatal("调用记录文件为空")
+	}
+	var call MockCall
+	if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+		t.Fatal(err)
+	}
+	if len(call.GlobalIDs) != 2*len(want) || call.GlobalIDs[0] != want[0] || call.GlobalIDs[len(want)] != want[0] {
+		t.Fatalf("文件中的GlobalID为%v, 应为两遍%v", call.GlobalIDs, want)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"writer/sdk"
+)
+
+// HTTPDefaultTimeout 网络插件单个请求的默认超时时间
+const HTTPDefaultTimeout = 30 * time.Second
+
+// IsHTTPPlugin 是否为网络插件, 如: --plugin=http://127.0.0.1:8080?timeout=10s
+func IsHTTPPlugin(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// NewHTTPWriter 创建网络插件, 通过HTTP将断面以protobuf编码发送到远程服务, 协议参考 writer/sdk/rtdb_writer.proto
+// 可选参数: timeout 单个请求的超时时间, 默认为30s
+func NewHTTPWriter(rawURL string) (*SdkWriter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("网络插件地址解析失败: %v, %v", rawURL, err)
+	}
+	timeout := HTTPDefaultTimeout
+	if v := u.Query().Get("timeout"); v != "" {
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("网络插件参数错误: timeout=%v", v)
+		}
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	client := sdk.NewHTTPClient(u.String(), timeout)
+	info, err := client.QueryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("网络插件查询插件信息失败: %v, %v", u.String(), err)
+	}
+	return NewSdkWriter(client, info), nil
+}
+
+// ServeStub 启动本地网络协议桩服务, 收到的请求转发给pluginPath对应的插件
+// 用于在单机上端到端验证网络写入协议, 默认使用内置mock插件
+func ServeStub(listen string, pluginPath string) error {
+	if IsHTTPPlugin(pluginPath) {
+		return fmt.Errorf("桩服务不能转发到网络插件: %v", pluginPath)
+	}
+	writer, err := NewWriter(pluginPath)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(sdk.HTTPServicePath, sdk.NewHTTPHandler(&PluginHost{writer: writer}))
+	log.Printf("桩服务已启动, 监听地址: %v, 插件: %v, %v\n", listen, pluginPath, writer.Info())
+	return http.ListenAndServe(listen, mux)
+}
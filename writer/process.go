@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"writer/sdk"
+)
+
+// ProcessPluginScheme 外部进程插件路径前缀, 如: --plugin="exec://java -jar writer-plugin.jar"
+const ProcessPluginScheme = "exec://"
+
+// ProcessWriter 外部进程插件
+// 写入程序启动插件进程, 通过插件进程的标准输入输出交换数据, 协议参考 writer/sdk/protocol.go
+// 插件进程的标准错误输出直接输出到写入程序的标准错误输出
+type ProcessWriter struct {
+	*SdkWriter
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	client *sdk.Client
+}
+
+// NewProcessWriter 启动插件进程并查询插件信息, command为插件进程的命令行, 参数以空格分隔
+func NewProcessWriter(command string) (*ProcessWriter, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("外部进程插件命令为空")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("外部进程插件启动失败: %v, %v", command, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("外部进程插件启动失败: %v, %v", command, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("外部进程插件启动失败: %v, %v", command, err)
+	}
+
+	client := sdk.NewClient(stdout, stdin)
+	info, err := client.QueryInfo()
+	if err != nil {
+		_ = stdin.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("外部进程插件查询插件信息失败: %v, %v", command, err)
+	}
+
+	return &ProcessWriter{
+		SdkWriter: NewSdkWriter(client, info),
+		cmd:       cmd,
+		stdin:     stdin,
+		client:    client,
+	}, nil
+}
+
+// Logout 登出, 并关闭插件进程的标准输入, 等待插件进程退出
+func (w *ProcessWriter) Logout() {
+	w.SdkWriter.Logout()
+	_ = w.stdin.Close()
+	if err := w.cmd.Wait(); err != nil {
+		log.Println("外部进程插件退出异常:", err)
+	}
+}
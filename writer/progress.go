@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProgressInterval 默认的写入进度输出间隔
+const DefaultProgressInterval = 10 * time.Second
+
+// Progress 写入进度, 写入过程中通过 --progress 定期输出
+// 每次输出快采点和普通点的断面数量, 吞吐量, 本周期的P99耗时, 工作/睡眠比例, 缓存队列使用情况以及按CSV读取进度估算的剩余时间
+type Progress struct {
+	collector *Collector
+	interval  time.Duration
+	fastCache bool
+	start     time.Time
+	exitCh    chan bool
+	doneCh    chan bool
+}
+
+// ProgressInput 一组CSV输入的读取进度和缓存队列, 由 Collector.Input 创建
+type ProgressInput struct {
+	kind    string // fast或normal
+	name    string // 输出时的名称
+	ch      chan Section
+	total   atomic.Int64 // CSV文件总大小, 循环读取时乘以读取遍数
+	read    atomic.Int64 // 已读取的字节数
+	skipped atomic.Int64 // 跳过的CSV行数
+}
+
+// NewProgress 创建写入进度, interval为输出间隔
+func NewProgress(collector *Collector, interval time.Duration, fastCache bool) *Progress {
+	return &Progress{
+		collector: collector,
+		interval:  interval,
+		fastCache: fastCache,
+		exitCh:    make(chan bool, 1),
+		doneCh:    make(chan bool),
+	}
+}
+
+// Reader 统计从file读取的字节数, 文件大小乘以读取遍数计入总大小, 无限循环(loop为0)时不计入; input为nil时直接返回file
+func (input *ProgressInput) Reader(file *os.File, loop int) io.Reader {
+	if input == nil {
+		return file
+	}
+	if info, err := file.Stat(); err == nil {
+		input.total.Add(info.Size() * int64(loop))
+	}
+	return &progressReader{r: file, read: &input.read}
+}
+
+// Skip 记录一个跳过的CSV行, input为nil时不记录
+func (input *ProgressInput) Skip() {
+	if input != nil {
+		input.skipped.Add(1)
+	}
+}
+
+type progressReader struct {
+	r    io.Reader
+	read *atomic.Int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.read.Add(int64(n))
+	return n, err
+}
+
+// Start 开始定期输出写入进度
+func (p *Progress) Start() {
+	p.start = time.Now()
+	go func() {
+		defer close(p.doneCh)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		lastFast, lastNormal := p.collector.merge()
+		lastTime := p.start
+		for {
+			select {
+			case <-p.exitCh:
+				return
+			case now := <-ticker.C:
+				fast, normal := p.collector.merge()
+				p.log("fast", fast, lastFast, now.Sub(lastTime))
+				p.log("normal", normal, lastNormal, now.Sub(lastTime))
+				lastFast, lastNormal, lastTime = fast, normal, now
+			}
+		}
+	}()
+}
+
+// Stop 停止输出写入进度
+func (p *Progress) Stop() {
+	p.exitCh <- true
+	<-p.doneCh
+}
+
+// log 输出一类断面的进度, 没有注册输入的断面不输出
+func (p *Progress) log(kind string, stats *WriteStats, last *WriteStats, interval time.Duration) {
+	input, ok := p.collector.inputSummary(kind)
+	if !ok {
+		return
+	}
+
+	// 本周期的写入耗时和睡眠耗时
+	histogram := stats.Total.Histogram.Sub(last.Total.Histogram)
+	p99 := histogram.Quantile(0.99)
+	if p.fastCache {
+		p99 /= time.Duration(stats.Total.BatchSize)
+	}
+	work := histogram.Sum()
+	sleep := stats.Sleep - last.Sleep
+	ratio := "空闲"
+	if work+sleep > 0 {
+		workRatio := float64(work) * 100 / float64(work+sleep)
+		ratio = fmt.Sprintf("%.1f%%/%.1f%%", workRatio, 100-workRatio)
+	}
+	pnumRate := float64(stats.Total.PNumCount-last.Total.PNumCount) / interval.Seconds()
+
+	elapsed := time.Since(p.start)
+	log.Printf("%v - 已运行: %v, 断面数量: %v, PNUM/s: %.0f, P99耗时: %v, 工作/睡眠: %v, 缓存队列: %v/%v, %v\n",
+		input.Name, elapsed.Round(time.Second), stats.Total.SectionCount, pnumRate, p99,
+		ratio, input.Length, input.Capacity, remaining(elapsed, stats.Total.SectionCount, int64(input.Length), input.Read, input.Total),
+	)
+}
+
+// remaining 估算写入进度和剩余时间
+// 按已读取的字节数和断面数量(已写入+缓存队列中)估算CSV中的断面总数, 再按平均写入速度估算剩余时间
+func remaining(elapsed time.Duration, written int64, buffered int64, read int64, total int64) string {
+	sections := written + buffered
+	if total <= 0 || read <= 0 || sections <= 0 {
+		return "写入进度: 未知"
+	}
+	if read > total {
+		read = total
+	}
+	estimated := float64(sections) * float64(total) / float64(read)
+	percent := float64(written) * 100 / estimated
+	if written == 0 {
+		return fmt.Sprintf("写入进度: %.1f%%, 预计剩余: 未知", percent)
+	}
+	eta := time.Duration((estimated - float64(written)) * float64(elapsed) / float64(written))
+	return fmt.Sprintf("写入进度: %.1f%%, 预计剩余: %v", percent, eta.Round(time.Second))
+}
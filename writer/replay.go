@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 时间戳模式
+const (
+	TimestampOriginal = "original" // 使用CSV中的原始时间戳
+	TimestampRebase   = "rebase"   // 整体平移, 第一个断面的时间戳对齐到第一次写入的时间
+	TimestampNow      = "now"      // 写入时将断面的时间戳改为当前时间
+)
+
+// LoopForever --loop 无限循环读取
+const LoopForever = "forever"
+
+// CsvOptions CSV读取选项, 通过 --loop, --timestamp_mode 和 --per_unit_csv 指定
+type CsvOptions struct {
+	Loop          int           // 读取遍数, 0表示无限循环
+	TimestampMode string        // 时间戳模式
+	PerUnit       bool          // 每个机组读取各自的CSV文件, 参考 UnitCsvPath
+	UnitNumber    int64         // 机组数量
+	anchor        *rebaseAnchor // rebase模式下所有写入协程共用的对齐时间
+}
+
+// rebaseAnchor rebase模式的对齐时间, 为第一次写入的时间, 不包括登录后等待加载缓存的时间
+type rebaseAnchor struct {
+	once sync.Once
+	ms   int64
+}
+
+// get 第一次调用时记录当前时间
+func (a *rebaseAnchor) get() int64 {
+	a.once.Do(func() {
+		a.ms = time.Now().UnixMilli()
+	})
+	return a.ms
+}
+
+// AddCsvFlags 添加CSV读取选项的命令行参数, 默认使用CSV中的原始时间戳
+func AddCsvFlags(cmd *cobra.Command) {
+	cmd.Flags().String("loop", "1", "CSV读取遍数, forever表示无限循环(配合--duration等停止条件使用), 每一遍的时间戳按数据集的时间跨度平移, 保持单调递增")
+	cmd.Flags().Bool("per_unit_csv", false, "为true时每个机组读取各自的CSV文件, 文件名为CSV参数的文件名加上unit_<机组编号>_前缀, 机组编号从0开始, 如unit_0_xxx.csv")
+	cmd.Flags().String("timestamp_mode", TimestampOriginal, "时间戳模式: original表示使用CSV中的原始时间戳, rebase表示整体平移, 第一个断面的时间戳对齐到第一次写入的时间, now表示写入时将断面的时间戳改为当前时间")
+}
+
+// NewCsvOptions 读取并检查CSV读取选项
+func NewCsvOptions(cmd *cobra.Command) (CsvOptions, error) {
+	options := CsvOptions{}
+	loop, _ := cmd.Flags().GetString("loop")
+	options.TimestampMode, _ = cmd.Flags().GetString("timestamp_mode")
+	options.PerUnit, _ = cmd.Flags().GetBool("per_unit_csv")
+	options.UnitNumber, _ = cmd.Flags().GetInt64("unit_number")
+	if loop == LoopForever {
+		options.Loop = 0
+	} else if n, err := strconv.Atoi(loop); err != nil || n < 1 {
+		return options, fmt.Errorf("CSV读取遍数必须为正整数或%v: %v", LoopForever, loop)
+	} else {
+		options.Loop = n
+	}
+	switch options.TimestampMode {
+	case TimestampOriginal, TimestampNow:
+	case TimestampRebase:
+		options.anchor = new(rebaseAnchor)
+	default:
+		return options, fmt.Errorf("未知的时间戳模式: %v, 可选值: %v, %v, %v", options.TimestampMode, TimestampOriginal, TimestampRebase, TimestampNow)
+	}
+	if options.PerUnit {
+		if err := options.checkUnitCsv(cmd); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// LogCsvOptions 输出CSV读取选项
+func LogCsvOptions(options CsvOptions) {
+	loop := strconv.Itoa(options.Loop)
+	if options.Loop == 0 {
+		loop = LoopForever
+	}
+	log.Printf("时间戳模式: %v, CSV读取遍数: %v, 按机组读取CSV: %v\n", options.TimestampMode, loop, options.PerUnit)
+}
+
+// NewStamper now和rebase模式下创建断面时间戳的修改器, original模式返回nil
+func (options CsvOptions) NewStamper() *SectionStamper {
+	switch options.TimestampMode {
+	case TimestampNow:
+		return new(SectionStamper)
+	case TimestampRebase:
+		return &SectionStamper{anchor: options.anchor}
+	}
+	return nil
+}
+
+// SectionStamper 在写入时修改断面的时间戳, 每个写入协程的每类断面一个
+// now模式下将断面的时间戳改为当前时间, 时间戳保证单调递增, 每毫秒写入多个断面时(如极速写入)时间戳会超前于当前时间
+// rebase模式下整体平移, 第一个断面对齐到所有写入协程中第一次写入的时间, 断面之间的间隔不变
+type SectionStamper struct {
+	last   int64         // 上一个断面的时间戳
+	anchor *rebaseAnchor // rebase模式的对齐时间, now模式为nil
+	offset int64         // rebase模式的时间偏移量
+	based  bool          // rebase模式下是否已经计算时间偏移量
+}
+
+// Stamp 修改一次写入的所有断面的时间戳, s为nil时不修改
+// now模式下最后一个断面为当前时间, 其他断面保持与最后一个断面的间隔
+func (s *SectionStamper) Stamp(sections []Section) {
+	if s == nil || len(sections) == 0 {
+		return
+	}
+	if s.anchor != nil {
+		if !s.based {
+			s.offset = s.anchor.get() - sections[0].time()
+			s.based = true
+		}
+		for i := range sections {
+			sections[i].analog.Time += s.offset
+			sections[i].digital.Time += s.offset
+		}
+		return
+	}
+	now := time.Now().UnixMilli()
+	base := sections[len(sections)-1].time()
+	for i := range sections {
+		ts := now - (base - sections[i].time())
+		if ts <= s.last {
+			ts = s.last + 1
+		}
+		s.last = ts
+		sections[i].analog.Time = ts
+		sections[i].digital.Time = ts
+	}
+}
+
+// StampSection 修改一个断面的时间戳, 返回修改后的断面
+func (s *SectionStamper) StampSection(section Section) Section {
+	sections := []Section{section}
+	s.Stamp(sections)
+	return sections[0]
+}
+
+// timeShift 一个CSV文件的时间戳平移
+// 第一遍读取时记录数据集的时间跨度, 之后每一遍在上一遍的基础上平移一个时间跨度, 保持时间戳单调递增
+type timeShift struct {
+	options CsvOptions
+	pass    int   // 已读完的遍数
+	offset  int64 // 当前的时间偏移量, 单位毫秒
+	first   int64 // 第一遍的第一个时间戳, 没有读到时为-1
+	step    int64 // 第一遍前两个断面的时间间隔
+	last    int64 // 第一遍的最后一个时间戳
+}
+
+func newTimeShift(options CsvOptions) *timeShift {
+	return &timeShift{options: options, first: -1}
+}
+
+// observe 记录第一遍读取到的时间戳
+func (s *timeShift) observe(ts int64) {
+	if s.pass > 0 {
+		return
+	}
+	if s.first == -1 {
+		s.first = ts
+	} else if s.step == 0 && ts != s.first {
+		s.step = ts - s.first
+	}
+	s.last = ts
+}
+
+// shift 平移后的时间戳
+func (s *timeShift) shift(ts int64) int64 {
+	return ts + s.offset
+}
+
+// next 读完一遍后调用, 返回是否继续读取下一遍, 继续时平移一个时间跨度
+// 时间跨度为 最后一个时间戳-第一个时间戳+断面间隔, 只有一个断面时断面间隔按1毫秒计算; 空文件不循环
+func (s *timeShift) next() bool {
+	s.pass++
+	if s.first == -1 || (s.options.Loop > 0 && s.pass >= s.options.Loop) {
+		return false
+	}
+	step := s.step
+	if step <= 0 {
+		step = 1
+	}
+	s.offset += s.last - s.first + step
+	return true
+}
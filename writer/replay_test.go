@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTimeShift(t *testing.T) {
+	tests := []struct {
+		name    string
+		loop    int
+		times   []int64 // 一遍中每一行的时间戳
+		offsets []int64 // 每一遍的时间偏移量
+	}{
+		{"读一遍", 1, []int64{1000, 1000, 1010}, []int64{0}},
+		{"读三遍", 3, []int64{1000, 1000, 1010, 1020}, []int64{0, 30, 60}},
+		{"间隔不均匀", 2, []int64{1000, 1005, 1100}, []int64{0, 105}},
+		{"只有一个断面", 3, []int64{1000, 1000}, []int64{0, 1, 2}},
+		{"空文件不循环", 0, nil, []int64{0}},
+		{"无限循环", 0, []int64{0, 10}, []int64{0, 20, 40, 60, 80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTimeShift(CsvOptions{Loop: tt.loop})
+			offsets := make([]int64, 0)
+			for {
+				offsets = append(offsets, s.shift(0))
+				for _, ts := range tt.times {
+					s.observe(ts)
+				}
+				// 无限循环时最多读5遍
+				if !s.next() || len(offsets) == 5 {
+					break
+				}
+			}
+			if !reflect.DeepEqual(offsets, tt.offsets) {
+				t.Fatalf("每一遍的时间偏移量为%v, 应为%v", offsets, tt.offsets)
+			}
+		})
+	}
+}
+
+// TestReadAnalogCsvLoop 循环读取CSV时每一遍的断面时间戳按时间跨度平移, 保持单调递增
+func TestReadAnalogCsvLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "REALTIME_FAST_ANALOG.csv")
+	rows := []string{
+		"TIME,P_NUM,AV,AVR,Q,BF,FQ,FAI,MS,TEW,CST",
+		"1000,1,1.0,0.5,False,False,False,0.0,False,A,0",
+		"1000,2,1.0,0.5,False,False,False,0.0,False,A,0",
+		"1010,1,1.0,0.5,False,False,False,0.0,False,A,0",
+		"1020,1,1.0,0.5,False,False,False,0.0,False,A,0",
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan AnalogSection, 16)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go ReadAnalogCsv(wg, path, ch, make(chan bool), nil, CsvOptions{Loop: 3})
+	times, counts := make([]int64, 0), make([]int, 0)
+	for section := range ch {
+		times = append(times, section.Time)
+		counts = append(counts, len(section.Data))
+	}
+	wg.Wait()
+	if want := []int64{1000, 1010, 1020, 1030, 1040, 1050, 1060, 1070, 1080}; !reflect.DeepEqual(times, want) {
+		t.Fatalf("断面时间戳为%v, 应为%v", times, want)
+	}
+	if want := []int{2, 1, 1, 2, 1, 1, 2, 1, 1}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("断面的值数量为%v, 应为%v", counts, want)
+	}
+}
+
+// testSections 时间戳为times的断面, 同时包含模拟量和数字量
+func testSections(times ...int64) []Section {
+	sections := make([]Section, 0, len(times))
+	for _, ts := range times {
+		sections = append(sections, Section{
+			analogOk: true, analog: AnalogSection{Time: ts},
+			digitalOk: true, digital: DigitalSection{Time: ts},
+		})
+	}
+	return sections
+}
+
+func TestSectionStamperRebase(t *testing.T) {
+	options := CsvOptions{TimestampMode: TimestampRebase, anchor: new(rebaseAnchor)}
+	anchor := options.anchor.get()
+	tests := []struct {
+		name   string
+		writes [][]int64 // 每次写入的断面时间戳
+	}{
+		{"逐个写入", [][]int64{{1000}, {1010}, {1020}}},
+		{"批量写入", [][]int64{{5000, 5001, 5002}, {5010}}},
+		{"第一个断面较晚的文件", [][]int64{{9000}, {9100, 9200}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 每个文件一个修改器, 共用同一个对齐时间, 第一个断面都对齐到第一次写入的时间
+			stamper := options.NewStamper()
+			first := tt.writes[0][0]
+			for _, times := range tt.writes {
+				sections := testSections(times...)
+				stamper.Stamp(sections)
+				for i, section := range sections {
+					want := anchor + times[i] - first
+					if section.analog.Time != want || section.digital.Time != want {
+						t.Fatalf("断面%v的时间戳为%v/%v, 应为%v", times[i], section.analog.Time, section.digital.Time, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSectionStamperNow(t *testing.T) {
+	if stamper := (CsvOptions{TimestampMode: TimestampOriginal}).NewStamper(); stamper != nil {
+		t.Fatal("original模式不应修改时间戳")
+	}
+	stamper := CsvOptions{TimestampMode: TimestampNow}.NewStamper()
+	last := int64(0)
+	for _, times := range [][]int64{{1000, 1000, 1001}, {1001}, {2000}} {
+		sections := testSections(times...)
+		stamper.Stamp(sections)
+		for _, section := range sections {
+			if section.analog.Time <= last || section.digital.Time != section.analog.Time {
+				t.Fatalf("时间戳%v不是单调递增的, 上一个为%v", section.analog.Time, last)
+			}
+			last = section.analog.Time
+		}
+	}
+}
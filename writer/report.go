@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ReportSchemaVersion 测试报告格式版本号, 报告字段发生不兼容变化时递增
+const ReportSchemaVersion = 1
+
+// Report 测试报告, 通过 --report 输出, 供CI比较多次测试的结果
+// 所有耗时均为纳秒, 时间为RFC3339格式, 字段始终输出, 没有写入的分类各项统计为0
+type Report struct {
+	SchemaVersion int               `json:"schema_version"`
+	Command       string            `json:"command"`
+	Name          string            `json:"name"`
+	Flags         map[string]string `json:"flags"`
+	Plugin        string            `json:"plugin"`
+	Magic         int32             `json:"magic"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       time.Time         `json:"end_time"`
+	LogoutNs      int64             `json:"logout_ns"`
+	Fast          ReportGroup       `json:"fast"`     // 快采点, 静态写入时为静态点
+	Normal        ReportGroup       `json:"normal"`   // 普通点, 写历史值时为历史点
+	Periodic      *PeriodicConfig   `json:"periodic"` // 周期性写入参数, 其他写入方式为null
+	FaultEvents   []ReportFault     `json:"fault_events"`
+}
+
+// ReportGroup 快采点或普通点的统计
+type ReportGroup struct {
+	Total       ReportStats    `json:"total"` // 模拟量和数字量合并统计, 与日志中的统计一致
+	Analog      ReportStats    `json:"analog"`
+	Digital     ReportStats    `json:"digital"`
+	SleepNs     int64          `json:"sleep_ns"`
+	Schedule    ReportSchedule `json:"schedule"`     // 周期性写入的调度统计, 其他写入方式各项为0
+	SkippedRows int64          `json:"skipped_rows"` // 跳过的CSV行数
+}
+
+// ReportSchedule 周期性写入的调度统计
+type ReportSchedule struct {
+	Writes          int64 `json:"writes"`
+	MissedDeadlines int64 `json:"missed_deadlines"`
+	OverrunNs       int64 `json:"overrun_ns"`
+	MaxOverrunNs    int64 `json:"max_overrun_ns"`
+	DriftNs         int64 `json:"drift_ns"`
+	MaxDriftNs      int64 `json:"max_drift_ns"`
+	Skipped         int64 `json:"skipped"`
+	Coalesced       int64 `json:"coalesced"`
+}
+
+// ReportStats 一类断面的写入统计
+type ReportStats struct {
+	DurationNs         int64              `json:"duration_ns"`
+	SectionCount       int                `json:"section_count"`
+	PNumCount          int                `json:"pnum_count"`
+	AvgNs              int64              `json:"avg_ns"`
+	MinNs              int64              `json:"min_ns"`
+	MaxNs              int64              `json:"max_ns"`
+	P50Ns              int64              `json:"p50_ns"`
+	P95Ns              int64              `json:"p95_ns"`
+	P99Ns              int64              `json:"p99_ns"`
+	P999Ns             int64              `json:"p999_ns"`
+	P9999Ns            int64              `json:"p9999_ns"`
+	FailedSectionCount int                `json:"failed_section_count"`
+	FailedPNumCount    int                `json:"failed_pnum_count"`
+	Errors             []ReportErrorCount `json:"errors"` // 按错误码排序
+}
+
+// ReportErrorCount 每种错误码出现的次数
+type ReportErrorCount struct {
+	Code  int    `json:"code"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ReportFault 故障事件
+type ReportFault struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// NewReportStats 转换一类断面的统计
+func NewReportStats(stats *LatencyStats, fastCache bool) ReportStats {
+	summary := Summary(stats, fastCache)
+	rtn := ReportStats{
+		DurationNs:         int64(summary.All),
+		SectionCount:       summary.Count,
+		PNumCount:          summary.PNum,
+		AvgNs:              int64(summary.Avg),
+		MinNs:              int64(summary.Min),
+		MaxNs:              int64(summary.Max),
+		P50Ns:              int64(summary.P50),
+		P95Ns:              int64(summary.P95),
+		P99Ns:              int64(summary.P99),
+		P999Ns:             int64(summary.P999),
+		P9999Ns:            int64(summary.P9999),
+		FailedSectionCount: int(stats.FailedSectionCount),
+		FailedPNumCount:    int(stats.FailedPNumCount),
+		Errors:             make([]ReportErrorCount, 0),
+	}
+	for code, n := range stats.ErrorCodes {
+		rtn.Errors = append(rtn.Errors, ReportErrorCount{Code: int(code), Name: code.String(), Count: n})
+	}
+	sort.Slice(rtn.Errors, func(i, j int) bool {
+		return rtn.Errors[i].Code < rtn.Errors[j].Code
+	})
+	return rtn
+}
+
+// NewReportGroup 转换快采点或普通点的统计
+func NewReportGroup(stats *WriteStats, fastCache bool) ReportGroup {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	return ReportGroup{
+		Total:       NewReportStats(stats.Total, fastCache),
+		Analog:      NewReportStats(stats.Analog, fastCache),
+		Digital:     NewReportStats(stats.Digital, fastCache),
+		SleepNs:     int64(stats.Sleep),
+		SkippedRows: stats.SkippedRows,
+		Schedule: ReportSchedule{
+			Writes:          stats.Schedule.Writes,
+			MissedDeadlines: stats.Schedule.Missed,
+			OverrunNs:       int64(stats.Schedule.Overrun),
+			MaxOverrunNs:    int64(stats.Schedule.MaxOverrun),
+			DriftNs:         int64(stats.Schedule.Drift),
+			MaxDriftNs:      int64(stats.Schedule.MaxDrift),
+			Skipped:         stats.Schedule.Skipped,
+			Coalesced:       stats.Schedule.Coalesced,
+		},
+	}
+}
+
+// ReportFlags 命令的所有参数, 包括未指定而使用默认值的参数
+func ReportFlags(cmd *cobra.Command) map[string]string {
+	flags := make(map[string]string)
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		flags[flag.Name] = flag.Value.String()
+	})
+	return flags
+}
+
+// NewReport 根据写入统计生成测试报告; periodic为周期性写入参数, 其他写入方式为nil
+func NewReport(
+	cmd *cobra.Command, magic int32, name string, start time.Time, end time.Time,
+	fast *WriteStats, normal *WriteStats,
+	logoutDuration time.Duration, fastCache bool, periodic *PeriodicConfig,
+) Report {
+	pluginPath, _ := cmd.Flags().GetString("plugin")
+	report := Report{
+		SchemaVersion: ReportSchemaVersion,
+		Command:       cmd.Name(),
+		Name:          name,
+		Flags:         ReportFlags(cmd),
+		Plugin:        pluginPath,
+		Magic:         magic,
+		StartTime:     start,
+		EndTime:       end,
+		LogoutNs:      int64(logoutDuration),
+		Fast:          NewReportGroup(fast, fastCache),
+		Normal:        NewReportGroup(normal, fastCache),
+		Periodic:      periodic,
+		FaultEvents:   make([]ReportFault, 0),
+	}
+	faultEventLock.Lock()
+	for _, event := range FaultEventList {
+		report.FaultEvents = append(report.FaultEvents, ReportFault{Time: event.Time, Kind: event.Kind, Message: event.Message})
+	}
+	faultEventLock.Unlock()
+	return report
+}
+
+// WriteReport 测试结束后输出测试报告, --report为空时不输出
+// 路径以.csv结尾时输出CSV格式, 每个分类一行, 否则输出JSON格式; periodic为周期性写入参数, 其他写入方式为nil
+// 执行测试场景时, 报告同时作为当前阶段的结果汇总到场景报告中
+func WriteReport(
+	cmd *cobra.Command, magic int32, name string, start time.Time, end time.Time,
+	fast *WriteStats, normal *WriteStats,
+	logoutDuration time.Duration, fastCache bool, periodic *PeriodicConfig,
+) {
+	report := NewReport(cmd, magic, name, start, end, fast, normal, logoutDuration, fastCache, periodic)
+	if scenarioSession != nil {
+		scenarioSession.report = &report
+	}
+	path, _ := cmd.Flags().GetString("report")
+	if path == "" {
+		return
+	}
+	writeReportFile(path, report, report.MarshalCSV)
+}
+
+// writeReportFile 输出报告文件, 路径以.csv结尾时调用marshalCSV输出CSV格式, 否则输出JSON格式
+func writeReportFile(path string, v interface{}, marshalCSV func() ([]byte, error)) {
+	var data []byte
+	var err error
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		data, err = marshalCSV()
+	} else {
+		buf := new(bytes.Buffer)
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(v)
+		data = buf.Bytes()
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("测试报告输出失败: %v, %v\n", path, err)
+		return
+	}
+	log.Printf("测试报告已输出: %v\n", path)
+}
+
+// ReportCSVHeader CSV格式报告的表头
+var ReportCSVHeader = []string{
+	"schema_version", "command", "name", "plugin", "magic", "start_time", "end_time", "logout_ns", "category",
+	"duration_ns", "section_count", "pnum_count", "avg_ns", "min_ns", "max_ns", "p50_ns", "p95_ns", "p99_ns",
+	"p999_ns", "p9999_ns", "sleep_ns", "failed_section_count", "failed_pnum_count", "errors",
+	"schedule_writes", "missed_deadlines", "overrun_ns", "max_overrun_ns", "drift_ns", "max_drift_ns", "skipped", "coalesced",
+	"cache_size", "overload_protection_write_duration", "overload_protection_write_periodic",
+	"fast_regular_write_periodic", "normal_regular_write_periodic", "fast_cache_batch_size", "skipped_rows",
+}
+
+// MarshalCSV 输出CSV格式的报告, 每个分类(fast_total, fast_analog, ...)一行
+func (r Report) MarshalCSV() ([]byte, error) {
+	buf := new(strings.Builder)
+	w := csv.NewWriter(buf)
+	_ = w.Write(ReportCSVHeader)
+	for _, row := range r.CSVRows() {
+		_ = w.Write(row)
+	}
+	w.Flush()
+	return []byte(buf.String()), w.Error()
+}
+
+// CSVRows CSV格式报告的数据行, 与 ReportCSVHeader 对应
+func (r Report) CSVRows() [][]string {
+	rows := make([][]string, 0, 6)
+	// 周期性写入参数, 其他写入方式为空
+	periodic := make([]string, 6)
+	if r.Periodic != nil {
+		periodic = []string{
+			strconv.Itoa(r.Periodic.CacheSize), strconv.Itoa(r.Periodic.OverloadProtectionWriteDuration), strconv.Itoa(r.Periodic.OverloadProtectionWritePeriodic),
+			strconv.Itoa(r.Periodic.FastRegularWritePeriodic), strconv.Itoa(r.Periodic.NormalRegularWritePeriodic), strconv.Itoa(r.Periodic.FastCacheBatchSize),
+		}
+	}
+	groups := []struct {
+		name  string
+		group ReportGroup
+	}{{"fast", r.Fast}, {"normal", r.Normal}}
+	for _, g := range groups {
+		categories := []struct {
+			name  string
+			stats ReportStats
+		}{{"total", g.group.Total}, {"analog", g.group.Analog}, {"digital", g.group.Digital}}
+		for _, c := range categories {
+			errs := make([]string, 0, len(c.stats.Errors))
+			for _, e := range c.stats.Errors {
+				errs = append(errs, fmt.Sprintf("%d:%d", e.Code, e.Count))
+			}
+			rows = append(rows, append([]string{
+				strconv.Itoa(r.SchemaVersion), r.Command, r.Name, r.Plugin, strconv.Itoa(int(r.Magic)),
+				r.StartTime.Format(time.RFC3339Nano), r.EndTime.Format(time.RFC3339Nano), strconv.FormatInt(r.LogoutNs, 10),
+				g.name + "_" + c.name,
+				strconv.FormatInt(c.stats.DurationNs, 10), strconv.Itoa(c.stats.SectionCount), strconv.Itoa(c.stats.PNumCount),
+				strconv.FormatInt(c.stats.AvgNs, 10), strconv.FormatInt(c.stats.MinNs, 10), strconv.FormatInt(c.stats.MaxNs, 10),
+				strconv.FormatInt(c.stats.P50Ns, 10), strconv.FormatInt(c.stats.P95Ns, 10), strconv.FormatInt(c.stats.P99Ns, 10),
+				strconv.FormatInt(c.stats.P999Ns, 10), strconv.FormatInt(c.stats.P9999Ns, 10),
+				strconv.FormatInt(g.group.SleepNs, 10), strconv.Itoa(c.stats.FailedSectionCount), strconv.Itoa(c.stats.FailedPNumCount),
+				strings.Join(errs, ";"),
+				strconv.FormatInt(g.group.Schedule.Writes, 10), strconv.FormatInt(g.group.Schedule.MissedDeadlines, 10),
+				strconv.FormatInt(g.group.Schedule.OverrunNs, 10), strconv.FormatInt(g.group.Schedule.MaxOverrunNs, 10),
+				strconv.FormatInt(g.group.Schedule.DriftNs, 10), strconv.FormatInt(g.group.Schedule.MaxDriftNs, 10),
+				strconv.FormatInt(g.group.Schedule.Skipped, 10), strconv.FormatInt(g.group.Schedule.Coalesced, 10),
+			}, append(periodic, strconv.FormatInt(g.group.SkippedRows, 10))...))
+		}
+	}
+	return rows
+}
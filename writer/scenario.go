@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// ScenarioSchemaVersion 测试场景报告格式版本号, 报告字段发生不兼容变化时递增
+const ScenarioSchemaVersion = 1
+
+// Scenario 测试场景, 通过 run 命令按顺序执行多个写入阶段, 所有阶段共用一个插件和一次登录
+type Scenario struct {
+	Name   string                 `yaml:"name"`   // 场景名称
+	Plugin string                 `yaml:"plugin"` // 插件路径, 所有阶段共用
+	Param  string                 `yaml:"param"`  // 登录参数, 阶段中没有指定param时使用
+	Report string                 `yaml:"report"` // 场景报告输出路径, 以.csv结尾输出CSV格式, 为空表示不输出
+	Flags  map[string]interface{} `yaml:"flags"`  // 所有阶段共用的参数, 只对支持该参数的命令生效
+	Phases []ScenarioPhase        `yaml:"phases"`
+}
+
+// ScenarioPhase 测试场景的一个阶段, 对应一次写入命令
+type ScenarioPhase struct {
+	Name       string                 `yaml:"name"`        // 阶段名称, 为空时使用命令名称
+	Command    string                 `yaml:"command"`     // 写入命令, 如 static_write, rt_periodic_write
+	Magic      *int32                 `yaml:"magic"`       // 魔数, 等同于 flags 中的 magic
+	UnitNumber *int64                 `yaml:"unit_number"` // 机组数量, 等同于 flags 中的 unit_number
+	Param      *string                `yaml:"param"`       // 登录参数, 与当前登录的参数不同时重新登录
+	Duration   time.Duration          `yaml:"duration"`    // 阶段时长, 等同于 flags 中的 duration
+	Flags      map[string]interface{} `yaml:"flags"`       // 命令参数, 键为参数名称
+}
+
+// ScenarioCommands 测试场景支持的写入命令
+var ScenarioCommands = map[string]*cobra.Command{
+	"static_write":       staticWrite,
+	"rt_fast_write":      rtFastWrite,
+	"rt_periodic_write":  rtPeriodicWrite,
+	"his_fast_write":     hisFastWrite,
+	"his_periodic_write": hisPeriodicWrite,
+}
+
+// LoadScenario 读取并检查测试场景文件, 文件为YAML格式(JSON也是合法的YAML), 不支持TOML
+func LoadScenario(path string) (*Scenario, error) {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return nil, fmt.Errorf("不支持TOML格式的测试场景: %v, 请使用YAML或JSON", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取测试场景失败: %v, %v", path, err)
+	}
+	scenario := new(Scenario)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(scenario); err != nil {
+		return nil, fmt.Errorf("解析测试场景失败: %v, %v", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = path
+	}
+	if err := scenario.Check(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// Check 检查测试场景, 每个阶段的参数都会试着设置一次, 避免执行到一半才发现参数错误
+func (s *Scenario) Check() error {
+	if s.Plugin == "" {
+		return fmt.Errorf("测试场景没有指定插件(plugin)")
+	}
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("测试场景没有任何阶段(phases)")
+	}
+	for i := range s.Phases {
+		phase := &s.Phases[i]
+		cmd, ok := ScenarioCommands[phase.Command]
+		if !ok {
+			return fmt.Errorf("阶段%v: 不支持的命令: %v", i+1, phase.Command)
+		}
+		if phase.Name == "" {
+			phase.Name = phase.Command
+		}
+		if phase.Duration < 0 {
+			return fmt.Errorf("阶段%v(%v): 阶段时长不能小于0: %v", i+1, phase.Name, phase.Duration)
+		}
+		if phase.Duration > 0 && cmd == staticWrite {
+			return fmt.Errorf("阶段%v(%v): 静态写入不支持阶段时长", i+1, phase.Name)
+		}
+		if _, ok := phase.Flags["plugin"]; ok {
+			return fmt.Errorf("阶段%v(%v): 插件由测试场景统一指定, 阶段中不能指定plugin", i+1, phase.Name)
+		}
+		err := s.apply(cmd, phase)
+		resetFlags(cmd)
+		if err != nil {
+			return fmt.Errorf("阶段%v(%v): %v", i+1, phase.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply 将阶段的参数设置到命令上, 优先级: 阶段的magic/unit_number/param/duration > 阶段的flags > 场景的flags > 场景的param
+func (s *Scenario) apply(cmd *cobra.Command, phase *ScenarioPhase) error {
+	resetFlags(cmd)
+	flags := map[string]string{"param": s.Param}
+	for name, value := range s.Flags {
+		if cmd.Flags().Lookup(name) != nil {
+			flags[name] = fmt.Sprint(value)
+		}
+	}
+	for name, value := range phase.Flags {
+		if cmd.Flags().Lookup(name) == nil {
+			return fmt.Errorf("命令 %v 不支持参数: %v", cmd.Name(), name)
+		}
+		flags[name] = fmt.Sprint(value)
+	}
+	flags["plugin"] = s.Plugin
+	if phase.Magic != nil {
+		flags["magic"] = strconv.Itoa(int(*phase.Magic))
+	}
+	if phase.UnitNumber != nil {
+		flags["unit_number"] = strconv.FormatInt(*phase.UnitNumber, 10)
+	}
+	if phase.Param != nil {
+		flags["param"] = *phase.Param
+	}
+	if phase.Duration > 0 {
+		flags["duration"] = phase.Duration.String()
+	}
+
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := cmd.Flags().Set(name, flags[name]); err != nil {
+			return fmt.Errorf("参数错误: %v=%v, %v", name, flags[name], err)
+		}
+	}
+	return nil
+}
+
+// resetFlags 将命令的参数恢复为默认值, 同一个命令可以在多个阶段中使用
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		_ = flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+}
+
+// ScenarioSession 测试场景共用的插件登录状态, 执行测试场景时不为nil
+type ScenarioSession struct {
+	loggedIn bool
+	param    string  // 当前登录使用的参数
+	report   *Report // 当前阶段的测试报告, 由 WriteReport 设置
+}
+
+// scenarioSession 正在执行的测试场景, 单独执行写入命令时为nil
+var scenarioSession *ScenarioSession
+
+// Login 登录插件, 已经使用相同的param登录时直接返回, param不同时先登出再重新登录
+func (s *ScenarioSession) Login(param string) int {
+	if s.loggedIn && s.param == param {
+		return 0
+	}
+	if s.loggedIn {
+		log.Printf("登录参数变化, 重新登录: %v -> %v\n", s.param, param)
+		s.Logout()
+	}
+	rtn := GlobalPlugin.Login(param)
+	if rtn == 0 {
+		s.loggedIn = true
+		s.param = param
+	}
+	return rtn
+}
+
+// Logout 登出插件, 没有登录时忽略
+func (s *ScenarioSession) Logout() {
+	if !s.loggedIn {
+		return
+	}
+	GlobalPlugin.Logout()
+	s.loggedIn = false
+}
+
+// ScenarioReport 测试场景报告, 包括每个阶段的测试报告
+type ScenarioReport struct {
+	SchemaVersion int                   `json:"schema_version"`
+	Scenario      string                `json:"scenario"`
+	Plugin        string                `json:"plugin"`
+	StartTime     time.Time             `json:"start_time"`
+	EndTime       time.Time             `json:"end_time"`
+	Interrupted   bool                  `json:"interrupted"` // 是否因为中断信号跳过了之后的阶段
+	Phases        []ScenarioPhaseReport `json:"phases"`
+}
+
+// ScenarioPhaseReport 一个阶段的执行结果
+type ScenarioPhaseReport struct {
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Completed bool      `json:"completed"` // 是否完成写入, 加载插件或登录失败等情况下为false
+	Report    *Report   `json:"report"`    // 阶段的测试报告, 没有完成写入时为null
+}
+
+// MarshalCSV 输出CSV格式的场景报告, 每个阶段的每个分类一行, 没有完成写入的阶段不输出
+func (r ScenarioReport) MarshalCSV() ([]byte, error) {
+	buf := new(strings.Builder)
+	w := csv.NewWriter(buf)
+	_ = w.Write(append([]string{"scenario", "phase", "phase_name"}, ReportCSVHeader...))
+	for i, phase := range r.Phases {
+		if phase.Report == nil {
+			continue
+		}
+		for _, row := range phase.Report.CSVRows() {
+			_ = w.Write(append([]string{r.Scenario, strconv.Itoa(i + 1), phase.Name}, row...))
+		}
+	}
+	w.Flush()
+	return []byte(buf.String()), w.Error()
+}
+
+// RunScenario 按顺序执行测试场景的所有阶段, 收到中断信号时当前阶段平滑退出, 之后的阶段不再执行
+// 阶段失败(没有完成写入)时继续执行之后的阶段, 输出场景报告后返回错误
+func RunScenario(scenario *Scenario) error {
+	if err := InitGlobalPlugin(scenario.Plugin); err != nil {
+		return err
+	}
+	scenarioSession = new(ScenarioSession)
+	defer func() {
+		scenarioSession = nil
+	}()
+
+	// 中断信号由当前阶段的写入协程处理, 这里只记录是否收到过
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	report := ScenarioReport{
+		SchemaVersion: ScenarioSchemaVersion,
+		Scenario:      scenario.Name,
+		Plugin:        scenario.Plugin,
+		StartTime:     time.Now(),
+		Phases:        make([]ScenarioPhaseReport, 0, len(scenario.Phases)),
+	}
+	for i := range scenario.Phases {
+		phase := &scenario.Phases[i]
+		cmd := ScenarioCommands[phase.Command]
+		if err := scenario.apply(cmd, phase); err != nil {
+			return fmt.Errorf("阶段%v(%v): %v", i+1, phase.Name, err)
+		}
+		log.Printf("测试场景: %v - 阶段%v/%v开始: %v, 命令: %v\n", scenario.Name, i+1, len(scenario.Phases), phase.Name, phase.Command)
+
+		ResetFaultEvents()
+		scenarioSession.report = nil
+		result := ScenarioPhaseReport{Name: phase.Name, Command: phase.Command, StartTime: time.Now()}
+		cmd.Run(cmd, nil)
+		result.EndTime = time.Now()
+		result.Report = scenarioSession.report
+		result.Completed = result.Report != nil
+		report.Phases = append(report.Phases, result)
+		resetFlags(cmd)
+
+		log.Printf("测试场景: %v - 阶段%v/%v结束: %v, 耗时: %v, 完成写入: %v\n", scenario.Name, i+1, len(scenario.Phases), phase.Name, result.EndTime.Sub(result.StartTime), result.Completed)
+		select {
+		case <-interrupt:
+			report.Interrupted = true
+		default:
+		}
+		if report.Interrupted {
+			log.Printf("测试场景: %v - 收到中断信号, 跳过之后的%v个阶段\n", scenario.Name, len(scenario.Phases)-i-1)
+			break
+		}
+	}
+
+	logoutStart := time.Now()
+	scenarioSession.Logout()
+	log.Println("logout time: ", time.Since(logoutStart))
+	report.EndTime = time.Now()
+	ScenarioSummary(report)
+	if scenario.Report != "" {
+		writeReportFile(scenario.Report, report, report.MarshalCSV)
+	}
+	failed := make([]string, 0)
+	for i, phase := range report.Phases {
+		if !phase.Completed {
+			failed = append(failed, fmt.Sprintf("阶段%v(%v)", i+1, phase.Name))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("测试场景: %v - 未完成写入的阶段: %v", scenario.Name, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ScenarioSummary 输出测试场景的汇总, 每个阶段一行
+func ScenarioSummary(report ScenarioReport) {
+	log.Printf("测试场景: %v - 开始时间: %v, 结束时间: %v, 总耗时: %v, 阶段数量: %v\n",
+		report.Scenario, report.StartTime.Format(time.RFC3339), report.EndTime.Format(time.RFC3339), report.EndTime.Sub(report.StartTime), len(report.Phases),
+	)
+	for i, phase := range report.Phases {
+		if phase.Report == nil {
+			log.Printf("\t阶段%v: %v(%v), 耗时: %v, 未完成写入\n", i+1, phase.Name, phase.Command, phase.EndTime.Sub(phase.StartTime))
+			continue
+		}
+		r := phase.Report
+		log.Printf("\t阶段%v: %v(%v), 耗时: %v, 断面数量: %v, PNUM数量: %v, 失败断面数量: %v, 故障事件数量: %v\n",
+			i+1, phase.Name, phase.Command, phase.EndTime.Sub(phase.StartTime),
+			r.Fast.Total.SectionCount+r.Normal.Total.SectionCount, r.Fast.Total.PNumCount+r.Normal.Total.PNumCount,
+			r.Fast.Total.FailedSectionCount+r.Normal.Total.FailedSectionCount, len(r.FaultEvents),
+		)
+	}
+	if report.Interrupted {
+		log.Println("测试场景因中断信号提前结束")
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 调度落后于计划时间时的追赶策略
+const (
+	CatchUpBurst    = "burst"    // 不睡眠, 连续写入直到追上计划时间
+	CatchUpSkip     = "skip"     // 跳过已经错过的计划时间, 从下一个周期开始写入, 不丢弃断面
+	CatchUpCoalesce = "coalesce" // 将已经错过的周期对应的断面合并为一次批量写入, 只有实时快采点支持
+)
+
+// DefaultCatchUp 默认的追赶策略
+const DefaultCatchUp = CatchUpBurst
+
+// CheckCatchUp 检查追赶策略是否合法
+func CheckCatchUp(policy string) error {
+	switch policy {
+	case CatchUpBurst, CatchUpSkip, CatchUpCoalesce:
+		return nil
+	default:
+		return fmt.Errorf("追赶策略错误: %v, 可选值: burst, skip, coalesce", policy)
+	}
+}
+
+// ScheduleOptions 周期性写入的调度参数
+type ScheduleOptions struct {
+	CatchUp string        // 追赶策略
+	Spin    time.Duration // 距离计划时间不超过该时长时忙等而不是睡眠, 为0时不忙等
+}
+
+// Scheduler 按绝对时间调度周期性写入, 第n次写入的计划时间为 开始时间+n*写入周期
+// 睡眠误差不会累计, 写入耗时超出写入周期时按追赶策略处理
+type Scheduler struct {
+	options ScheduleOptions
+	next    time.Time
+}
+
+func NewScheduler(options ScheduleOptions) *Scheduler {
+	return &Scheduler{options: options}
+}
+
+// Wait 等待到下一次写入的计划时间, 返回计划时间和实际等待的时长, 第一次调用时立即返回
+// 开启忙等时先睡眠到计划时间前的spin时长, 剩余时间忙等, 用于亚毫秒级的写入周期
+func (s *Scheduler) Wait() (time.Time, time.Duration) {
+	now := time.Now()
+	if s.next.IsZero() {
+		s.next = now
+	}
+	if !now.Before(s.next) {
+		return s.next, 0
+	}
+	wait := s.next.Sub(now)
+	if wait > s.options.Spin {
+		time.Sleep(wait - s.options.Spin)
+	}
+	for time.Now().Before(s.next) {
+		// 忙等
+	}
+	return s.next, time.Since(now)
+}
+
+// Advance 本次写入结束, 计划时间增加一个写入周期, 返回下一次写入的周期数和跳过的周期数
+// 写入周期数大于1表示按coalesce策略将错过的周期合并到下一次写入
+func (s *Scheduler) Advance(period time.Duration) (int, int) {
+	s.next = s.next.Add(period)
+	behind := time.Since(s.next)
+	if behind <= 0 || period <= 0 {
+		return 1, 0
+	}
+	switch s.options.CatchUp {
+	case CatchUpSkip:
+		skipped := int((behind + period - 1) / period)
+		s.next = s.next.Add(time.Duration(skipped) * period)
+		return 1, skipped
+	case CatchUpCoalesce:
+		coalesced := int(behind / period)
+		s.next = s.next.Add(time.Duration(coalesced) * period)
+		return 1 + coalesced, 0
+	default:
+		return 1, 0
+	}
+}
+
+// ScheduleStats 周期性写入的调度统计
+type ScheduleStats struct {
+	Writes     int64         // 写入次数
+	Missed     int64         // 错过截止时间的次数, 即写入耗时超出写入周期的次数
+	Overrun    time.Duration // 写入耗时超出写入周期的总时长
+	MaxOverrun time.Duration // 写入耗时超出写入周期的最大时长
+	Drift      time.Duration // 最后一次写入的实际时间与计划时间的偏差, 即累计偏差
+	MaxDrift   time.Duration // 实际时间晚于计划时间的最大偏差
+	Skipped    int64         // 按skip策略跳过的周期数
+	Coalesced  int64         // 按coalesce策略合并到其他写入的周期数
+}
+
+func (s *ScheduleStats) record(drift time.Duration, overrun time.Duration) {
+	s.Writes++
+	s.Drift = drift
+	if drift > s.MaxDrift {
+		s.MaxDrift = drift
+	}
+	if overrun > 0 {
+		s.Missed++
+		s.Overrun += overrun
+		if overrun > s.MaxOverrun {
+			s.MaxOverrun = overrun
+		}
+	}
+}
+
+// Merge 将other中的统计合并到s, 累计偏差取偏差较大的写入协程
+func (s *ScheduleStats) Merge(other ScheduleStats) {
+	if other.Writes == 0 {
+		return
+	}
+	if s.Writes == 0 || other.Drift > s.Drift {
+		s.Drift = other.Drift
+	}
+	s.Writes += other.Writes
+	s.Missed += other.Missed
+	s.Overrun += other.Overrun
+	s.Skipped += other.Skipped
+	s.Coalesced += other.Coalesced
+	if other.MaxOverrun > s.MaxOverrun {
+		s.MaxOverrun = other.MaxOverrun
+	}
+	if other.MaxDrift > s.MaxDrift {
+		s.MaxDrift = other.MaxDrift
+	}
+}
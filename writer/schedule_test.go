@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerAdvance(t *testing.T) {
+	const period = 100 * time.Millisecond
+	tests := []struct {
+		name    string
+		catchUp string
+		behind  time.Duration // 本次写入结束时落后于下一次计划时间的时长, 为负数表示还没到计划时间
+		writes  int
+		skipped int
+		next    time.Duration // 下一次计划时间相对当前时间的大致偏移
+	}{
+		{"未落后", CatchUpBurst, -time.Second, 1, 0, time.Second},
+		{"burst连续写入", CatchUpBurst, 250 * time.Millisecond, 1, 0, -250 * time.Millisecond},
+		{"skip跳过错过的周期", CatchUpSkip, 250 * time.Millisecond, 1, 3, 50 * time.Millisecond},
+		{"skip未落后", CatchUpSkip, -time.Second, 1, 0, time.Second},
+		{"coalesce合并错过的周期", CatchUpCoalesce, 250 * time.Millisecond, 3, 0, -50 * time.Millisecond},
+		{"coalesce落后不足一个周期", CatchUpCoalesce, 50 * time.Millisecond, 1, 0, -50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScheduler(ScheduleOptions{CatchUp: tt.catchUp})
+			s.next = time.Now().Add(-tt.behind - period)
+			writes, skipped := s.Advance(period)
+			if writes != tt.writes || skipped != tt.skipped {
+				t.Fatalf("Advance()=(%v, %v), 应为(%v, %v)", writes, skipped, tt.writes, tt.skipped)
+			}
+			// 允许测试执行本身的耗时
+			if next := time.Until(s.next); next > tt.next || next < tt.next-20*time.Millisecond {
+				t.Fatalf("下一次计划时间偏移%v, 应约为%v", next, tt.next)
+			}
+		})
+	}
+}
+
+func TestSchedulerWait(t *testing.T) {
+	s := NewScheduler(ScheduleOptions{CatchUp: CatchUpBurst, Spin: time.Millisecond})
+	first, wait := s.Wait()
+	if wait != 0 {
+		t.Fatalf("第一次调用应立即返回, 等待了%v", wait)
+	}
+	s.Advance(5 * time.Millisecond)
+	intended, _ := s.Wait()
+	if intended.Sub(first) != 5*time.Millisecond {
+		t.Fatalf("计划时间间隔为%v, 应为5ms", intended.Sub(first))
+	}
+	if now := time.Now(); now.Before(intended) {
+		t.Fatalf("返回时间%v早于计划时间%v", now, intended)
+	}
+}
@@ -0,0 +1,99 @@
+// 使用Go实现的示例插件, 功能与 plugin_example/write_plugin.c 相同
+// 既可以编译为Go插件, 也可以作为外部进程插件运行:
+// * Go插件: go build -buildmode=plugin -o libgowrite_plugin.so ./sdk/example, 使用 --plugin=goplugin://./libgowrite_plugin.so
+// * 外部进程插件: go build -o example_plugin ./sdk/example, 使用 --plugin=exec://./example_plugin
+package main
+
+import (
+	"log"
+	"os"
+	"writer/sdk"
+)
+
+// ExampleWriter 示例插件
+type ExampleWriter struct{}
+
+// NewWriter Go插件导出的构造函数
+func NewWriter() sdk.Writer {
+	return &ExampleWriter{}
+}
+
+func (w *ExampleWriter) Info() sdk.PluginInfo {
+	info := sdk.DefaultPluginInfo()
+	info.Vendor = "example-go"
+	return info
+}
+
+func (w *ExampleWriter) Login(param string) int {
+	log.Printf("rtdb login: param: %v\n", param)
+	return 0
+}
+
+func (w *ExampleWriter) Logout() {
+	log.Println("rtdb logout!")
+}
+
+func (w *ExampleWriter) WriteRtAnalog(magic int32, unitId int64, time int64, data []sdk.Analog, isFast bool) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteRtDigital(magic int32, unitId int64, time int64, data []sdk.Digital, isFast bool) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]sdk.Analog) error {
+	if len(times) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]sdk.Digital) error {
+	if len(times) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteHisAnalog(magic int32, unitId int64, time int64, data []sdk.Analog) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteHisDigital(magic int32, unitId int64, time int64, data []sdk.Digital) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	return nil
+}
+
+func (w *ExampleWriter) WriteStaticAnalog(magic int32, unitId int64, data []sdk.StaticAnalog, typ int64) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	log.Printf("write static analog: unit_id: %v, type: %v, count: %v, first: %v %v\n", unitId, typ, len(data), data[0].PN, data[0].DESC)
+	return nil
+}
+
+func (w *ExampleWriter) WriteStaticDigital(magic int32, unitId int64, data []sdk.StaticDigital, typ int64) error {
+	if len(data) == 0 {
+		return sdk.NewError(sdk.ErrInvalidData, "empty section")
+	}
+	log.Printf("write static digital: unit_id: %v, type: %v, count: %v, first: %v %v\n", unitId, typ, len(data), data[0].PN, data[0].DESC)
+	return nil
+}
+
+// 作为外部进程插件运行时, 通过标准输入输出与写入程序通信, 日志输出到标准错误输出
+func main() {
+	if err := sdk.Serve(os.Stdin, os.Stdout, NewWriter()); err != nil {
+		log.Fatalln(err)
+	}
+}
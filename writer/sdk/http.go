@@ -0,0 +1,239 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPContentType 网络写入协议的请求和响应类型
+const HTTPContentType = "application/x-protobuf"
+
+// HTTPServicePath 网络写入协议的路径前缀, 完整路径为 {base_url}/rtdb_writer.v1.Writer/{方法名}
+const HTTPServicePath = "/rtdb_writer.v1.Writer/"
+
+// HTTPClient 网络写入协议客户端, 通过HTTP发送protobuf编码的请求, 消息定义见 rtdb_writer.proto
+// 可以被多个goroutine同时使用
+type HTTPClient struct {
+	base   string
+	client *http.Client
+}
+
+// NewHTTPClient 创建客户端, base为服务地址, 如: http://127.0.0.1:8080, timeout为单个请求的超时时间
+func NewHTTPClient(base string, timeout time.Duration) *HTTPClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// 多个机组同时写入时复用连接
+	transport.MaxIdleConnsPerHost = 1024
+	return &HTTPClient{
+		base:   strings.TrimSuffix(base, "/"),
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+// call 发送请求并返回响应体, HTTP状态码不是200时按 rtdb_writer.proto 中的约定转换为错误码
+func (c *HTTPClient) call(method string, body []byte) ([]byte, error) {
+	resp, err := c.client.Post(c.base+HTTPServicePath+method, HTTPContentType, bytes.NewReader(body))
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, NewError(ErrTimeout, "%v请求超时: %v", method, err)
+		}
+		return nil, NewError(ErrConnection, "%v请求失败: %v", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewError(ErrConnection, "%v响应读取失败: %v", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewError(HTTPStatusErrorCode(resp.StatusCode), "%v请求失败: %v %v", method, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// write 发送写入请求并解析 WriteResponse
+func (c *HTTPClient) write(method string, body []byte) error {
+	data, err := c.call(method, body)
+	if err != nil {
+		return err
+	}
+	code, message, err := decodeCode(data)
+	if err != nil {
+		return NewError(ErrInvalidData, "%v响应解析失败: %v", method, err)
+	}
+	if ErrorCode(code) != Ok {
+		return &Error{Code: ErrorCode(code), Message: message}
+	}
+	return nil
+}
+
+// HTTPStatusErrorCode HTTP状态码对应的错误码
+func HTTPStatusErrorCode(status int) ErrorCode {
+	switch status {
+	case http.StatusOK:
+		return Ok
+	case http.StatusBadRequest:
+		return ErrInvalidData
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return ErrUnsupported
+	case http.StatusServiceUnavailable:
+		return ErrOverload
+	default:
+		return ErrUnknown
+	}
+}
+
+// QueryInfo 查询服务端的插件信息
+func (c *HTTPClient) QueryInfo() (PluginInfo, error) {
+	data, err := c.call("Info", nil)
+	if err != nil {
+		return PluginInfo{}, err
+	}
+	info, err := decodePluginInfo(data)
+	if err != nil {
+		return info, NewError(ErrInvalidData, "插件信息解析失败: %v", err)
+	}
+	return info, nil
+}
+
+func (c *HTTPClient) Login(param string) int {
+	data, err := c.call("Login", encodeLoginRequest(param))
+	if err != nil {
+		return -1
+	}
+	code, _, err := decodeCode(data)
+	if err != nil {
+		return -1
+	}
+	return int(code)
+}
+
+func (c *HTTPClient) Logout() {
+	_, _ = c.call("Logout", nil)
+	c.client.CloseIdleConnections()
+}
+
+func (c *HTTPClient) WriteRtAnalog(magic int32, unitId int64, time int64, data []Analog, isFast bool) error {
+	return c.write("WriteRtAnalog", encodeWriteAnalogRequest(magic, unitId, isFast, []int64{time}, [][]Analog{data}))
+}
+
+func (c *HTTPClient) WriteRtDigital(magic int32, unitId int64, time int64, data []Digital, isFast bool) error {
+	return c.write("WriteRtDigital", encodeWriteDigitalRequest(magic, unitId, isFast, []int64{time}, [][]Digital{data}))
+}
+
+func (c *HTTPClient) WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]Analog) error {
+	return c.write("WriteRtAnalogList", encodeWriteAnalogRequest(magic, unitId, true, times, data))
+}
+
+func (c *HTTPClient) WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]Digital) error {
+	return c.write("WriteRtDigitalList", encodeWriteDigitalRequest(magic, unitId, true, times, data))
+}
+
+func (c *HTTPClient) WriteHisAnalog(magic int32, unitId int64, time int64, data []Analog) error {
+	return c.write("WriteHisAnalog", encodeWriteAnalogRequest(magic, unitId, false, []int64{time}, [][]Analog{data}))
+}
+
+func (c *HTTPClient) WriteHisDigital(magic int32, unitId int64, time int64, data []Digital) error {
+	return c.write("WriteHisDigital", encodeWriteDigitalRequest(magic, unitId, false, []int64{time}, [][]Digital{data}))
+}
+
+func (c *HTTPClient) WriteStaticAnalog(magic int32, unitId int64, data []StaticAnalog, typ int64) error {
+	return c.write("WriteStaticAnalog", encodeWriteStaticAnalogRequest(magic, unitId, data, typ))
+}
+
+func (c *HTTPClient) WriteStaticDigital(magic int32, unitId int64, data []StaticDigital, typ int64) error {
+	return c.write("WriteStaticDigital", encodeWriteStaticDigitalRequest(magic, unitId, data, typ))
+}
+
+// NewHTTPHandler 在HTTP上提供网络写入协议服务, 请求转发给writer
+// 请求体解析失败时返回400, 未知方法返回404, 写入失败通过 WriteResponse.code 返回
+func NewHTTPHandler(writer Writer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+			return
+		}
+		method, ok := strings.CutPrefix(r.URL.Path, HTTPServicePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, status, err := handleHTTP(writer, method, body)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", HTTPContentType)
+		_, _ = w.Write(resp)
+	})
+}
+
+// handleHTTP 处理一个请求, 返回响应体; 请求无法处理时返回HTTP状态码和错误
+func handleHTTP(writer Writer, method string, body []byte) ([]byte, int, error) {
+	kind := ""
+	switch method {
+	case "Info":
+		return encodePluginInfo(InfoOf(writer)), http.StatusOK, nil
+	case "Login":
+		param, err := decodeLoginRequest(body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Login请求解析失败: %v", err)
+		}
+		return encodeCode(int32(writer.Login(param)), ""), http.StatusOK, nil
+	case "Logout":
+		writer.Logout()
+		return nil, http.StatusOK, nil
+	case "WriteRtAnalog", "WriteRtAnalogList", "WriteHisAnalog":
+		kind = "analog"
+	case "WriteRtDigital", "WriteRtDigitalList", "WriteHisDigital":
+		kind = "digital"
+	case "WriteStaticAnalog":
+		kind = "static_analog"
+	case "WriteStaticDigital":
+		kind = "static_digital"
+	default:
+		return nil, http.StatusNotFound, fmt.Errorf("未知方法: %v", method)
+	}
+
+	req, err := decodeWriteRequest(body, kind)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("%v请求解析失败: %v", method, err)
+	}
+	// 单断面接口必须只包含1个断面
+	switch method {
+	case "WriteRtAnalog", "WriteRtDigital", "WriteHisAnalog", "WriteHisDigital":
+		if len(req.times) != 1 {
+			return nil, http.StatusBadRequest, fmt.Errorf("%v请求应包含1个断面, 实际包含%v个", method, len(req.times))
+		}
+	}
+
+	switch method {
+	case "WriteRtAnalog":
+		err = writer.WriteRtAnalog(req.magic, req.unitId, req.times[0], req.analogs[0], req.isFast)
+	case "WriteRtDigital":
+		err = writer.WriteRtDigital(req.magic, req.unitId, req.times[0], req.digitals[0], req.isFast)
+	case "WriteRtAnalogList":
+		err = writer.WriteRtAnalogList(req.magic, req.unitId, req.times, req.analogs)
+	case "WriteRtDigitalList":
+		err = writer.WriteRtDigitalList(req.magic, req.unitId, req.times, req.digitals)
+	case "WriteHisAnalog":
+		err = writer.WriteHisAnalog(req.magic, req.unitId, req.times[0], req.analogs[0])
+	case "WriteHisDigital":
+		err = writer.WriteHisDigital(req.magic, req.unitId, req.times[0], req.digitals[0])
+	case "WriteStaticAnalog":
+		err = writer.WriteStaticAnalog(req.magic, req.unitId, req.staticAnalogs, req.typ)
+	case "WriteStaticDigital":
+		err = writer.WriteStaticDigital(req.magic, req.unitId, req.staticDigitals, req.typ)
+	}
+	return encodeCode(int32(ErrorCodeOf(err)), errorMessage(err)), http.StatusOK, nil
+}
@@ -0,0 +1,509 @@
+package sdk
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 网络写入协议的protobuf编解码, 消息定义见 rtdb_writer.proto
+// 直接使用protowire编解码, 不依赖protoc生成代码; pb_test.go 由proto文件生成消息描述, 检查字段编号和类型与proto文件一致
+
+// pbEncoder protobuf消息编码器, 与proto3一致, 标量字段为零值时不编码
+type pbEncoder struct {
+	buf []byte
+}
+
+func (e *pbEncoder) varint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.buf = protowire.AppendTag(e.buf, num, protowire.VarintType)
+	e.buf = protowire.AppendVarint(e.buf, v)
+}
+
+func (e *pbEncoder) int64(num protowire.Number, v int64) { e.varint(num, uint64(v)) }
+func (e *pbEncoder) int32(num protowire.Number, v int32) { e.varint(num, uint64(int64(v))) }
+
+func (e *pbEncoder) boolean(num protowire.Number, v bool) {
+	if v {
+		e.varint(num, 1)
+	}
+}
+
+func (e *pbEncoder) float(num protowire.Number, v float32) {
+	bits := math.Float32bits(v)
+	if bits == 0 {
+		return
+	}
+	e.buf = protowire.AppendTag(e.buf, num, protowire.Fixed32Type)
+	e.buf = protowire.AppendFixed32(e.buf, bits)
+}
+
+func (e *pbEncoder) str(num protowire.Number, v string) {
+	if v == "" {
+		return
+	}
+	e.buf = protowire.AppendTag(e.buf, num, protowire.BytesType)
+	e.buf = protowire.AppendString(e.buf, v)
+}
+
+// message 编码嵌套消息, 用于repeated字段时即使为空消息也会编码
+func (e *pbEncoder) message(num protowire.Number, encode func(e *pbEncoder)) {
+	sub := &pbEncoder{}
+	encode(sub)
+	e.buf = protowire.AppendTag(e.buf, num, protowire.BytesType)
+	e.buf = protowire.AppendBytes(e.buf, sub.buf)
+}
+
+// pbField 解码后的字段, 变长整数和定长整数保存在v中, 长度分隔类型保存在data中
+type pbField struct {
+	num  protowire.Number
+	typ  protowire.Type
+	v    uint64
+	data []byte
+}
+
+func (f pbField) int64() int64     { return int64(f.v) }
+func (f pbField) int32() int32     { return int32(f.v) }
+func (f pbField) boolean() bool    { return f.v != 0 }
+func (f pbField) float() float32   { return math.Float32frombits(uint32(f.v)) }
+func (f pbField) str() string      { return string(f.data) }
+func (f pbField) isBytes() bool    { return f.typ == protowire.BytesType }
+func (f pbField) isFixed32() bool  { return f.typ == protowire.Fixed32Type }
+func (f pbField) isVarint() bool   { return f.typ == protowire.VarintType }
+func (f pbField) uint16() uint16   { return uint16(f.v) }
+func (f pbField) byteValue() uint8 { return uint8(f.v) }
+
+// pbDecode 遍历消息的所有字段, 未知字段或类型不匹配的字段由f自行忽略
+func pbDecode(b []byte, f func(field pbField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		field := pbField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			field.v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			field.v = uint64(v)
+		case protowire.Fixed64Type:
+			field.v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			field.data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeAnalog(e *pbEncoder, a Analog) {
+	e.int64(1, a.GlobalID)
+	e.int32(2, a.PNum)
+	e.float(3, a.AV)
+	e.float(4, a.AVR)
+	e.boolean(5, a.Q)
+	e.boolean(6, a.BF)
+	e.boolean(7, a.QF)
+	e.float(8, a.FAI)
+	e.boolean(9, a.MS)
+	e.varint(10, uint64(a.TEW))
+	e.varint(11, uint64(a.CST))
+}
+
+func decodeAnalog(b []byte) (Analog, error) {
+	a := Analog{}
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			a.GlobalID = f.int64()
+		case f.num == 2 && f.isVarint():
+			a.PNum = f.int32()
+		case f.num == 3 && f.isFixed32():
+			a.AV = f.float()
+		case f.num == 4 && f.isFixed32():
+			a.AVR = f.float()
+		case f.num == 5 && f.isVarint():
+			a.Q = f.boolean()
+		case f.num == 6 && f.isVarint():
+			a.BF = f.boolean()
+		case f.num == 7 && f.isVarint():
+			a.QF = f.boolean()
+		case f.num == 8 && f.isFixed32():
+			a.FAI = f.float()
+		case f.num == 9 && f.isVarint():
+			a.MS = f.boolean()
+		case f.num == 10 && f.isVarint():
+			a.TEW = f.byteValue()
+		case f.num == 11 && f.isVarint():
+			a.CST = f.uint16()
+		}
+		return nil
+	})
+	return a, err
+}
+
+func encodeDigital(e *pbEncoder, d Digital) {
+	e.int64(1, d.GlobalID)
+	e.int32(2, d.PNum)
+	e.boolean(3, d.DV)
+	e.boolean(4, d.DVR)
+	e.boolean(5, d.Q)
+	e.boolean(6, d.BF)
+	e.boolean(7, d.BQ)
+	e.boolean(8, d.FAI)
+	e.boolean(9, d.MS)
+	e.varint(10, uint64(d.TEW))
+	e.varint(11, uint64(d.CST))
+}
+
+func decodeDigital(b []byte) (Digital, error) {
+	d := Digital{}
+	err := pbDecode(b, func(f pbField) error {
+		if !f.isVarint() {
+			return nil
+		}
+		switch f.num {
+		case 1:
+			d.GlobalID = f.int64()
+		case 2:
+			d.PNum = f.int32()
+		case 3:
+			d.DV = f.boolean()
+		case 4:
+			d.DVR = f.boolean()
+		case 5:
+			d.Q = f.boolean()
+		case 6:
+			d.BF = f.boolean()
+		case 7:
+			d.BQ = f.boolean()
+		case 8:
+			d.FAI = f.boolean()
+		case 9:
+			d.MS = f.boolean()
+		case 10:
+			d.TEW = f.byteValue()
+		case 11:
+			d.CST = f.uint16()
+		}
+		return nil
+	})
+	return d, err
+}
+
+func encodeStaticAnalog(e *pbEncoder, s StaticAnalog) {
+	e.int64(1, s.GlobalID)
+	e.int32(2, s.PNum)
+	e.varint(3, uint64(s.TAGT))
+	e.varint(4, uint64(s.FACK))
+	e.boolean(5, s.L4AR)
+	e.boolean(6, s.L3AR)
+	e.boolean(7, s.L2AR)
+	e.boolean(8, s.L1AR)
+	e.boolean(9, s.H4AR)
+	e.boolean(10, s.H3AR)
+	e.boolean(11, s.H2AR)
+	e.boolean(12, s.H1AR)
+	e.str(13, s.CHN)
+	e.str(14, s.PN)
+	e.str(15, s.DESC)
+	e.str(16, s.UNIT)
+	e.float(17, s.MU)
+	e.float(18, s.MD)
+}
+
+func decodeStaticAnalog(b []byte) (StaticAnalog, error) {
+	s := StaticAnalog{}
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			s.GlobalID = f.int64()
+		case f.num == 2 && f.isVarint():
+			s.PNum = f.int32()
+		case f.num == 3 && f.isVarint():
+			s.TAGT = f.uint16()
+		case f.num == 4 && f.isVarint():
+			s.FACK = f.uint16()
+		case f.num == 5 && f.isVarint():
+			s.L4AR = f.boolean()
+		case f.num == 6 && f.isVarint():
+			s.L3AR = f.boolean()
+		case f.num == 7 && f.isVarint():
+			s.L2AR = f.boolean()
+		case f.num == 8 && f.isVarint():
+			s.L1AR = f.boolean()
+		case f.num == 9 && f.isVarint():
+			s.H4AR = f.boolean()
+		case f.num == 10 && f.isVarint():
+			s.H3AR = f.boolean()
+		case f.num == 11 && f.isVarint():
+			s.H2AR = f.boolean()
+		case f.num == 12 && f.isVarint():
+			s.H1AR = f.boolean()
+		case f.num == 13 && f.isBytes():
+			s.CHN = f.str()
+		case f.num == 14 && f.isBytes():
+			s.PN = f.str()
+		case f.num == 15 && f.isBytes():
+			s.DESC = f.str()
+		case f.num == 16 && f.isBytes():
+			s.UNIT = f.str()
+		case f.num == 17 && f.isFixed32():
+			s.MU = f.float()
+		case f.num == 18 && f.isFixed32():
+			s.MD = f.float()
+		}
+		return nil
+	})
+	return s, err
+}
+
+func encodeStaticDigital(e *pbEncoder, s StaticDigital) {
+	e.int64(1, s.GlobalID)
+	e.int32(2, s.PNum)
+	e.varint(3, uint64(s.FACK))
+	e.str(4, s.CHN)
+	e.str(5, s.PN)
+	e.str(6, s.DESC)
+	e.str(7, s.UNIT)
+}
+
+func decodeStaticDigital(b []byte) (StaticDigital, error) {
+	s := StaticDigital{}
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			s.GlobalID = f.int64()
+		case f.num == 2 && f.isVarint():
+			s.PNum = f.int32()
+		case f.num == 3 && f.isVarint():
+			s.FACK = f.uint16()
+		case f.num == 4 && f.isBytes():
+			s.CHN = f.str()
+		case f.num == 5 && f.isBytes():
+			s.PN = f.str()
+		case f.num == 6 && f.isBytes():
+			s.DESC = f.str()
+		case f.num == 7 && f.isBytes():
+			s.UNIT = f.str()
+		}
+		return nil
+	})
+	return s, err
+}
+
+func encodePluginInfo(info PluginInfo) []byte {
+	e := &pbEncoder{}
+	e.int32(1, int32(info.ABIVersion))
+	e.str(2, info.Vendor)
+	e.int64(3, int64(info.MaxBatchSize))
+	e.boolean(4, info.Reentrant)
+	e.boolean(5, info.SupportList)
+	e.boolean(6, info.SupportHis)
+	e.boolean(7, info.SupportStatic)
+	return e.buf
+}
+
+func decodePluginInfo(b []byte) (PluginInfo, error) {
+	info := PluginInfo{}
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			info.ABIVersion = int(f.int32())
+		case f.num == 2 && f.isBytes():
+			info.Vendor = f.str()
+		case f.num == 3 && f.isVarint():
+			info.MaxBatchSize = int(f.int64())
+		case f.num == 4 && f.isVarint():
+			info.Reentrant = f.boolean()
+		case f.num == 5 && f.isVarint():
+			info.SupportList = f.boolean()
+		case f.num == 6 && f.isVarint():
+			info.SupportHis = f.boolean()
+		case f.num == 7 && f.isVarint():
+			info.SupportStatic = f.boolean()
+		}
+		return nil
+	})
+	return info, err
+}
+
+// encodeCode 编码 LoginRequest, LoginResponse, WriteResponse 这类只包含一个整数和一个字符串的消息
+func encodeCode(code int32, message string) []byte {
+	e := &pbEncoder{}
+	e.int32(1, code)
+	e.str(2, message)
+	return e.buf
+}
+
+func decodeCode(b []byte) (int32, string, error) {
+	code := int32(0)
+	message := ""
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			code = f.int32()
+		case f.num == 2 && f.isBytes():
+			message = f.str()
+		}
+		return nil
+	})
+	return code, message, err
+}
+
+func encodeLoginRequest(param string) []byte {
+	e := &pbEncoder{}
+	e.str(1, param)
+	return e.buf
+}
+
+func decodeLoginRequest(b []byte) (string, error) {
+	param := ""
+	err := pbDecode(b, func(f pbField) error {
+		if f.num == 1 && f.isBytes() {
+			param = f.str()
+		}
+		return nil
+	})
+	return param, err
+}
+
+// writeRequest WriteAnalogRequest, WriteDigitalRequest, WriteStaticAnalogRequest, WriteStaticDigitalRequest 的公共结构
+// 写实时/历史值时使用 times, analogs/digitals; 写静态值时使用 typ, staticAnalogs/staticDigitals
+type writeRequest struct {
+	magic          int32
+	unitId         int64
+	isFast         bool
+	typ            int64
+	times          []int64
+	analogs        [][]Analog
+	digitals       [][]Digital
+	staticAnalogs  []StaticAnalog
+	staticDigitals []StaticDigital
+}
+
+func encodeWriteAnalogRequest(magic int32, unitId int64, isFast bool, times []int64, data [][]Analog) []byte {
+	e := &pbEncoder{}
+	e.int32(1, magic)
+	e.int64(2, unitId)
+	e.boolean(3, isFast)
+	for i := range times {
+		e.message(4, func(e *pbEncoder) {
+			e.int64(1, times[i])
+			for _, a := range data[i] {
+				e.message(2, func(e *pbEncoder) { encodeAnalog(e, a) })
+			}
+		})
+	}
+	return e.buf
+}
+
+func encodeWriteDigitalRequest(magic int32, unitId int64, isFast bool, times []int64, data [][]Digital) []byte {
+	e := &pbEncoder{}
+	e.int32(1, magic)
+	e.int64(2, unitId)
+	e.boolean(3, isFast)
+	for i := range times {
+		e.message(4, func(e *pbEncoder) {
+			e.int64(1, times[i])
+			for _, d := range data[i] {
+				e.message(2, func(e *pbEncoder) { encodeDigital(e, d) })
+			}
+		})
+	}
+	return e.buf
+}
+
+func encodeWriteStaticAnalogRequest(magic int32, unitId int64, data []StaticAnalog, typ int64) []byte {
+	e := &pbEncoder{}
+	e.int32(1, magic)
+	e.int64(2, unitId)
+	e.int64(3, typ)
+	for _, s := range data {
+		e.message(4, func(e *pbEncoder) { encodeStaticAnalog(e, s) })
+	}
+	return e.buf
+}
+
+func encodeWriteStaticDigitalRequest(magic int32, unitId int64, data []StaticDigital, typ int64) []byte {
+	e := &pbEncoder{}
+	e.int32(1, magic)
+	e.int64(2, unitId)
+	e.int64(3, typ)
+	for _, s := range data {
+		e.message(4, func(e *pbEncoder) { encodeStaticDigital(e, s) })
+	}
+	return e.buf
+}
+
+// decodeWriteRequest 解码写入请求, kind为请求消息中第4个字段的类型
+func decodeWriteRequest(b []byte, kind string) (*writeRequest, error) {
+	req := &writeRequest{}
+	err := pbDecode(b, func(f pbField) error {
+		switch {
+		case f.num == 1 && f.isVarint():
+			req.magic = f.int32()
+		case f.num == 2 && f.isVarint():
+			req.unitId = f.int64()
+		case f.num == 3 && f.isVarint():
+			req.isFast = f.boolean()
+			req.typ = f.int64()
+		case f.num == 4 && f.isBytes():
+			return req.decodeItem(f.data, kind)
+		}
+		return nil
+	})
+	return req, err
+}
+
+func (req *writeRequest) decodeItem(b []byte, kind string) error {
+	switch kind {
+	case "analog", "digital":
+		time := int64(0)
+		analogs := make([]Analog, 0)
+		digitals := make([]Digital, 0)
+		err := pbDecode(b, func(f pbField) error {
+			switch {
+			case f.num == 1 && f.isVarint():
+				time = f.int64()
+			case f.num == 2 && f.isBytes() && kind == "analog":
+				a, err := decodeAnalog(f.data)
+				analogs = append(analogs, a)
+				return err
+			case f.num == 2 && f.isBytes() && kind == "digital":
+				d, err := decodeDigital(f.data)
+				digitals = append(digitals, d)
+				return err
+			}
+			return nil
+		})
+		req.times = append(req.times, time)
+		req.analogs = append(req.analogs, analogs)
+		req.digitals = append(req.digitals, digitals)
+		return err
+	case "static_analog":
+		s, err := decodeStaticAnalog(b)
+		req.staticAnalogs = append(req.staticAnalogs, s)
+		return err
+	case "static_digital":
+		s, err := decodeStaticDigital(b)
+		req.staticDigitals = append(req.staticDigitals, s)
+		return err
+	default:
+		return fmt.Errorf("未知请求类型: %v", kind)
+	}
+}
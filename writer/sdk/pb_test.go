@@ -0,0 +1,327 @@
+package sdk
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// loadProto 由 rtdb_writer.proto 生成消息描述, 用于检查手写的编解码与proto文件一致
+// 只支持该文件用到的语法: 不嵌套的message, 标量字段和repeated消息字段
+func loadProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	b, err := os.ReadFile("rtdb_writer.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := regexp.MustCompile(`//[^\n]*`).ReplaceAllString(string(b), "")
+	pkg := regexp.MustCompile(`package\s+([\w.]+)\s*;`).FindStringSubmatch(src)[1]
+
+	scalars := map[string]descriptorpb.FieldDescriptorProto_Type{
+		"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+		"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+		"uint32": descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+		"float":  descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+		"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+		"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("rtdb_writer.proto"),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+	}
+	fieldRe := regexp.MustCompile(`(repeated\s+)?(\w+)\s+(\w+)\s*=\s*(\d+)\s*;`)
+	for _, m := range regexp.MustCompile(`message\s+(\w+)\s*\{([^}]*)\}`).FindAllStringSubmatch(src, -1) {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(m[1])}
+		for _, f := range fieldRe.FindAllStringSubmatch(m[2], -1) {
+			num, _ := strconv.Atoi(f[4])
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(f[3]),
+				JsonName: proto.String(f[3]),
+				Number:   proto.Int32(int32(num)),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			if f[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			if typ, ok := scalars[f[2]]; ok {
+				field.Type = typ.Enum()
+			} else {
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + pkg + "." + f[2])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+		file.MessageType = append(file.MessageType, msg)
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// protoName Go字段名对应的proto字段名, 如 GlobalID -> global_id, PNum -> p_num, L4AR -> l4ar
+func protoName(name string) string {
+	runes := []rune(name)
+	b := strings.Builder{}
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// toDynamic 按字段名将Go结构体转换为proto消息, 要求结构体覆盖消息的所有字段
+func toDynamic(t *testing.T, md protoreflect.MessageDescriptor, v any) *dynamicpb.Message {
+	t.Helper()
+	msg := dynamicpb.NewMessage(md)
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		name := protoName(rv.Type().Field(i).Name)
+		fd := md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			t.Fatalf("%v: proto中没有字段%v", md.Name(), name)
+		}
+		msg.Set(fd, scalarValue(t, fd, rv.Field(i)))
+	}
+	if rv.NumField() != md.Fields().Len() {
+		t.Fatalf("%v: Go结构体有%v个字段, proto消息有%v个字段", md.Name(), rv.NumField(), md.Fields().Len())
+	}
+	return msg
+}
+
+func scalarValue(t *testing.T, fd protoreflect.FieldDescriptor, v reflect.Value) protoreflect.Value {
+	t.Helper()
+	switch fd.Kind() {
+	case protoreflect.Int64Kind:
+		return protoreflect.ValueOfInt64(v.Int())
+	case protoreflect.Int32Kind:
+		return protoreflect.ValueOfInt32(int32(v.Int()))
+	case protoreflect.Uint32Kind:
+		return protoreflect.ValueOfUint32(uint32(v.Uint()))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(v.Float()))
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(v.Bool())
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v.String())
+	}
+	t.Fatalf("不支持的字段类型: %v %v", fd.FullName(), fd.Kind())
+	return protoreflect.Value{}
+}
+
+// checkEncode 手写编码的结果应能被proto消息描述解码, 并且与期望的消息相同
+func checkEncode(t *testing.T, name string, b []byte, want *dynamicpb.Message) {
+	t.Helper()
+	got := dynamicpb.NewMessage(want.Descriptor())
+	if err := (proto.UnmarshalOptions{DiscardUnknown: false}).Unmarshal(b, got); err != nil {
+		t.Fatalf("%v: 解码失败: %v", name, err)
+	}
+	if len(got.GetUnknown()) > 0 {
+		t.Fatalf("%v: 编码中包含proto未定义的字段: %x", name, got.GetUnknown())
+	}
+	if !proto.Equal(got, want) {
+		t.Fatalf("%v: 编码不一致\ngot:  %v\nwant: %v", name, got, want)
+	}
+}
+
+func marshal(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+var (
+	testAnalog = Analog{GlobalID: 1<<40 | 7, PNum: -3, AV: 1.5, AVR: -2.25, Q: true, BF: true, QF: true,
+		FAI: 3.75, MS: true, TEW: 'B', CST: 0xBEEF}
+	testDigital = Digital{GlobalID: 1<<42 | 9, PNum: 12, DV: true, DVR: true, Q: true, BF: true, BQ: true,
+		FAI: true, MS: true, TEW: 'G', CST: 513}
+	testStaticAnalog = StaticAnalog{GlobalID: 5, PNum: 6, TAGT: 7, FACK: 8, L4AR: true, L3AR: true, L2AR: true, L1AR: true,
+		H4AR: true, H3AR: true, H2AR: true, H1AR: true, CHN: "通道", PN: "pn", DESC: "描述", UNIT: "MW", MU: 100.5, MD: -100.5}
+	testStaticDigital = StaticDigital{GlobalID: 11, PNum: 12, FACK: 13, CHN: "chn", PN: "pn", DESC: "desc", UNIT: "unit"}
+	testPluginInfo    = PluginInfo{ABIVersion: 2, Vendor: "test", MaxBatchSize: 50, Reentrant: true,
+		SupportList: true, SupportHis: true, SupportStatic: true}
+)
+
+func TestPbMessages(t *testing.T) {
+	fd := loadProto(t)
+	msg := func(name string) protoreflect.MessageDescriptor {
+		md := fd.Messages().ByName(protoreflect.Name(name))
+		if md == nil {
+			t.Fatalf("proto中没有消息%v", name)
+		}
+		return md
+	}
+
+	tests := []struct {
+		name   string
+		value  any
+		encode func() []byte
+		decode func(b []byte) (any, error)
+	}{
+		{"Analog", testAnalog,
+			func() []byte { e := &pbEncoder{}; encodeAnalog(e, testAnalog); return e.buf },
+			func(b []byte) (any, error) { return decodeAnalog(b) }},
+		{"Digital", testDigital,
+			func() []byte { e := &pbEncoder{}; encodeDigital(e, testDigital); return e.buf },
+			func(b []byte) (any, error) { return decodeDigital(b) }},
+		{"StaticAnalog", testStaticAnalog,
+			func() []byte { e := &pbEncoder{}; encodeStaticAnalog(e, testStaticAnalog); return e.buf },
+			func(b []byte) (any, error) { return decodeStaticAnalog(b) }},
+		{"StaticDigital", testStaticDigital,
+			func() []byte { e := &pbEncoder{}; encodeStaticDigital(e, testStaticDigital); return e.buf },
+			func(b []byte) (any, error) { return decodeStaticDigital(b) }},
+		{"PluginInfo", testPluginInfo,
+			func() []byte { return encodePluginInfo(testPluginInfo) },
+			func(b []byte) (any, error) { return decodePluginInfo(b) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := toDynamic(t, msg(tt.name), tt.value)
+			checkEncode(t, tt.name, tt.encode(), want)
+			got, err := tt.decode(marshal(t, want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Fatalf("解码不一致\ngot:  %+v\nwant: %+v", got, tt.value)
+			}
+		})
+	}
+
+	t.Run("Code", func(t *testing.T) {
+		for _, name := range []string{"LoginResponse", "WriteResponse"} {
+			want := dynamicpb.NewMessage(msg(name))
+			want.Set(want.Descriptor().Fields().ByName("code"), protoreflect.ValueOfInt32(-6))
+			if name == "WriteResponse" {
+				want.Set(want.Descriptor().Fields().ByName("message"), protoreflect.ValueOfString("overload"))
+				checkEncode(t, name, encodeCode(-6, "overload"), want)
+			} else {
+				checkEncode(t, name, encodeCode(-6, ""), want)
+			}
+			code, message, err := decodeCode(marshal(t, want))
+			if err != nil || code != -6 || (name == "WriteResponse" && message != "overload") {
+				t.Fatalf("%v: 解码不一致: %v, %v, %v", name, code, message, err)
+			}
+		}
+	})
+
+	t.Run("LoginRequest", func(t *testing.T) {
+		want := dynamicpb.NewMessage(msg("LoginRequest"))
+		want.Set(want.Descriptor().Fields().ByName("param"), protoreflect.ValueOfString("user=a"))
+		checkEncode(t, "LoginRequest", encodeLoginRequest("user=a"), want)
+		param, err := decodeLoginRequest(marshal(t, want))
+		if err != nil || param != "user=a" {
+			t.Fatalf("解码不一致: %v, %v", param, err)
+		}
+	})
+}
+
+// writeRequestMessage 构造写入请求的proto消息, items为每个断面的数据或静态数据
+func writeRequestMessage(t *testing.T, md protoreflect.MessageDescriptor, header map[string]protoreflect.Value, items func(list protoreflect.List)) *dynamicpb.Message {
+	t.Helper()
+	msg := dynamicpb.NewMessage(md)
+	for name, v := range header {
+		msg.Set(md.Fields().ByName(protoreflect.Name(name)), v)
+	}
+	field := md.Fields().ByNumber(4)
+	list := msg.Mutable(field).List()
+	items(list)
+	return msg
+}
+
+func TestPbWriteRequests(t *testing.T) {
+	fd := loadProto(t)
+	times := []int64{1718350759143, 1718350759144}
+	analogs := [][]Analog{{testAnalog, {GlobalID: 1}}, {}}
+	digitals := [][]Digital{{testDigital}, {{GlobalID: 2, DV: true}}}
+	header := map[string]protoreflect.Value{
+		"magic":   protoreflect.ValueOfInt32(-1),
+		"unit_id": protoreflect.ValueOfInt64(3),
+		"is_fast": protoreflect.ValueOfBool(true),
+	}
+	staticHeader := map[string]protoreflect.Value{
+		"magic":   protoreflect.ValueOfInt32(-1),
+		"unit_id": protoreflect.ValueOfInt64(3),
+		"type":    protoreflect.ValueOfInt64(2),
+	}
+	sections := func(name string, count func(i int) int, item func(i, j int) any) func(list protoreflect.List) {
+		return func(list protoreflect.List) {
+			sectionMd := fd.Messages().ByName(protoreflect.Name(name + "Section"))
+			itemMd := fd.Messages().ByName(protoreflect.Name(name))
+			for i := range times {
+				section := dynamicpb.NewMessage(sectionMd)
+				section.Set(sectionMd.Fields().ByName("time"), protoreflect.ValueOfInt64(times[i]))
+				data := section.Mutable(sectionMd.Fields().ByName("data")).List()
+				for j := 0; j < count(i); j++ {
+					data.Append(protoreflect.ValueOfMessage(toDynamic(t, itemMd, item(i, j))))
+				}
+				list.Append(protoreflect.ValueOfMessage(section))
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		kind   string
+		header map[string]protoreflect.Value
+		items  func(list protoreflect.List)
+		encode []byte
+		want   *writeRequest
+	}{
+		{"WriteAnalogRequest", "analog", header,
+			sections("Analog", func(i int) int { return len(analogs[i]) }, func(i, j int) any { return analogs[i][j] }),
+			encodeWriteAnalogRequest(-1, 3, true, times, analogs),
+			&writeRequest{magic: -1, unitId: 3, isFast: true, typ: 1, times: times, analogs: analogs, digitals: [][]Digital{{}, {}}}},
+		{"WriteDigitalRequest", "digital", header,
+			sections("Digital", func(i int) int { return len(digitals[i]) }, func(i, j int) any { return digitals[i][j] }),
+			encodeWriteDigitalRequest(-1, 3, true, times, digitals),
+			&writeRequest{magic: -1, unitId: 3, isFast: true, typ: 1, times: times, analogs: [][]Analog{{}, {}}, digitals: digitals}},
+		{"WriteStaticAnalogRequest", "static_analog", staticHeader,
+			func(list protoreflect.List) {
+				list.Append(protoreflect.ValueOfMessage(toDynamic(t, fd.Messages().ByName("StaticAnalog"), testStaticAnalog)))
+			},
+			encodeWriteStaticAnalogRequest(-1, 3, []StaticAnalog{testStaticAnalog}, 2),
+			&writeRequest{magic: -1, unitId: 3, isFast: true, typ: 2, staticAnalogs: []StaticAnalog{testStaticAnalog}}},
+		{"WriteStaticDigitalRequest", "static_digital", staticHeader,
+			func(list protoreflect.List) {
+				list.Append(protoreflect.ValueOfMessage(toDynamic(t, fd.Messages().ByName("StaticDigital"), testStaticDigital)))
+			},
+			encodeWriteStaticDigitalRequest(-1, 3, []StaticDigital{testStaticDigital}, 2),
+			&writeRequest{magic: -1, unitId: 3, isFast: true, typ: 2, staticDigitals: []StaticDigital{testStaticDigital}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := fd.Messages().ByName(protoreflect.Name(tt.name))
+			want := writeRequestMessage(t, md, tt.header, tt.items)
+			checkEncode(t, tt.name, tt.encode, want)
+			got, err := decodeWriteRequest(marshal(t, want), tt.kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("解码不一致\ngot:  %+v\nwant: %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,772 @@
+package sdk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+//
+// 外部进程插件协议
+// 写入程序与插件进程之间通过一对字节流(如标准输入输出)交换帧, 所有整数均为小端序
+//
+// 请求帧:
+// +--------+--------+-------+---------+
+// | uint32 | uint32 | uint8 |   ...   |
+// +--------+--------+-------+---------+
+// | length |   id   |  op   | payload |
+// +--------+--------+-------+---------+
+//
+// 响应帧:
+// +--------+--------+-------+-------+---------+---------+
+// | uint32 | uint32 | uint8 | int32 | string  |   ...   |
+// +--------+--------+-------+-------+---------+---------+
+// | length |   id   |  op   | code  | message | payload |
+// +--------+--------+-------+-------+---------+---------+
+//
+// * length: length字段之后的字节数
+// * id: 请求ID, 响应帧的id与请求帧相同; 写入程序可能同时发送多个请求, 插件可以乱序响应
+// * op: 操作类型, 参考 Op
+// * code: 错误码, 参考 ErrorCode; login请求时为login的返回值
+// * message: 错误信息
+//
+// 基本类型: bool为1字节(0或1), string为uint32长度+UTF-8字节, float为IEEE754单精度
+// Analog: int64 global_id, int32 p_num, float av, float avr, bool q, bool bf, bool qf, float fai, bool ms, uint8 tew, uint16 cst
+// Digital: int64 global_id, int32 p_num, bool dv, bool dvr, bool q, bool bf, bool bq, bool fai, bool ms, uint8 tew, uint16 cst
+// StaticAnalog: int64 global_id, int32 p_num, uint16 tagt, uint16 fack, bool l4ar, bool l3ar, bool l2ar, bool l1ar,
+//               bool h4ar, bool h3ar, bool h2ar, bool h1ar, string chn, string pn, string desc, string unit, float mu, float md
+// StaticDigital: int64 global_id, int32 p_num, uint16 fack, string chn, string pn, string desc, string unit
+//
+
+// Op 操作类型
+type Op uint8
+
+const (
+	// OpInfo 请求: 无; 响应: int32 abi_version, string vendor, int64 max_batch_size, bool reentrant, bool support_list, bool support_his, bool support_static
+	OpInfo Op = 1
+	// OpLogin 请求: string param(空字符串表示参数为空)
+	OpLogin Op = 2
+	// OpLogout 请求: 无
+	OpLogout Op = 3
+	// OpWriteRtAnalog 请求: int32 magic, int64 unit_id, int64 time, bool is_fast, uint32 count, Analog[count]
+	OpWriteRtAnalog Op = 4
+	// OpWriteRtDigital 请求: int32 magic, int64 unit_id, int64 time, bool is_fast, uint32 count, Digital[count]
+	OpWriteRtDigital Op = 5
+	// OpWriteRtAnalogList 请求: int32 magic, int64 unit_id, uint32 section_count, 每个断面: int64 time, uint32 count, Analog[count]
+	OpWriteRtAnalogList Op = 6
+	// OpWriteRtDigitalList 请求: int32 magic, int64 unit_id, uint32 section_count, 每个断面: int64 time, uint32 count, Digital[count]
+	OpWriteRtDigitalList Op = 7
+	// OpWriteHisAnalog 请求: int32 magic, int64 unit_id, int64 time, uint32 count, Analog[count]
+	OpWriteHisAnalog Op = 8
+	// OpWriteHisDigital 请求: int32 magic, int64 unit_id, int64 time, uint32 count, Digital[count]
+	OpWriteHisDigital Op = 9
+	// OpWriteStaticAnalog 请求: int32 magic, int64 unit_id, int64 type, uint32 count, StaticAnalog[count]
+	OpWriteStaticAnalog Op = 10
+	// OpWriteStaticDigital 请求: int32 magic, int64 unit_id, int64 type, uint32 count, StaticDigital[count]
+	OpWriteStaticDigital Op = 11
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpInfo:
+		return "plugin_info"
+	case OpLogin:
+		return "login"
+	case OpLogout:
+		return "logout"
+	case OpWriteRtAnalog:
+		return "write_rt_analog"
+	case OpWriteRtDigital:
+		return "write_rt_digital"
+	case OpWriteRtAnalogList:
+		return "write_rt_analog_list"
+	case OpWriteRtDigitalList:
+		return "write_rt_digital_list"
+	case OpWriteHisAnalog:
+		return "write_his_analog"
+	case OpWriteHisDigital:
+		return "write_his_digital"
+	case OpWriteStaticAnalog:
+		return "write_static_analog"
+	case OpWriteStaticDigital:
+		return "write_static_digital"
+	default:
+		return fmt.Sprintf("op(%d)", uint8(op))
+	}
+}
+
+// MaxFrameSize 单个帧的最大长度
+const MaxFrameSize = 1 << 30
+
+// frameHeaderSize 帧头长度: length + id + op
+const frameHeaderSize = 9
+
+// 各类型编码后的最小长度, 用于解码时检查数量是否合法
+const (
+	analogSize        = 31
+	digitalSize       = 22
+	staticAnalogSize  = 48
+	staticDigitalSize = 30
+)
+
+// encoder 帧编码器, 帧头的length和id在发送前填写
+type encoder struct {
+	buf []byte
+}
+
+func newFrame(id uint32, op Op) *encoder {
+	e := &encoder{buf: make([]byte, frameHeaderSize, 256)}
+	binary.LittleEndian.PutUint32(e.buf[4:], id)
+	e.buf[8] = byte(op)
+	return e
+}
+
+func (e *encoder) bytes() []byte {
+	binary.LittleEndian.PutUint32(e.buf[0:], uint32(len(e.buf)-4))
+	return e.buf
+}
+
+func (e *encoder) u8(v uint8)   { e.buf = append(e.buf, v) }
+func (e *encoder) u16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *encoder) u32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *encoder) i32(v int32)  { e.u32(uint32(v)) }
+func (e *encoder) i64(v int64)  { e.buf = binary.LittleEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *encoder) f32(v float32) {
+	e.u32(math.Float32bits(v))
+}
+
+func (e *encoder) boolean(v bool) {
+	if v {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+}
+
+func (e *encoder) str(v string) {
+	e.u32(uint32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) info(info PluginInfo) {
+	e.i32(int32(info.ABIVersion))
+	e.str(info.Vendor)
+	e.i64(int64(info.MaxBatchSize))
+	e.boolean(info.Reentrant)
+	e.boolean(info.SupportList)
+	e.boolean(info.SupportHis)
+	e.boolean(info.SupportStatic)
+}
+
+func (e *encoder) analogs(data []Analog) {
+	e.u32(uint32(len(data)))
+	for _, a := range data {
+		e.i64(a.GlobalID)
+		e.i32(a.PNum)
+		e.f32(a.AV)
+		e.f32(a.AVR)
+		e.boolean(a.Q)
+		e.boolean(a.BF)
+		e.boolean(a.QF)
+		e.f32(a.FAI)
+		e.boolean(a.MS)
+		e.u8(a.TEW)
+		e.u16(a.CST)
+	}
+}
+
+func (e *encoder) digitals(data []Digital) {
+	e.u32(uint32(len(data)))
+	for _, d := range data {
+		e.i64(d.GlobalID)
+		e.i32(d.PNum)
+		e.boolean(d.DV)
+		e.boolean(d.DVR)
+		e.boolean(d.Q)
+		e.boolean(d.BF)
+		e.boolean(d.BQ)
+		e.boolean(d.FAI)
+		e.boolean(d.MS)
+		e.u8(d.TEW)
+		e.u16(d.CST)
+	}
+}
+
+func (e *encoder) staticAnalogs(data []StaticAnalog) {
+	e.u32(uint32(len(data)))
+	for _, s := range data {
+		e.i64(s.GlobalID)
+		e.i32(s.PNum)
+		e.u16(s.TAGT)
+		e.u16(s.FACK)
+		e.boolean(s.L4AR)
+		e.boolean(s.L3AR)
+		e.boolean(s.L2AR)
+		e.boolean(s.L1AR)
+		e.boolean(s.H4AR)
+		e.boolean(s.H3AR)
+		e.boolean(s.H2AR)
+		e.boolean(s.H1AR)
+		e.str(s.CHN)
+		e.str(s.PN)
+		e.str(s.DESC)
+		e.str(s.UNIT)
+		e.f32(s.MU)
+		e.f32(s.MD)
+	}
+}
+
+func (e *encoder) staticDigitals(data []StaticDigital) {
+	e.u32(uint32(len(data)))
+	for _, s := range data {
+		e.i64(s.GlobalID)
+		e.i32(s.PNum)
+		e.u16(s.FACK)
+		e.str(s.CHN)
+		e.str(s.PN)
+		e.str(s.DESC)
+		e.str(s.UNIT)
+	}
+}
+
+// decoder 帧解码器, 遇到错误后所有读取都返回零值, 错误记录在err中
+type decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf)-d.off < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) i32() int32   { return int32(d.u32()) }
+func (d *decoder) f32() float32 { return math.Float32frombits(d.u32()) }
+func (d *decoder) boolean() bool {
+	return d.u8() != 0
+}
+
+func (d *decoder) i64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) str() string {
+	n := d.u32()
+	return string(d.next(int(n)))
+}
+
+// count 读取数组长度, 长度超过剩余字节数时记录错误
+func (d *decoder) count(size int) int {
+	n := int(d.u32())
+	if d.err == nil && n*size > len(d.buf)-d.off {
+		d.err = fmt.Errorf("数组长度错误: %v", n)
+	}
+	if d.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) info() PluginInfo {
+	return PluginInfo{
+		ABIVersion:    int(d.i32()),
+		Vendor:        d.str(),
+		MaxBatchSize:  int(d.i64()),
+		Reentrant:     d.boolean(),
+		SupportList:   d.boolean(),
+		SupportHis:    d.boolean(),
+		SupportStatic: d.boolean(),
+	}
+}
+
+func (d *decoder) analogs() []Analog {
+	data := make([]Analog, d.count(analogSize))
+	for i := range data {
+		a := &data[i]
+		a.GlobalID = d.i64()
+		a.PNum = d.i32()
+		a.AV = d.f32()
+		a.AVR = d.f32()
+		a.Q = d.boolean()
+		a.BF = d.boolean()
+		a.QF = d.boolean()
+		a.FAI = d.f32()
+		a.MS = d.boolean()
+		a.TEW = d.u8()
+		a.CST = d.u16()
+	}
+	return data
+}
+
+func (d *decoder) digitals() []Digital {
+	data := make([]Digital, d.count(digitalSize))
+	for i := range data {
+		v := &data[i]
+		v.GlobalID = d.i64()
+		v.PNum = d.i32()
+		v.DV = d.boolean()
+		v.DVR = d.boolean()
+		v.Q = d.boolean()
+		v.BF = d.boolean()
+		v.BQ = d.boolean()
+		v.FAI = d.boolean()
+		v.MS = d.boolean()
+		v.TEW = d.u8()
+		v.CST = d.u16()
+	}
+	return data
+}
+
+func (d *decoder) staticAnalogs() []StaticAnalog {
+	data := make([]StaticAnalog, d.count(staticAnalogSize))
+	for i := range data {
+		s := &data[i]
+		s.GlobalID = d.i64()
+		s.PNum = d.i32()
+		s.TAGT = d.u16()
+		s.FACK = d.u16()
+		s.L4AR = d.boolean()
+		s.L3AR = d.boolean()
+		s.L2AR = d.boolean()
+		s.L1AR = d.boolean()
+		s.H4AR = d.boolean()
+		s.H3AR = d.boolean()
+		s.H2AR = d.boolean()
+		s.H1AR = d.boolean()
+		s.CHN = d.str()
+		s.PN = d.str()
+		s.DESC = d.str()
+		s.UNIT = d.str()
+		s.MU = d.f32()
+		s.MD = d.f32()
+	}
+	return data
+}
+
+func (d *decoder) staticDigitals() []StaticDigital {
+	data := make([]StaticDigital, d.count(staticDigitalSize))
+	for i := range data {
+		s := &data[i]
+		s.GlobalID = d.i64()
+		s.PNum = d.i32()
+		s.FACK = d.u16()
+		s.CHN = d.str()
+		s.PN = d.str()
+		s.DESC = d.str()
+		s.UNIT = d.str()
+	}
+	return data
+}
+
+// readFrame 读取一个帧, 返回帧的id, op和payload
+func readFrame(r io.Reader) (uint32, Op, []byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:])
+	if length < frameHeaderSize-4 || length > MaxFrameSize {
+		return 0, 0, nil, fmt.Errorf("帧长度错误: %v", length)
+	}
+	payload := make([]byte, length-(frameHeaderSize-4))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return binary.LittleEndian.Uint32(header[4:]), Op(header[8]), payload, nil
+}
+
+// Client 外部进程插件协议的客户端, 实现了 Writer 接口
+// 可以被多个goroutine同时调用, 请求通过id与响应对应
+type Client struct {
+	w         io.Writer
+	writeLock *sync.Mutex
+
+	lock    *sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan *decoder
+	err     error // 连接断开的原因, 不为nil时所有请求立即失败
+}
+
+// NewClient 创建客户端, r和w分别为插件的输出流和输入流
+func NewClient(r io.Reader, w io.Writer) *Client {
+	c := &Client{
+		w:         w,
+		writeLock: new(sync.Mutex),
+		lock:      new(sync.Mutex),
+		pending:   make(map[uint32]chan *decoder),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Err 连接断开的原因, 连接正常时返回nil
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *Client) readLoop(r io.Reader) {
+	for {
+		id, _, payload, err := readFrame(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			c.fail(err)
+			return
+		}
+		c.lock.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.lock.Unlock()
+		if ch != nil {
+			ch <- &decoder{buf: payload}
+		}
+	}
+}
+
+// fail 连接断开, 所有等待中的请求返回连接错误
+func (c *Client) fail(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// call 发送请求并等待响应, 返回的decoder位于响应payload的起始位置
+func (c *Client) call(e *encoder) (ErrorCode, *decoder, error) {
+	c.lock.Lock()
+	if c.err != nil {
+		err := c.err
+		c.lock.Unlock()
+		return ErrConnection, nil, NewError(ErrConnection, "插件连接已断开: %v", err)
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *decoder, 1)
+	c.pending[id] = ch
+	c.lock.Unlock()
+
+	frame := e.bytes()
+	binary.LittleEndian.PutUint32(frame[4:], id)
+	c.writeLock.Lock()
+	_, err := c.w.Write(frame)
+	c.writeLock.Unlock()
+	if err != nil {
+		c.fail(err)
+		return ErrConnection, nil, NewError(ErrConnection, "发送请求失败: %v", err)
+	}
+
+	d, ok := <-ch
+	if !ok {
+		return ErrConnection, nil, NewError(ErrConnection, "插件连接已断开: %v", c.Err())
+	}
+	code := ErrorCode(d.i32())
+	message := d.str()
+	if d.err != nil {
+		return ErrInvalidData, nil, NewError(ErrInvalidData, "响应解析失败: %v", d.err)
+	}
+	if code != Ok {
+		return code, d, &Error{Code: code, Message: message}
+	}
+	return code, d, nil
+}
+
+// QueryInfo 查询插件信息
+func (c *Client) QueryInfo() (PluginInfo, error) {
+	_, d, err := c.call(newFrame(0, OpInfo))
+	if err != nil {
+		return PluginInfo{}, err
+	}
+	info := d.info()
+	if d.err != nil {
+		return info, NewError(ErrInvalidData, "插件信息解析失败: %v", d.err)
+	}
+	return info, nil
+}
+
+func (c *Client) Login(param string) int {
+	e := newFrame(0, OpLogin)
+	e.str(param)
+	code, _, err := c.call(e)
+	if err != nil && code == ErrConnection {
+		return -1
+	}
+	return int(code)
+}
+
+func (c *Client) Logout() {
+	_, _, _ = c.call(newFrame(0, OpLogout))
+}
+
+func (c *Client) WriteRtAnalog(magic int32, unitId int64, time int64, data []Analog, isFast bool) error {
+	e := newFrame(0, OpWriteRtAnalog)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(time)
+	e.boolean(isFast)
+	e.analogs(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteRtDigital(magic int32, unitId int64, time int64, data []Digital, isFast bool) error {
+	e := newFrame(0, OpWriteRtDigital)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(time)
+	e.boolean(isFast)
+	e.digitals(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]Analog) error {
+	e := newFrame(0, OpWriteRtAnalogList)
+	e.i32(magic)
+	e.i64(unitId)
+	e.u32(uint32(len(times)))
+	for i := range times {
+		e.i64(times[i])
+		e.analogs(data[i])
+	}
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]Digital) error {
+	e := newFrame(0, OpWriteRtDigitalList)
+	e.i32(magic)
+	e.i64(unitId)
+	e.u32(uint32(len(times)))
+	for i := range times {
+		e.i64(times[i])
+		e.digitals(data[i])
+	}
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteHisAnalog(magic int32, unitId int64, time int64, data []Analog) error {
+	e := newFrame(0, OpWriteHisAnalog)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(time)
+	e.analogs(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteHisDigital(magic int32, unitId int64, time int64, data []Digital) error {
+	e := newFrame(0, OpWriteHisDigital)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(time)
+	e.digitals(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteStaticAnalog(magic int32, unitId int64, data []StaticAnalog, typ int64) error {
+	e := newFrame(0, OpWriteStaticAnalog)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(typ)
+	e.staticAnalogs(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+func (c *Client) WriteStaticDigital(magic int32, unitId int64, data []StaticDigital, typ int64) error {
+	e := newFrame(0, OpWriteStaticDigital)
+	e.i32(magic)
+	e.i64(unitId)
+	e.i64(typ)
+	e.staticDigitals(data)
+	_, _, err := c.call(e)
+	return err
+}
+
+// Serve 在r和w上提供外部进程插件协议服务, 每个请求在单独的goroutine中处理
+// r读到EOF时等待所有请求处理完成后返回nil
+// 插件不可重入时, 写入程序会保证同一时间只有一个请求
+func Serve(r io.Reader, w io.Writer, writer Writer) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	writeLock := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+	defer wg.Wait()
+
+	for {
+		id, op, payload, err := readFrame(br)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frame := handle(writer, id, op, payload)
+			writeLock.Lock()
+			defer writeLock.Unlock()
+			if _, err := bw.Write(frame); err == nil {
+				_ = bw.Flush()
+			}
+		}()
+	}
+}
+
+// handle 处理一个请求, 返回响应帧
+func handle(writer Writer, id uint32, op Op, payload []byte) []byte {
+	d := &decoder{buf: payload}
+	resp := newFrame(id, op)
+	var err error
+
+	switch op {
+	case OpInfo:
+		resp.i32(int32(Ok))
+		resp.str("")
+		resp.info(InfoOf(writer))
+		return resp.bytes()
+	case OpLogin:
+		param := d.str()
+		if d.err == nil {
+			resp.i32(int32(writer.Login(param)))
+			resp.str("")
+			return resp.bytes()
+		}
+	case OpLogout:
+		writer.Logout()
+	case OpWriteRtAnalog, OpWriteRtDigital:
+		magic := d.i32()
+		unitId := d.i64()
+		time := d.i64()
+		isFast := d.boolean()
+		if op == OpWriteRtAnalog {
+			if data := d.analogs(); d.err == nil {
+				err = writer.WriteRtAnalog(magic, unitId, time, data, isFast)
+			}
+		} else {
+			if data := d.digitals(); d.err == nil {
+				err = writer.WriteRtDigital(magic, unitId, time, data, isFast)
+			}
+		}
+	case OpWriteRtAnalogList:
+		magic := d.i32()
+		unitId := d.i64()
+		times := make([]int64, d.count(12))
+		data := make([][]Analog, len(times))
+		for i := range times {
+			times[i] = d.i64()
+			data[i] = d.analogs()
+		}
+		if d.err == nil {
+			err = writer.WriteRtAnalogList(magic, unitId, times, data)
+		}
+	case OpWriteRtDigitalList:
+		magic := d.i32()
+		unitId := d.i64()
+		times := make([]int64, d.count(12))
+		data := make([][]Digital, len(times))
+		for i := range times {
+			times[i] = d.i64()
+			data[i] = d.digitals()
+		}
+		if d.err == nil {
+			err = writer.WriteRtDigitalList(magic, unitId, times, data)
+		}
+	case OpWriteHisAnalog, OpWriteHisDigital:
+		magic := d.i32()
+		unitId := d.i64()
+		time := d.i64()
+		if op == OpWriteHisAnalog {
+			if data := d.analogs(); d.err == nil {
+				err = writer.WriteHisAnalog(magic, unitId, time, data)
+			}
+		} else {
+			if data := d.digitals(); d.err == nil {
+				err = writer.WriteHisDigital(magic, unitId, time, data)
+			}
+		}
+	case OpWriteStaticAnalog, OpWriteStaticDigital:
+		magic := d.i32()
+		unitId := d.i64()
+		typ := d.i64()
+		if op == OpWriteStaticAnalog {
+			if data := d.staticAnalogs(); d.err == nil {
+				err = writer.WriteStaticAnalog(magic, unitId, data, typ)
+			}
+		} else {
+			if data := d.staticDigitals(); d.err == nil {
+				err = writer.WriteStaticDigital(magic, unitId, data, typ)
+			}
+		}
+	default:
+		err = NewError(ErrUnsupported, "未知操作: %v", op)
+	}
+	if d.err != nil {
+		err = NewError(ErrInvalidData, "%v请求解析失败: %v", op, d.err)
+	}
+
+	resp.i32(int32(ErrorCodeOf(err)))
+	resp.str(errorMessage(err))
+	return resp.bytes()
+}
+
+// errorMessage 获取错误信息, 不包含错误码
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return err.Error()
+}
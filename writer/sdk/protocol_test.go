@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recordWriter 记录每次调用的接口名称和参数, 写入接口返回err
+type recordWriter struct {
+	lock  sync.Mutex
+	op    string
+	args  []any
+	login int
+	err   error
+}
+
+func (w *recordWriter) record(op string, args ...any) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.op, w.args = op, args
+	return w.err
+}
+
+func (w *recordWriter) last() (string, []any) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.op, w.args
+}
+
+func (w *recordWriter) Info() PluginInfo { return testPluginInfo }
+
+func (w *recordWriter) Login(param string) int {
+	_ = w.record("login", param)
+	return w.login
+}
+
+func (w *recordWriter) Logout() { _ = w.record("logout") }
+
+func (w *recordWriter) WriteRtAnalog(magic int32, unitId int64, time int64, data []Analog, isFast bool) error {
+	return w.record("write_rt_analog", magic, unitId, time, data, isFast)
+}
+
+func (w *recordWriter) WriteRtDigital(magic int32, unitId int64, time int64, data []Digital, isFast bool) error {
+	return w.record("write_rt_digital", magic, unitId, time, data, isFast)
+}
+
+func (w *recordWriter) WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]Analog) error {
+	return w.record("write_rt_analog_list", magic, unitId, times, data)
+}
+
+func (w *recordWriter) WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]Digital) error {
+	return w.record("write_rt_digital_list", magic, unitId, times, data)
+}
+
+func (w *recordWriter) WriteHisAnalog(magic int32, unitId int64, time int64, data []Analog) error {
+	return w.record("write_his_analog", magic, unitId, time, data)
+}
+
+func (w *recordWriter) WriteHisDigital(magic int32, unitId int64, time int64, data []Digital) error {
+	return w.record("write_his_digital", magic, unitId, time, data)
+}
+
+func (w *recordWriter) WriteStaticAnalog(magic int32, unitId int64, data []StaticAnalog, typ int64) error {
+	return w.record("write_static_analog", magic, unitId, data, typ)
+}
+
+func (w *recordWriter) WriteStaticDigital(magic int32, unitId int64, data []StaticDigital, typ int64) error {
+	return w.record("write_static_digital", magic, unitId, data, typ)
+}
+
+// newTestClient 通过内存管道连接客户端和 Serve, 测试结束时断开连接并等待 Serve 返回
+func newTestClient(t *testing.T, writer Writer) *Client {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- Serve(c2, c2, writer) }()
+	t.Cleanup(func() {
+		_ = c1.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve返回错误: %v", err)
+		}
+		_ = c2.Close()
+	})
+	return NewClient(c1, c1)
+}
+
+func TestProtocolRoundTrip(t *testing.T) {
+	writer := new(recordWriter)
+	client := newTestClient(t, writer)
+	times := []int64{1718350759143, 1718350759144}
+
+	tests := []struct {
+		name string
+		call func() error
+		args []any
+	}{
+		{"write_rt_analog", func() error {
+			return client.WriteRtAnalog(-1, 3, times[0], []Analog{testAnalog, {GlobalID: 1}}, true)
+		}, []any{int32(-1), int64(3), times[0], []Analog{testAnalog, {GlobalID: 1}}, true}},
+		{"write_rt_digital", func() error {
+			return client.WriteRtDigital(7, 0, times[1], []Digital{testDigital}, false)
+		}, []any{int32(7), int64(0), times[1], []Digital{testDigital}, false}},
+		{"write_rt_analog_list", func() error {
+			return client.WriteRtAnalogList(1, 2, times, [][]Analog{{testAnalog}, {}})
+		}, []any{int32(1), int64(2), times, [][]Analog{{testAnalog}, {}}}},
+		{"write_rt_digital_list", func() error {
+			return client.WriteRtDigitalList(1, 2, times, [][]Digital{{}, {testDigital, testDigital}})
+		}, []any{int32(1), int64(2), times, [][]Digital{{}, {testDigital, testDigital}}}},
+		{"write_his_analog", func() error {
+			return client.WriteHisAnalog(1, 2, times[0], []Analog{testAnalog})
+		}, []any{int32(1), int64(2), times[0], []Analog{testAnalog}}},
+		{"write_his_digital", func() error {
+			return client.WriteHisDigital(1, 2, times[0], []Digital{})
+		}, []any{int32(1), int64(2), times[0], []Digital{}}},
+		{"write_static_analog", func() error {
+			return client.WriteStaticAnalog(1, 2, []StaticAnalog{testStaticAnalog}, 2)
+		}, []any{int32(1), int64(2), []StaticAnalog{testStaticAnalog}, int64(2)}},
+		{"write_static_digital", func() error {
+			return client.WriteStaticDigital(1, 2, []StaticDigital{testStaticDigital, {}}, 0)
+		}, []any{int32(1), int64(2), []StaticDigital{testStaticDigital, {}}, int64(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatal(err)
+			}
+			op, args := writer.last()
+			if op != tt.name {
+				t.Fatalf("调用的接口为%v, 应为%v", op, tt.name)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Fatalf("参数不一致\ngot:  %+v\nwant: %+v", args, tt.args)
+			}
+		})
+	}
+
+	t.Run("plugin_info", func(t *testing.T) {
+		info, err := client.QueryInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info != testPluginInfo {
+			t.Fatalf("插件信息不一致\ngot:  %+v\nwant: %+v", info, testPluginInfo)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		writer.login = -3
+		if rtn := client.Login("user=a"); rtn != -3 {
+			t.Fatalf("login返回值为%v, 应为-3", rtn)
+		}
+		if op, args := writer.last(); op != "login" || !reflect.DeepEqual(args, []any{"user=a"}) {
+			t.Fatalf("调用不一致: %v, %v", op, args)
+		}
+		client.Logout()
+		if op, _ := writer.last(); op != "logout" {
+			t.Fatalf("调用的接口为%v, 应为logout", op)
+		}
+	})
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    ErrorCode
+		message string
+	}{
+		{"ok", nil, Ok, ""},
+		{"sdk_error", NewError(ErrOverload, "busy %v", 1), ErrOverload, "busy 1"},
+		{"other_error", errors.New("disk full"), ErrUnknown, "disk full"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &recordWriter{err: tt.err})
+			err := client.WriteHisAnalog(0, 0, 0, []Analog{testAnalog})
+			if code := ErrorCodeOf(err); code != tt.code {
+				t.Fatalf("错误码为%v, 应为%v: %v", code, tt.code, err)
+			}
+			var e *Error
+			if tt.err != nil && (!errors.As(err, &e) || e.Message != tt.message) {
+				t.Fatalf("错误信息不一致: %v, 应为%v", err, tt.message)
+			}
+		})
+	}
+}
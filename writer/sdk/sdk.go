@@ -0,0 +1,193 @@
+// Package sdk 纯Go的写入插件接口
+// 供使用Go或其他语言(通过外部进程插件协议或网络写入协议)实现写入插件的厂商使用, 不依赖cgo
+// 数据结构与 plugin/write_plugin.h 一一对应
+package sdk
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ABIVersion 插件ABI版本号, 与 plugin/write_plugin.h 中的 WRITE_PLUGIN_ABI_VERSION 一致
+const ABIVersion = 2
+
+// ErrorCode 写入接口返回的错误码, 与 plugin/write_plugin.h 中的 WRITE_ERR_* 一一对应
+type ErrorCode int32
+
+const (
+	Ok             ErrorCode = 0 // 写入成功
+	ErrUnknown     ErrorCode = 1 // 未知错误
+	ErrConnection  ErrorCode = 2 // 连接错误, 如网络断开, 未登录
+	ErrTimeout     ErrorCode = 3 // 写入超时
+	ErrInvalidData ErrorCode = 4 // 数据错误, 如点不存在, 数据类型不匹配
+	ErrOverload    ErrorCode = 5 // 数据库过载, 拒绝写入
+	ErrUnsupported ErrorCode = 6 // 插件不支持该接口
+)
+
+// Error 写入接口返回的错误, 插件返回其他类型的错误时按未知错误处理
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("错误码%d: %v", e.Code, e.Message)
+}
+
+// NewError 构造写入错误
+func NewError(code ErrorCode, format string, args ...any) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCodeOf 获取错误对应的错误码, nil对应 Ok
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return Ok
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ErrUnknown
+}
+
+// Analog 模拟量
+type Analog struct {
+	GlobalID int64   // 全局ID
+	PNum     int32   // P_NUM
+	AV       float32 // AV
+	AVR      float32 // AVR
+	Q        bool    // Q
+	BF       bool    // BF
+	QF       bool    // QF
+	FAI      float32 // FAI
+	MS       bool    // MS
+	TEW      byte    // TEW
+	CST      uint16  // CST
+}
+
+// Digital 数字量
+type Digital struct {
+	GlobalID int64  // 全局ID
+	PNum     int32  // P_NUM
+	DV       bool   // DV
+	DVR      bool   // DVR
+	Q        bool   // Q
+	BF       bool   // BF
+	BQ       bool   // FQ
+	FAI      bool   // FAI
+	MS       bool   // MS
+	TEW      byte   // TEW
+	CST      uint16 // CST
+}
+
+// StaticAnalog 静态模拟量
+type StaticAnalog struct {
+	GlobalID int64   // 全局ID
+	PNum     int32   // P_NUM
+	TAGT     uint16  // TAGT
+	FACK     uint16  // FACK
+	L4AR     bool    // L4AR
+	L3AR     bool    // L3AR
+	L2AR     bool    // L2AR
+	L1AR     bool    // L1AR
+	H4AR     bool    // H4AR
+	H3AR     bool    // H3AR
+	H2AR     bool    // H2AR
+	H1AR     bool    // H1AR
+	CHN      string  // CHN, 最长31字节
+	PN       string  // PN, 最长31字节
+	DESC     string  // DESC, 最长127字节
+	UNIT     string  // UNIT, 最长31字节
+	MU       float32 // MU
+	MD       float32 // MD
+}
+
+// StaticDigital 静态数字量
+type StaticDigital struct {
+	GlobalID int64  // 全局ID
+	PNum     int32  // P_NUM
+	FACK     uint16 // FACK
+	CHN      string // CHN, 最长31字节
+	PN       string // PN, 最长31字节
+	DESC     string // DESC, 最长127字节
+	UNIT     string // UNIT, 最长31字节
+}
+
+// PluginInfo 插件信息, 对应 plugin/write_plugin.h 中的 PluginInfo
+type PluginInfo struct {
+	ABIVersion    int    // ABI版本号
+	Vendor        string // 厂商名称
+	MaxBatchSize  int    // 批量写入接口单次最多写入的断面数量, 0表示不限制
+	Reentrant     bool   // 写入接口是否可重入
+	SupportList   bool   // 是否支持批量写入
+	SupportHis    bool   // 是否支持写历史值
+	SupportStatic bool   // 是否支持写静态值
+}
+
+// DefaultPluginInfo 插件未提供插件信息时使用的默认值
+func DefaultPluginInfo() PluginInfo {
+	return PluginInfo{
+		ABIVersion:    ABIVersion,
+		Reentrant:     true,
+		SupportList:   true,
+		SupportHis:    true,
+		SupportStatic: true,
+	}
+}
+
+func (info PluginInfo) String() string {
+	vendor := info.Vendor
+	if vendor == "" {
+		vendor = "未知"
+	}
+	return fmt.Sprintf("厂商: %v, ABI版本: %v, 最大批量写入断面数量: %v, 可重入: %v, 批量写入: %v, 历史值写入: %v, 静态值写入: %v",
+		vendor, info.ABIVersion, info.MaxBatchSize, info.Reentrant, info.SupportList, info.SupportHis, info.SupportStatic)
+}
+
+// Writer 写入插件接口, 语义与 plugin/write_plugin.h 中的同名C接口相同
+// 写入接口返回nil表示写入成功, 返回 *Error 时携带错误码, 其他错误按未知错误处理
+type Writer interface {
+	// Login 登陆数据库, param是命令行向login传递的参数
+	Login(param string) int
+
+	// Logout 登出数据库
+	Logout()
+
+	// WriteRtAnalog 写实时模拟量, isFast为true时表示写快采点
+	WriteRtAnalog(magic int32, unitId int64, time int64, data []Analog, isFast bool) error
+
+	// WriteRtDigital 写实时数字量, isFast为true时表示写快采点
+	WriteRtDigital(magic int32, unitId int64, time int64, data []Digital, isFast bool) error
+
+	// WriteRtAnalogList 批量写实时模拟量, times[i]为data[i]的断面时间, 只有写快采点的时候会调用此接口
+	WriteRtAnalogList(magic int32, unitId int64, times []int64, data [][]Analog) error
+
+	// WriteRtDigitalList 批量写实时数字量, times[i]为data[i]的断面时间, 只有写快采点的时候会调用此接口
+	WriteRtDigitalList(magic int32, unitId int64, times []int64, data [][]Digital) error
+
+	// WriteHisAnalog 写历史模拟量
+	WriteHisAnalog(magic int32, unitId int64, time int64, data []Analog) error
+
+	// WriteHisDigital 写历史数字量
+	WriteHisDigital(magic int32, unitId int64, time int64, data []Digital) error
+
+	// WriteStaticAnalog 写静态模拟量, typ: 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点
+	WriteStaticAnalog(magic int32, unitId int64, data []StaticAnalog, typ int64) error
+
+	// WriteStaticDigital 写静态数字量, typ: 0代表实时快采集点, 1代表实时普通点, 2代表历史普通点
+	WriteStaticDigital(magic int32, unitId int64, data []StaticDigital, typ int64) error
+}
+
+// InfoProvider 插件可选实现的接口, 未实现时使用 DefaultPluginInfo
+type InfoProvider interface {
+	Info() PluginInfo
+}
+
+// InfoOf 获取插件信息
+func InfoOf(w Writer) PluginInfo {
+	if p, ok := w.(InfoProvider); ok {
+		return p.Info()
+	}
+	return DefaultPluginInfo()
+}
@@ -0,0 +1,276 @@
+package main
+
+// #cgo CFLAGS: -I../plugin
+// #include "write_plugin.h"
+import "C"
+import (
+	"errors"
+	"writer/sdk"
+)
+
+// SdkWriter 将纯Go的 sdk.Writer 适配为插件后端
+// 写入前将C结构体转换为 sdk 中的Go结构体, Go插件和外部进程插件都通过它接入
+type SdkWriter struct {
+	writer sdk.Writer
+	info   PluginInfo
+}
+
+// NewSdkWriter 创建适配器, info为插件信息
+func NewSdkWriter(writer sdk.Writer, info PluginInfo) *SdkWriter {
+	return &SdkWriter{writer: writer, info: info}
+}
+
+func (w *SdkWriter) Info() PluginInfo {
+	return w.info
+}
+
+func (w *SdkWriter) Login(param string) int {
+	return w.writer.Login(param)
+}
+
+func (w *SdkWriter) Logout() {
+	w.writer.Logout()
+}
+
+func (w *SdkWriter) WriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error {
+	err := w.writer.WriteRtAnalog(magic, unitId, section.Time, ToSdkAnalogList(section.Data), isFast)
+	return NewSdkWriteError("write_rt_analog", unitId, err)
+}
+
+func (w *SdkWriter) WriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error {
+	err := w.writer.WriteRtDigital(magic, unitId, section.Time, ToSdkDigitalList(section.Data), isFast)
+	return NewSdkWriteError("write_rt_digital", unitId, err)
+}
+
+func (w *SdkWriter) WriteRtAnalogList(magic int32, unitId int64, sections []AnalogSection) error {
+	times := make([]int64, len(sections))
+	data := make([][]sdk.Analog, len(sections))
+	for i := range sections {
+		times[i] = sections[i].Time
+		data[i] = ToSdkAnalogList(sections[i].Data)
+	}
+	err := w.writer.WriteRtAnalogList(magic, unitId, times, data)
+	return NewSdkWriteError("write_rt_analog_list", unitId, err)
+}
+
+func (w *SdkWriter) WriteRtDigitalList(magic int32, unitId int64, sections []DigitalSection) error {
+	times := make([]int64, len(sections))
+	data := make([][]sdk.Digital, len(sections))
+	for i := range sections {
+		times[i] = sections[i].Time
+		data[i] = ToSdkDigitalList(sections[i].Data)
+	}
+	err := w.writer.WriteRtDigitalList(magic, unitId, times, data)
+	return NewSdkWriteError("write_rt_digital_list", unitId, err)
+}
+
+func (w *SdkWriter) WriteHisAnalog(magic int32, unitId int64, section AnalogSection) error {
+	err := w.writer.WriteHisAnalog(magic, unitId, section.Time, ToSdkAnalogList(section.Data))
+	return NewSdkWriteError("write_his_analog", unitId, err)
+}
+
+func (w *SdkWriter) WriteHisDigital(magic int32, unitId int64, section DigitalSection) error {
+	err := w.writer.WriteHisDigital(magic, unitId, section.Time, ToSdkDigitalList(section.Data))
+	return NewSdkWriteError("write_his_digital", unitId, err)
+}
+
+func (w *SdkWriter) WriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error {
+	data := make([]sdk.StaticAnalog, len(section.Data))
+	for i := range section.Data {
+		data[i] = ToSdkStaticAnalog(section.Data[i])
+	}
+	err := w.writer.WriteStaticAnalog(magic, unitId, data, typ)
+	return NewSdkWriteError("write_static_analog", unitId, err)
+}
+
+func (w *SdkWriter) WriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error {
+	data := make([]sdk.StaticDigital, len(section.Data))
+	for i := range section.Data {
+		data[i] = ToSdkStaticDigital(section.Data[i])
+	}
+	err := w.writer.WriteStaticDigital(magic, unitId, data, typ)
+	return NewSdkWriteError("write_static_digital", unitId, err)
+}
+
+// NewSdkWriteError 将 sdk.Writer 返回的错误转换为 *WriteError, 写入成功时返回nil
+func NewSdkWriteError(op string, unitId int64, err error) error {
+	if err == nil {
+		return nil
+	}
+	var sdkErr *sdk.Error
+	if errors.As(err, &sdkErr) {
+		return &WriteError{Op: op, UnitId: unitId, Code: WriteErrorCode(sdkErr.Code), Message: sdkErr.Message}
+	}
+	return &WriteError{Op: op, UnitId: unitId, Code: WriteErrUnknown, Message: err.Error()}
+}
+
+func ToSdkAnalogList(data []C.Analog) []sdk.Analog {
+	rtn := make([]sdk.Analog, len(data))
+	for i, a := range data {
+		rtn[i] = sdk.Analog{
+			GlobalID: int64(a.global_id),
+			PNum:     int32(a.p_num),
+			AV:       float32(a.av),
+			AVR:      float32(a.avr),
+			Q:        bool(a.q),
+			BF:       bool(a.bf),
+			QF:       bool(a.qf),
+			FAI:      float32(a.fai),
+			MS:       bool(a.ms),
+			TEW:      byte(a.tew),
+			CST:      uint16(a.cst),
+		}
+	}
+	return rtn
+}
+
+func ToSdkDigitalList(data []C.Digital) []sdk.Digital {
+	rtn := make([]sdk.Digital, len(data))
+	for i, d := range data {
+		rtn[i] = sdk.Digital{
+			GlobalID: int64(d.global_id),
+			PNum:     int32(d.p_num),
+			DV:       bool(d.dv),
+			DVR:      bool(d.dvr),
+			Q:        bool(d.q),
+			BF:       bool(d.bf),
+			BQ:       bool(d.bq),
+			FAI:      bool(d.fai),
+			MS:       bool(d.ms),
+			TEW:      byte(d.tew),
+			CST:      uint16(d.cst),
+		}
+	}
+	return rtn
+}
+
+func ToSdkStaticAnalog(s C.StaticAnalog) sdk.StaticAnalog {
+	return sdk.StaticAnalog{
+		GlobalID: int64(s.global_id),
+		PNum:     int32(s.p_num),
+		TAGT:     uint16(s.tagt),
+		FACK:     uint16(s.fack),
+		L4AR:     bool(s.l4ar),
+		L3AR:     bool(s.l3ar),
+		L2AR:     bool(s.l2ar),
+		L1AR:     bool(s.l1ar),
+		H4AR:     bool(s.h4ar),
+		H3AR:     bool(s.h3ar),
+		H2AR:     bool(s.h2ar),
+		H1AR:     bool(s.h1ar),
+		CHN:      CharArrayToString(s.chn[:]),
+		PN:       CharArrayToString(s.pn[:]),
+		DESC:     CharArrayToString(s.desc[:]),
+		UNIT:     CharArrayToString(s.unit[:]),
+		MU:       float32(s.mu),
+		MD:       float32(s.md),
+	}
+}
+
+func ToSdkStaticDigital(s C.StaticDigital) sdk.StaticDigital {
+	return sdk.StaticDigital{
+		GlobalID: int64(s.global_id),
+		PNum:     int32(s.p_num),
+		FACK:     uint16(s.fack),
+		CHN:      CharArrayToString(s.chn[:]),
+		PN:       CharArrayToString(s.pn[:]),
+		DESC:     CharArrayToString(s.desc[:]),
+		UNIT:     CharArrayToString(s.unit[:]),
+	}
+}
+
+// CharArrayToString C字符数组转换为字符串, 字符数组不以'\0'结尾时使用整个数组
+func CharArrayToString(chars []C.char) string {
+	b := make([]byte, 0, len(chars))
+	for _, c := range chars {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
+
+func FromSdkAnalogList(data []sdk.Analog) []C.Analog {
+	rtn := make([]C.Analog, len(data))
+	for i, a := range data {
+		rtn[i] = C.Analog{
+			global_id: C.int64_t(a.GlobalID),
+			p_num:     C.int32_t(a.PNum),
+			av:        C.float(a.AV),
+			avr:       C.float(a.AVR),
+			q:         C.bool(a.Q),
+			bf:        C.bool(a.BF),
+			qf:        C.bool(a.QF),
+			fai:       C.float(a.FAI),
+			ms:        C.bool(a.MS),
+			tew:       C.char(a.TEW),
+			cst:       C.uint16_t(a.CST),
+		}
+	}
+	return rtn
+}
+
+func FromSdkDigitalList(data []sdk.Digital) []C.Digital {
+	rtn := make([]C.Digital, len(data))
+	for i, d := range data {
+		rtn[i] = C.Digital{
+			global_id: C.int64_t(d.GlobalID),
+			p_num:     C.int32_t(d.PNum),
+			dv:        C.bool(d.DV),
+			dvr:       C.bool(d.DVR),
+			q:         C.bool(d.Q),
+			bf:        C.bool(d.BF),
+			bq:        C.bool(d.BQ),
+			fai:       C.bool(d.FAI),
+			ms:        C.bool(d.MS),
+			tew:       C.char(d.TEW),
+			cst:       C.uint16_t(d.CST),
+		}
+	}
+	return rtn
+}
+
+func FromSdkStaticAnalog(s sdk.StaticAnalog) C.StaticAnalog {
+	rtn := C.StaticAnalog{
+		global_id: C.int64_t(s.GlobalID),
+		p_num:     C.int32_t(s.PNum),
+		tagt:      C.uint16_t(s.TAGT),
+		fack:      C.uint16_t(s.FACK),
+		l4ar:      C.bool(s.L4AR),
+		l3ar:      C.bool(s.L3AR),
+		l2ar:      C.bool(s.L2AR),
+		l1ar:      C.bool(s.L1AR),
+		h4ar:      C.bool(s.H4AR),
+		h3ar:      C.bool(s.H3AR),
+		h2ar:      C.bool(s.H2AR),
+		h1ar:      C.bool(s.H1AR),
+		mu:        C.float(s.MU),
+		md:        C.float(s.MD),
+	}
+	StringToCharArray(rtn.chn[:], s.CHN)
+	StringToCharArray(rtn.pn[:], s.PN)
+	StringToCharArray(rtn.desc[:], s.DESC)
+	StringToCharArray(rtn.unit[:], s.UNIT)
+	return rtn
+}
+
+func FromSdkStaticDigital(s sdk.StaticDigital) C.StaticDigital {
+	rtn := C.StaticDigital{
+		global_id: C.int64_t(s.GlobalID),
+		p_num:     C.int32_t(s.PNum),
+		fack:      C.uint16_t(s.FACK),
+	}
+	StringToCharArray(rtn.chn[:], s.CHN)
+	StringToCharArray(rtn.pn[:], s.PN)
+	StringToCharArray(rtn.desc[:], s.DESC)
+	StringToCharArray(rtn.unit[:], s.UNIT)
+	return rtn
+}
+
+// StringToCharArray 字符串复制到C字符数组中, 与解析CSV时相同, 超出数组长度的部分被截断
+func StringToCharArray(dst []C.char, s string) {
+	for i := 0; i < len(s) && i < len(dst); i++ {
+		dst[i] = C.char(s[i])
+	}
+}
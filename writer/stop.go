@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// StopLimit 写入的停止条件, 通过 --duration, --max_sections, --max_pnums 指定, 为0表示不限制
+// 任意一个条件满足时通过 RequestStop 平滑退出, 与收到中断信号的处理相同
+// 正在进行的写入会继续完成, 实际写入的断面数量和PNUM数量可能略多于上限
+type StopLimit struct {
+	Duration    time.Duration // 写入时长, 从登录完成开始计时
+	MaxSections int64         // 快采点和普通点合计的断面数量上限
+	MaxPNums    int64         // 快采点和普通点合计的PNUM数量上限
+	sections    atomic.Int64
+	pnums       atomic.Int64
+	once        sync.Once
+	reason      atomic.Value // 触发的停止条件, 没有触发时为nil
+	timer       *time.Timer
+}
+
+// AddStopLimitFlags 添加停止条件的命令行参数
+func AddStopLimitFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("duration", 0, "写入时长, 如2h, 到达后平滑退出, 为0表示写完CSV为止")
+	cmd.Flags().Int64("max_sections", 0, "快采点和普通点合计写入的断面数量上限, 到达后平滑退出, 为0表示不限制")
+	cmd.Flags().Int64("max_pnums", 0, "快采点和普通点合计写入的PNUM数量上限, 到达后平滑退出, 为0表示不限制")
+}
+
+// NewStopLimit 读取并检查停止条件
+func NewStopLimit(cmd *cobra.Command) (*StopLimit, error) {
+	l := new(StopLimit)
+	l.Duration, _ = cmd.Flags().GetDuration("duration")
+	l.MaxSections, _ = cmd.Flags().GetInt64("max_sections")
+	l.MaxPNums, _ = cmd.Flags().GetInt64("max_pnums")
+	if l.Duration < 0 {
+		return nil, fmt.Errorf("写入时长不能小于0: %v", l.Duration)
+	}
+	if l.MaxSections < 0 {
+		return nil, fmt.Errorf("断面数量上限不能小于0: %v", l.MaxSections)
+	}
+	if l.MaxPNums < 0 {
+		return nil, fmt.Errorf("PNUM数量上限不能小于0: %v", l.MaxPNums)
+	}
+	return l, nil
+}
+
+// Start 开始计时, 写入时长为0时不计时
+func (l *StopLimit) Start() {
+	if l.Duration > 0 {
+		l.timer = time.AfterFunc(l.Duration, func() {
+			l.stop(fmt.Sprintf("达到写入时长: %v", l.Duration))
+		})
+	}
+}
+
+// Stop 写入结束后停止计时
+func (l *StopLimit) Stop() {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+}
+
+// Reason 触发的停止条件, 没有触发时返回空字符串
+func (l *StopLimit) Reason() string {
+	if reason, ok := l.reason.Load().(string); ok {
+		return reason
+	}
+	return ""
+}
+
+// record 记录一次写入的断面数量和PNUM数量, l为nil时忽略
+func (l *StopLimit) record(sections int64, pnums int64) {
+	if l == nil {
+		return
+	}
+	s := l.sections.Add(sections)
+	p := l.pnums.Add(pnums)
+	if l.MaxSections > 0 && s >= l.MaxSections {
+		l.stop(fmt.Sprintf("达到断面数量上限: %v", l.MaxSections))
+	} else if l.MaxPNums > 0 && p >= l.MaxPNums {
+		l.stop(fmt.Sprintf("达到PNUM数量上限: %v", l.MaxPNums))
+	}
+}
+
+// stop 第一次触发停止条件时请求平滑退出
+func (l *StopLimit) stop(reason string) {
+	l.once.Do(func() {
+		l.reason.Store(reason)
+		RequestStop(reason)
+	})
+}
+
+// LogStopReason 输出触发的停止条件, 没有触发时不输出
+func (l *StopLimit) LogStopReason() {
+	if reason := l.Reason(); reason != "" {
+		log.Printf("停止条件: %v\n", reason)
+	}
+}
+
+// FaultEvent 写入过程中发生的故障事件, 如插件宿主进程崩溃
+type FaultEvent struct {
+	Time    time.Time // 发生时间
+	Kind    string    // 故障类型
+	Message string    // 详细信息
+}
+
+var FaultEventList = make([]FaultEvent, 0)
+var faultEventLock = new(sync.Mutex)
+
+// RecordFaultEvent 记录故障事件, 统计时输出
+func RecordFaultEvent(kind string, message string) {
+	faultEventLock.Lock()
+	defer faultEventLock.Unlock()
+	FaultEventList = append(FaultEventList, FaultEvent{Time: time.Now(), Kind: kind, Message: message})
+	log.Printf("故障事件: %v, %v\n", kind, message)
+}
+
+// ResetFaultEvents 清空故障事件, 执行测试场景时每个阶段开始前调用, 每个阶段只统计本阶段的故障事件
+func ResetFaultEvents() {
+	faultEventLock.Lock()
+	defer faultEventLock.Unlock()
+	FaultEventList = make([]FaultEvent, 0)
+}
+
+// LogFaultEvents 输出所有故障事件, 没有故障事件时不输出
+func LogFaultEvents() {
+	faultEventLock.Lock()
+	defer faultEventLock.Unlock()
+	if len(FaultEventList) == 0 {
+		return
+	}
+	log.Printf("故障事件数量: %v\n", len(FaultEventList))
+	for _, event := range FaultEventList {
+		log.Printf("\t%v %v: %v\n", event.Time.Format(time.RFC3339Nano), event.Kind, event.Message)
+	}
+}
+
+var stopChannels = make([]chan os.Signal, 0)
+var stopChannelsLock = new(sync.Mutex)
+
+// NotifyStop 注册平滑退出, 收到中断信号或调用 RequestStop 时向ch发送信号
+func NotifyStop(ch chan os.Signal) {
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	stopChannelsLock.Lock()
+	defer stopChannelsLock.Unlock()
+	stopChannels = append(stopChannels, ch)
+}
+
+// StopNotify 取消注册平滑退出, 写入结束后调用, 避免之后的中断信号或 RequestStop 发送到已经结束的写入
+func StopNotify(ch chan os.Signal) {
+	signal.Stop(ch)
+	stopChannelsLock.Lock()
+	defer stopChannelsLock.Unlock()
+	for i, c := range stopChannels {
+		if c == ch {
+			stopChannels = append(stopChannels[:i], stopChannels[i+1:]...)
+			break
+		}
+	}
+}
+
+// RequestStop 主动触发平滑退出, 效果与收到中断信号相同
+func RequestStop(reason string) {
+	log.Printf("请求平滑退出: %v\n", reason)
+	stopChannelsLock.Lock()
+	defer stopChannelsLock.Unlock()
+	for _, ch := range stopChannels {
+		select {
+		case ch <- syscall.SIGTERM:
+		default:
+		}
+	}
+}
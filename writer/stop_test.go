@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStopLimitSections(t *testing.T) {
+	tests := []struct {
+		name        string
+		analog      int64 // 每次写入的模拟量断面数量
+		digital     int64 // 每次写入的数字量断面数量
+		maxSections int64
+		maxPNums    int64
+		writes      int    // 写入次数
+		reason      string // 触发的停止条件, 为空表示不触发
+	}{
+		{"只有模拟量", 1, 0, 3, 0, 3, "达到断面数量上限: 3"},
+		{"只有数字量", 0, 1, 3, 0, 3, "达到断面数量上限: 3"},
+		{"只有数字量未达到上限", 0, 1, 3, 0, 2, ""},
+		{"模拟量和数字量不重复计算", 1, 1, 3, 0, 2, ""},
+		{"批量写入", 0, 5, 10, 0, 2, "达到断面数量上限: 10"},
+		{"只有数字量的PNUM", 0, 1, 0, 25, 3, "达到PNUM数量上限: 25"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan os.Signal, 1)
+			NotifyStop(ch)
+			defer StopNotify(ch)
+
+			stats, err := NewWriteStats("fast", DefaultHistogramPrecision, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats.limit = &StopLimit{MaxSections: tt.maxSections, MaxPNums: tt.maxPNums}
+			for i := 0; i < tt.writes; i++ {
+				stats.Record(
+					WriteSectionInfo{UnitNumber: 1, SectionCount: tt.analog, PNumCount: tt.analog * 10},
+					WriteSectionInfo{UnitNumber: 1, SectionCount: tt.digital, PNumCount: tt.digital * 10},
+				)
+			}
+			if reason := stats.limit.Reason(); reason != tt.reason {
+				t.Fatalf("停止条件为%q, 应为%q", reason, tt.reason)
+			}
+			if stopped := len(ch) == 1; stopped != (tt.reason != "") {
+				t.Fatalf("是否请求平滑退出: %v", stopped)
+			}
+			want := int64(tt.writes) * max(tt.analog, tt.digital)
+			if stats.Total.SectionCount != want {
+				t.Fatalf("断面数量为%v, 应为%v", stats.Total.SectionCount, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceRecord 断面写入明细, 通过 --trace 输出, 每次写入的模拟量或数字量对应一条记录
+type TraceRecord struct {
+	Kind         string     `json:"kind"`               // 断面类型: fast_analog, fast_digital, normal_analog, normal_digital
+	UnitNumber   int64      `json:"unit_number"`        // 机组数量
+	SectionTime  int64      `json:"section_time"`       // 断面时间, 静态写入时为-1
+	Start        time.Time  `json:"start"`              // 开始写入的时间
+	Intended     *time.Time `json:"intended,omitempty"` // 计划写入的时间, 只有周期性写入时输出
+	DriftNs      *int64     `json:"drift_ns,omitempty"` // 开始写入的时间与计划写入时间的偏差
+	DurationNs   int64      `json:"duration_ns"`        // 写入耗时
+	SectionCount int64      `json:"section_count"`
+	PNumCount    int64      `json:"pnum_count"`
+	ErrorCount   int        `json:"error_count"` // 写入失败的机组数量
+	ErrorCodes   []int      `json:"error_codes"` // 每个写入失败的机组对应的错误码
+}
+
+// NewTraceRecord 将一次写入的信息转换为明细记录
+func NewTraceRecord(kind string, info WriteSectionInfo) TraceRecord {
+	codes := make([]int, 0, len(info.Errors))
+	for _, err := range info.Errors {
+		codes = append(codes, int(WriteErrorCodeOf(err)))
+	}
+	r := TraceRecord{
+		Kind:         kind,
+		UnitNumber:   info.UnitNumber,
+		SectionTime:  info.Time,
+		Start:        info.Start,
+		DurationNs:   int64(info.Duration),
+		SectionCount: info.SectionCount,
+		PNumCount:    info.PNumCount,
+		ErrorCount:   len(info.Errors),
+		ErrorCodes:   codes,
+	}
+	if !info.Intended.IsZero() {
+		intended := info.Intended
+		drift := int64(info.Start.Sub(intended))
+		r.Intended = &intended
+		r.DriftNs = &drift
+	}
+	return r
+}
+
+// TraceWriter 写入明细输出, 边写入边输出, 不在内存中保留明细
+type TraceWriter struct {
+	lock    *sync.Mutex
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	csv     *csv.Writer   // 输出CSV格式时不为nil
+	json    *json.Encoder // 输出JSON Lines格式时不为nil
+	count   int
+	lastErr error
+}
+
+// NewTraceWriter 创建明细文件, 路径以.csv结尾时输出CSV格式, 否则输出JSON Lines格式(每行一条记录)
+func NewTraceWriter(path string) (*TraceWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &TraceWriter{lock: new(sync.Mutex), path: path, file: file, w: bufio.NewWriter(file)}
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		t.csv = csv.NewWriter(t.w)
+		_ = t.csv.Write([]string{"kind", "unit_number", "section_time", "start", "start_unix_ns", "intended_unix_ns", "drift_ns", "duration_ns", "section_count", "pnum_count", "error_count", "error_codes"})
+	} else {
+		t.json = json.NewEncoder(t.w)
+	}
+	return t, nil
+}
+
+// Write 输出一次写入的明细, t为nil时忽略; 出错时只记录第一个错误, 关闭时返回
+func (t *TraceWriter) Write(kind string, info WriteSectionInfo) {
+	if t == nil {
+		return
+	}
+	r := NewTraceRecord(kind, info)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.lastErr != nil {
+		return
+	}
+	t.count++
+	if t.csv != nil {
+		intended, drift := "", ""
+		if r.Intended != nil {
+			intended = strconv.FormatInt(r.Intended.UnixNano(), 10)
+			drift = strconv.FormatInt(*r.DriftNs, 10)
+		}
+		codes := make([]string, 0, len(r.ErrorCodes))
+		for _, code := range r.ErrorCodes {
+			codes = append(codes, strconv.Itoa(code))
+		}
+		t.lastErr = t.csv.Write([]string{
+			r.Kind,
+			strconv.FormatInt(r.UnitNumber, 10),
+			strconv.FormatInt(r.SectionTime, 10),
+			r.Start.Format(time.RFC3339Nano),
+			strconv.FormatInt(r.Start.UnixNano(), 10),
+			intended,
+			drift,
+			strconv.FormatInt(r.DurationNs, 10),
+			strconv.FormatInt(r.SectionCount, 10),
+			strconv.FormatInt(r.PNumCount, 10),
+			strconv.Itoa(r.ErrorCount),
+			strings.Join(codes, ";"),
+		})
+	} else {
+		t.lastErr = t.json.Encode(r)
+	}
+}
+
+// Close 刷新缓冲区并关闭文件
+func (t *TraceWriter) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.csv != nil {
+		t.csv.Flush()
+		if t.lastErr == nil {
+			t.lastErr = t.csv.Error()
+		}
+	}
+	if err := t.w.Flush(); err != nil && t.lastErr == nil {
+		t.lastErr = err
+	}
+	if err := t.file.Close(); err != nil && t.lastErr == nil {
+		t.lastErr = err
+	}
+	return t.lastErr
+}
@@ -0,0 +1,322 @@
+package main
+
+// #include "write_plugin.h"
+import "C"
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ValueModel 数值模型, 写入前对每个机组的每个值施加变化, 通过 --value_model 指定
+type ValueModel interface {
+	// Analog 模拟量的变化量, 加到av上(--value_avr 时同时加到avr上)
+	Analog(ctx *ValueContext) float64
+	// Digital 是否翻转数字量的dv
+	Digital(ctx *ValueContext) bool
+}
+
+// ValueContext 一个值的上下文, 随机数由种子, 模型序号, GlobalID和断面时间决定, 与写入顺序和并发无关
+type ValueContext struct {
+	UnitId int64 // 机组ID
+	Time   int64 // 断面时间, 单位毫秒
+	Origin int64 // 第一个写入的断面时间, 单位毫秒
+	key    uint64
+}
+
+// Rand 第n个[0,1)之间的随机数, 同一个值的同一个n返回相同的结果
+func (ctx *ValueContext) Rand(n uint64) float64 {
+	return float64(splitmix64(ctx.key+n*0x9E3779B97F4A7C15)>>11) / (1 << 53)
+}
+
+// Normal 第n个标准正态分布的随机数, 占用n和n+1
+func (ctx *ValueContext) Normal(n uint64) float64 {
+	u1 := 1 - ctx.Rand(n)
+	u2 := ctx.Rand(n + 1)
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
+
+// ValueModels 支持的数值模型, 格式为 名称:参数1:参数2...
+// * uniform:MIN:MAX 加上[MIN, MAX)之间均匀分布的随机数, --random_av 等同于 uniform:0:30
+// * noise:SIGMA 加上标准差为SIGMA的高斯噪声
+// * drift:RATE 按断面时间线性漂移, 每秒漂移RATE, 从第一个写入的断面开始计算
+// * wave:AMPLITUDE:PERIOD:UNIT_PHASE 加上振幅为AMPLITUDE, 周期为PERIOD(如10m)的正弦波, 机组i的相位偏移i*UNIT_PHASE(如30s)
+// * flip:RATE 数字量的dv以RATE的概率翻转
+var ValueModels = map[string]func(args []string) (ValueModel, error){
+	"uniform": func(args []string) (ValueModel, error) {
+		v, err := parseModelFloats(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if v[0] > v[1] {
+			return nil, fmt.Errorf("MIN不能大于MAX")
+		}
+		return uniformModel{min: v[0], max: v[1]}, nil
+	},
+	"noise": func(args []string) (ValueModel, error) {
+		v, err := parseModelFloats(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if v[0] < 0 {
+			return nil, fmt.Errorf("SIGMA不能小于0")
+		}
+		return noiseModel{sigma: v[0]}, nil
+	},
+	"drift": func(args []string) (ValueModel, error) {
+		v, err := parseModelFloats(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return driftModel{rate: v[0]}, nil
+	},
+	"wave": func(args []string) (ValueModel, error) {
+		if len(args) != 3 {
+			return nil, fmt.Errorf("需要3个参数")
+		}
+		amplitude, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		period, err := time.ParseDuration(args[1])
+		if err != nil {
+			return nil, err
+		}
+		if period <= 0 {
+			return nil, fmt.Errorf("PERIOD必须大于0")
+		}
+		phase, err := time.ParseDuration(args[2])
+		if err != nil {
+			return nil, err
+		}
+		return waveModel{amplitude: amplitude, period: period, phase: phase}, nil
+	},
+	"flip": func(args []string) (ValueModel, error) {
+		v, err := parseModelFloats(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if v[0] < 0 || v[0] > 1 {
+			return nil, fmt.Errorf("RATE取值范围[0, 1]")
+		}
+		return flipModel{rate: v[0]}, nil
+	},
+}
+
+func parseModelFloats(args []string, n int) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("需要%v个参数", n)
+	}
+	rtn := make([]float64, n)
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, err
+		}
+		rtn[i] = v
+	}
+	return rtn, nil
+}
+
+type uniformModel struct{ min, max float64 }
+
+func (m uniformModel) Analog(ctx *ValueContext) float64 {
+	return m.min + (m.max-m.min)*ctx.Rand(0)
+}
+
+func (m uniformModel) Digital(*ValueContext) bool { return false }
+
+type noiseModel struct{ sigma float64 }
+
+func (m noiseModel) Analog(ctx *ValueContext) float64 {
+	return m.sigma * ctx.Normal(0)
+}
+
+func (m noiseModel) Digital(*ValueContext) bool { return false }
+
+type driftModel struct{ rate float64 }
+
+func (m driftModel) Analog(ctx *ValueContext) float64 {
+	return m.rate * float64(ctx.Time-ctx.Origin) / 1000
+}
+
+func (m driftModel) Digital(*ValueContext) bool { return false }
+
+type waveModel struct {
+	amplitude float64
+	period    time.Duration
+	phase     time.Duration
+}
+
+func (m waveModel) Analog(ctx *ValueContext) float64 {
+	t := time.Duration(ctx.Time)*time.Millisecond + time.Duration(ctx.UnitId)*m.phase
+	return m.amplitude * math.Sin(2*math.Pi*float64(t%m.period)/float64(m.period))
+}
+
+func (m waveModel) Digital(*ValueContext) bool { return false }
+
+type flipModel struct{ rate float64 }
+
+func (m flipModel) Analog(*ValueContext) float64 { return 0 }
+
+func (m flipModel) Digital(ctx *ValueContext) bool {
+	return ctx.Rand(0) < m.rate
+}
+
+// ValueTransform 写入前的数值变化, 按顺序叠加多个数值模型, 每个机组得到不同但可复现的数据
+// 为nil时不修改数据
+type ValueTransform struct {
+	Specs  []string // 数值模型
+	Seed   int64    // 随机数种子
+	Avr    bool     // 模拟量的变化量是否同时加到avr上
+	models []ValueModel
+	origin atomic.Int64 // 第一个写入的断面时间, 为0表示还没有写入
+}
+
+// AddValueTransformFlags 添加数值模型的命令行参数
+func AddValueTransformFlags(cmd *cobra.Command) {
+	cmd.Flags().String("value_model", "", "写入前施加的数值模型, 多个模型用逗号分隔并按顺序叠加, 如noise:0.5,drift:0.01, 支持: "+valueModelNames())
+	cmd.Flags().Int64("value_seed", 0, "数值模型的随机数种子, 相同的种子和数据集生成相同的数据")
+	cmd.Flags().Bool("value_avr", false, "为true时模拟量的变化量同时加到avr上")
+}
+
+// valueModelNames 支持的数值模型名称, 用于命令行帮助
+func valueModelNames() string {
+	names := make([]string, 0, len(ValueModels))
+	for name := range ValueModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NewValueTransform 读取并解析数值模型, --random_av 等同于追加 uniform:0:30; 没有数值模型时返回nil
+func NewValueTransform(cmd *cobra.Command) (*ValueTransform, error) {
+	t := new(ValueTransform)
+	spec, _ := cmd.Flags().GetString("value_model")
+	randomAv, _ := cmd.Flags().GetBool("random_av")
+	t.Seed, _ = cmd.Flags().GetInt64("value_seed")
+	t.Avr, _ = cmd.Flags().GetBool("value_avr")
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			t.Specs = append(t.Specs, s)
+		}
+	}
+	if randomAv {
+		t.Specs = append(t.Specs, "uniform:0:30")
+	}
+	if len(t.Specs) == 0 {
+		return nil, nil
+	}
+	if err := t.parse(); err != nil {
+		return nil, err
+	}
+	log.Printf("数值模型: %v, 随机数种子: %v\n", strings.Join(t.Specs, ","), t.Seed)
+	return t, nil
+}
+
+// parse 解析Specs中的数值模型
+func (t *ValueTransform) parse() error {
+	t.models = make([]ValueModel, 0, len(t.Specs))
+	for _, s := range t.Specs {
+		parts := strings.Split(s, ":")
+		newModel, ok := ValueModels[parts[0]]
+		if !ok {
+			return fmt.Errorf("未知的数值模型: %v", s)
+		}
+		model, err := newModel(parts[1:])
+		if err != nil {
+			return fmt.Errorf("数值模型参数错误: %v, %v", s, err)
+		}
+		t.models = append(t.models, model)
+	}
+	return nil
+}
+
+// newContext 一个断面的值上下文, 同一个断面的所有值和模型共用, 施加模型前设置UnitId和key
+func (t *ValueTransform) newContext(ts int64) *ValueContext {
+	t.origin.CompareAndSwap(0, ts)
+	return &ValueContext{Time: ts, Origin: t.origin.Load()}
+}
+
+// key 第model个模型在ts时刻对globalId的随机数种子
+func (t *ValueTransform) key(ts int64, globalId int64, model int) uint64 {
+	return splitmix64(splitmix64(uint64(t.Seed)) ^ splitmix64(uint64(globalId)+uint64(model)<<56) ^ uint64(ts)*0xD6E8FEB86659FD93)
+}
+
+// analogDelta 模拟量的变化量, ctx的UnitId需要已经设置
+func (t *ValueTransform) analogDelta(ctx *ValueContext, globalId int64) float64 {
+	delta := 0.0
+	for j, model := range t.models {
+		ctx.key = t.key(ctx.Time, globalId, j)
+		delta += model.Analog(ctx)
+	}
+	return delta
+}
+
+// digitalFlip 数字量是否翻转, 每个模型翻转一次, ctx的UnitId需要已经设置
+func (t *ValueTransform) digitalFlip(ctx *ValueContext, globalId int64) bool {
+	flip := false
+	for j, model := range t.models {
+		ctx.key = t.key(ctx.Time, globalId, j)
+		if model.Digital(ctx) {
+			flip = !flip
+		}
+	}
+	return flip
+}
+
+// Section 对断面施加数值模型, 返回按机组保存数据的新断面, 不修改原断面; t为nil时原样返回
+// 在写入计时开始前调用, 数值模型的耗时不计入写入耗时; GlobalID与写入时 InitAnalogGlobalID, InitDigitalGlobalID 的结果相同
+func (t *ValueTransform) Section(magic int32, unitNumber int64, section Section, isRt bool, isFast bool) Section {
+	if t == nil {
+		return section
+	}
+	if section.analogOk {
+		ctx := t.newContext(section.analog.Time)
+		units := make([][]C.Analog, unitNumber)
+		for unitId := range units {
+			ctx.UnitId = int64(unitId)
+			data := append([]C.Analog(nil), section.analog.Unit(int64(unitId)).Data...)
+			for i := range data {
+				delta := t.analogDelta(ctx, GlobalID(magic, int64(unitId), true, isFast, isRt, int32(data[i].p_num)))
+				data[i].av = C.float(float64(data[i].av) + delta)
+				if t.Avr {
+					data[i].avr = C.float(float64(data[i].avr) + delta)
+				}
+			}
+			units[unitId] = data
+		}
+		section.analog = AnalogSection{Time: section.analog.Time, Units: units}
+	}
+	if section.digitalOk {
+		ctx := t.newContext(section.digital.Time)
+		units := make([][]C.Digital, unitNumber)
+		for unitId := range units {
+			ctx.UnitId = int64(unitId)
+			data := append([]C.Digital(nil), section.digital.Unit(int64(unitId)).Data...)
+			for i := range data {
+				if t.digitalFlip(ctx, GlobalID(magic, int64(unitId), false, isFast, isRt, int32(data[i].p_num))) {
+					data[i].dv = !data[i].dv
+				}
+			}
+			units[unitId] = data
+		}
+		section.digital = DigitalSection{Time: section.digital.Time, Units: units}
+	}
+	return section
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newTestTransform 解析specs的数值模型
+func newTestTransform(t *testing.T, seed int64, specs ...string) *ValueTransform {
+	t.Helper()
+	transform := &ValueTransform{Specs: specs, Seed: seed}
+	if err := transform.parse(); err != nil {
+		t.Fatal(err)
+	}
+	return transform
+}
+
+// transformValues 依次对时间戳为times的断面施加数值模型, 返回每个断面每个机组的av和dv
+func transformValues(t *testing.T, transform *ValueTransform, unitNumber int64, times ...int64) ([][][]float64, [][][]bool) {
+	t.Helper()
+	avs, dvs := make([][][]float64, 0), make([][][]bool, 0)
+	for _, ts := range times {
+		section := Section{
+			analogOk: true, analog: testAnalogSection(t, ts, 1, 2, 3),
+			digitalOk: true, digital: testDigitalSection(t, ts, 1, 2, 3),
+		}
+		section = transform.Section(0, unitNumber, section, true, true)
+		av, dv := make([][]float64, unitNumber), make([][]bool, unitNumber)
+		for unitId := int64(0); unitId < unitNumber; unitId++ {
+			for _, a := range section.analog.Unit(unitId).Data {
+				av[unitId] = append(av[unitId], float64(a.av))
+			}
+			for _, d := range section.digital.Unit(unitId).Data {
+				dv[unitId] = append(dv[unitId], bool(d.dv))
+			}
+		}
+		avs, dvs = append(avs, av), append(dvs, dv)
+	}
+	return avs, dvs
+}
+
+func TestValueTransformSeed(t *testing.T) {
+	specs := []string{"noise:1", "uniform:0:30", "drift:0.5", "wave:10:1s:100ms", "flip:0.5"}
+	times := []int64{1000, 1100, 1200, 1300}
+	avs, dvs := transformValues(t, newTestTransform(t, 1, specs...), 3, times...)
+
+	t.Run("相同的种子", func(t *testing.T) {
+		avs2, dvs2 := transformValues(t, newTestTransform(t, 1, specs...), 3, times...)
+		if !reflect.DeepEqual(avs, avs2) || !reflect.DeepEqual(dvs, dvs2) {
+			t.Fatalf("相同的种子生成了不同的数据\n%v\n%v", avs, avs2)
+		}
+	})
+
+	t.Run("不同的种子", func(t *testing.T) {
+		avs2, dvs2 := transformValues(t, newTestTransform(t, 2, specs...), 3, times...)
+		if reflect.DeepEqual(avs, avs2) || reflect.DeepEqual(dvs, dvs2) {
+			t.Fatal("不同的种子生成了相同的数据")
+		}
+	})
+
+	t.Run("不同的机组", func(t *testing.T) {
+		for i := range times {
+			if reflect.DeepEqual(avs[i][0], avs[i][1]) || reflect.DeepEqual(avs[i][1], avs[i][2]) {
+				t.Fatalf("断面%v的机组数据相同: %v", times[i], avs[i])
+			}
+		}
+	})
+
+	t.Run("不修改原断面", func(t *testing.T) {
+		section := Section{analogOk: true, analog: testAnalogSection(t, 1000, 1, 2, 3), digitalOk: true, digital: testDigitalSection(t, 1000, 1, 2, 3)}
+		newTestTransform(t, 1, specs...).Section(0, 3, section, true, true)
+		if av := float64(section.analog.Data[0].av); av != 1 {
+			t.Fatalf("原断面的av被修改为%v", av)
+		}
+		if dv := bool(section.digital.Data[0].dv); !dv {
+			t.Fatal("原断面的dv被修改")
+		}
+	})
+}
+
+func TestValueTransformNil(t *testing.T) {
+	var transform *ValueTransform
+	section := Section{analogOk: true, analog: testAnalogSection(t, 1000, 1)}
+	if got := transform.Section(0, 2, section, true, true); got.analog.Units != nil || len(got.analog.Data) != 1 {
+		t.Fatal("没有数值模型时不应修改断面")
+	}
+}
+
+func TestValueModels(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"uniform:0:30", true},
+		{"uniform:30:0", false},
+		{"uniform:0", false},
+		{"noise:0.5", true},
+		{"noise:-1", false},
+		{"drift:-0.1", true},
+		{"drift:x", false},
+		{"wave:10:10m:30s", true},
+		{"wave:10:0s:30s", false},
+		{"wave:10:10m", false},
+		{"flip:0.01", true},
+		{"flip:1.5", false},
+		{"unknown:1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			transform := &ValueTransform{Specs: []string{tt.spec}}
+			if err := transform.parse(); (err == nil) != tt.ok {
+				t.Fatalf("parse(%v): err=%v", tt.spec, err)
+			}
+		})
+	}
+}
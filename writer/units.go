@@ -0,0 +1,151 @@
+package main
+
+// #include "write_plugin.h"
+import "C"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// UnitCsvPrefix 按机组读取CSV时每个机组的文件名前缀, 如unit_0_1718350759143_REALTIME_FAST_ANALOG.csv
+const UnitCsvPrefix = "unit_%d_"
+
+// UnitCsvPath 机组unitId的CSV文件路径, 在path的文件名前加上机组前缀, 机组编号与GlobalID中的unit_id一致, 从0开始
+func UnitCsvPath(path string, unitId int64) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(UnitCsvPrefix, unitId)+filepath.Base(path))
+}
+
+// csvPaths 需要读取的CSV文件, 按机组读取时每个机组一个文件, 否则所有机组共用path
+func (options CsvOptions) csvPaths(path string) []string {
+	if !options.PerUnit {
+		return []string{path}
+	}
+	paths := make([]string, 0, options.UnitNumber)
+	for i := int64(0); i < options.UnitNumber; i++ {
+		paths = append(paths, UnitCsvPath(path, i))
+	}
+	return paths
+}
+
+// UnitCsvFlags 按机组读取时需要检查的CSV参数
+var UnitCsvFlags = []string{"rt_fast_analog", "rt_fast_digital", "rt_normal_analog", "rt_normal_digital", "his_normal_analog", "his_normal_digital"}
+
+// checkUnitCsv 登录前检查命令指定的每个CSV都有所有机组的文件
+func (options CsvOptions) checkUnitCsv(cmd *cobra.Command) error {
+	for _, name := range UnitCsvFlags {
+		if cmd.Flags().Lookup(name) == nil {
+			continue
+		}
+		path, _ := cmd.Flags().GetString(name)
+		if path == "" {
+			continue
+		}
+		for _, unitPath := range options.csvPaths(path) {
+			if _, err := os.Stat(unitPath); err != nil {
+				return fmt.Errorf("按机组读取CSV, --%v 缺少机组文件: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Unit 机组unitId的断面, 按机组读取CSV时返回该机组的数据, 否则所有机组使用相同的数据
+func (s AnalogSection) Unit(unitId int64) AnalogSection {
+	if s.Units == nil {
+		return s
+	}
+	return AnalogSection{Time: s.Time, Data: s.Units[unitId]}
+}
+
+// Unit 机组unitId的断面, 按机组读取CSV时返回该机组的数据, 否则所有机组使用相同的数据
+func (s DigitalSection) Unit(unitId int64) DigitalSection {
+	if s.Units == nil {
+		return s
+	}
+	return DigitalSection{Time: s.Time, Data: s.Units[unitId]}
+}
+
+// PNumCount 一个机组的PNUM数量, 按机组读取CSV时为各机组中最多的PNUM数量
+func (s AnalogSection) PNumCount() int64 {
+	n := len(s.Data)
+	for _, data := range s.Units {
+		if len(data) > n {
+			n = len(data)
+		}
+	}
+	return int64(n)
+}
+
+// PNumCount 一个机组的PNUM数量, 按机组读取CSV时为各机组中最多的PNUM数量
+func (s DigitalSection) PNumCount() int64 {
+	n := len(s.Data)
+	for _, data := range s.Units {
+		if len(data) > n {
+			n = len(data)
+		}
+	}
+	return int64(n)
+}
+
+// MergeAnalogUnits 按时间戳合并各机组的模拟量断面, chs[i]为机组i的断面
+// 每次取各机组中最小的时间戳合并为一个断面, 没有该时间戳的机组数据为空, 写入时跳过
+func MergeAnalogUnits(chs []chan AnalogSection, ch chan AnalogSection) {
+	heads := make([]AnalogSection, len(chs))
+	oks := make([]bool, len(chs))
+	for i := range chs {
+		heads[i], oks[i] = <-chs[i]
+	}
+	for {
+		ts, found := int64(0), false
+		for i := range chs {
+			if oks[i] && (!found || heads[i].Time < ts) {
+				ts, found = heads[i].Time, true
+			}
+		}
+		if !found {
+			close(ch)
+			return
+		}
+		section := AnalogSection{Time: ts, Units: make([][]C.Analog, len(chs))}
+		for i := range chs {
+			if oks[i] && heads[i].Time == ts {
+				section.Units[i] = heads[i].Data
+				heads[i], oks[i] = <-chs[i]
+			}
+		}
+		ch <- section
+	}
+}
+
+// MergeDigitalUnits 按时间戳合并各机组的数字量断面, chs[i]为机组i的断面
+// 每次取各机组中最小的时间戳合并为一个断面, 没有该时间戳的机组数据为空, 写入时跳过
+func MergeDigitalUnits(chs []chan DigitalSection, ch chan DigitalSection) {
+	heads := make([]DigitalSection, len(chs))
+	oks := make([]bool, len(chs))
+	for i := range chs {
+		heads[i], oks[i] = <-chs[i]
+	}
+	for {
+		ts, found := int64(0), false
+		for i := range chs {
+			if oks[i] && (!found || heads[i].Time < ts) {
+				ts, found = heads[i].Time, true
+			}
+		}
+		if !found {
+			close(ch)
+			return
+		}
+		section := DigitalSection{Time: ts, Units: make([][]C.Digital, len(chs))}
+		for i := range chs {
+			if oks[i] && heads[i].Time == ts {
+				section.Units[i] = heads[i].Data
+				heads[i], oks[i] = <-chs[i]
+			}
+		}
+		ch <- section
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// testAnalogSection 时间戳为ts的模拟量断面, 每个P_NUM的av为P_NUM
+func testAnalogSection(t *testing.T, ts int64, pNums ...int) AnalogSection {
+	t.Helper()
+	section := AnalogSection{Time: ts}
+	for _, pNum := range pNums {
+		_, a, err := ParseAnalogRecord([]string{strconv.FormatInt(ts, 10), strconv.Itoa(pNum), strconv.Itoa(pNum), "0.5",
+			"False", "False", "False", "0.0", "False", "A", "0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		section.Data = append(section.Data, a)
+	}
+	return section
+}
+
+// testDigitalSection 时间戳为ts的数字量断面, P_NUM为奇数时dv为true
+func testDigitalSection(t *testing.T, ts int64, pNums ...int) DigitalSection {
+	t.Helper()
+	section := DigitalSection{Time: ts}
+	for _, pNum := range pNums {
+		_, d, err := ParseDigitalRecord([]string{strconv.FormatInt(ts, 10), strconv.Itoa(pNum), pythonBool(pNum%2 == 1), "False",
+			"False", "False", "False", "False", "False", "A", "0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		section.Data = append(section.Data, d)
+	}
+	return section
+}
+
+// mergeUnitsTests 每个机组的断面时间戳, 以及合并后每个断面中有数据的机组
+var mergeUnitsTests = []struct {
+	name  string
+	units [][]int64
+	times []int64
+	want  [][]int // want[i]为第i个断面中有数据的机组
+}{
+	{"单机组", [][]int64{{1, 2, 3}}, []int64{1, 2, 3}, [][]int{{0}, {0}, {0}}},
+	{"时间戳相同", [][]int64{{1, 2}, {1, 2}}, []int64{1, 2}, [][]int{{0, 1}, {0, 1}}},
+	{"时间戳交错", [][]int64{{1, 2, 4}, {2, 3}, {}}, []int64{1, 2, 3, 4}, [][]int{{0}, {0, 1}, {1}, {0}}},
+	{"机组为空", [][]int64{{}, {5}}, []int64{5}, [][]int{{1}}},
+	{"全部为空", [][]int64{{}, {}}, []int64{}, [][]int{}},
+}
+
+// unitsOf 断面中有数据的机组, count(i)为机组i的数据数量, pNum(i)为机组i第一个数据的P_NUM, 应为机组编号+1
+func unitsOf(t *testing.T, n int, count func(i int) int, pNum func(i int) int) []int {
+	t.Helper()
+	rtn := make([]int, 0)
+	for unitId := 0; unitId < n; unitId++ {
+		if count(unitId) == 0 {
+			continue
+		}
+		if count(unitId) != 1 || pNum(unitId) != unitId+1 {
+			t.Fatalf("机组%v的数据错误: %v", unitId, count(unitId))
+		}
+		rtn = append(rtn, unitId)
+	}
+	return rtn
+}
+
+func TestMergeAnalogUnits(t *testing.T) {
+	for _, tt := range mergeUnitsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			chs := make([]chan AnalogSection, len(tt.units))
+			for unitId, times := range tt.units {
+				chs[unitId] = make(chan AnalogSection, len(times))
+				for _, ts := range times {
+					chs[unitId] <- testAnalogSection(t, ts, unitId+1)
+				}
+				close(chs[unitId])
+			}
+			ch := make(chan AnalogSection)
+			go MergeAnalogUnits(chs, ch)
+
+			times, units := make([]int64, 0), make([][]int, 0)
+			for section := range ch {
+				if len(section.Data) != 0 || len(section.Units) != len(tt.units) {
+					t.Fatalf("合并后的断面应只有Units: %v, %v", len(section.Data), len(section.Units))
+				}
+				times = append(times, section.Time)
+				units = append(units, unitsOf(t, len(section.Units),
+					func(i int) int { return len(section.Units[i]) },
+					func(i int) int { return int(section.Units[i][0].p_num) }))
+			}
+			if !reflect.DeepEqual(times, tt.times) {
+				t.Fatalf("断面时间戳为%v, 应为%v", times, tt.times)
+			}
+			if !reflect.DeepEqual(units, tt.want) {
+				t.Fatalf("断面中的机组为%v, 应为%v", units, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeDigitalUnits(t *testing.T) {
+	for _, tt := range mergeUnitsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			chs := make([]chan DigitalSection, len(tt.units))
+			for unitId, times := range tt.units {
+				chs[unitId] = make(chan DigitalSection, len(times))
+				for _, ts := range times {
+					chs[unitId] <- testDigitalSection(t, ts, unitId+1)
+				}
+				close(chs[unitId])
+			}
+			ch := make(chan DigitalSection)
+			go MergeDigitalUnits(chs, ch)
+
+			times, units := make([]int64, 0), make([][]int, 0)
+			for section := range ch {
+				if len(section.Data) != 0 || len(section.Units) != len(tt.units) {
+					t.Fatalf("合并后的断面应只有Units: %v, %v", len(section.Data), len(section.Units))
+				}
+				times = append(times, section.Time)
+				units = append(units, unitsOf(t, len(section.Units),
+					func(i int) int { return len(section.Units[i]) },
+					func(i int) int { return int(section.Units[i][0].p_num) }))
+			}
+			if !reflect.DeepEqual(times, tt.times) {
+				t.Fatalf("断面时间戳为%v, 应为%v", times, tt.times)
+			}
+			if !reflect.DeepEqual(units, tt.want) {
+				t.Fatalf("断面中的机组为%v, 应为%v", units, tt.want)
+			}
+		})
+	}
+}
+
+// TestMergedUnitsWrite 合并后的断面通过mock插件写入, 每个机组只写入自己的数据, 没有数据的机组跳过
+func TestMergedUnitsWrite(t *testing.T) {
+	for _, tt := range mergeUnitsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			chs := make([]chan AnalogSection, len(tt.units))
+			for unitId, times := range tt.units {
+				chs[unitId] = make(chan AnalogSection, len(times))
+				for _, ts := range times {
+					chs[unitId] <- testAnalogSection(t, ts, unitId+1)
+				}
+				close(chs[unitId])
+			}
+			ch := make(chan AnalogSection)
+			go MergeAnalogUnits(chs, ch)
+
+			mock, err := NewMockPlugin("mock://?record=memory")
+			if err != nil {
+				t.Fatal(err)
+			}
+			plugin := NewWritePluginFromWriter(mock)
+			for section := range ch {
+				if errs := plugin.WriteRtAnalog(0, int64(len(tt.units)), section, true); len(errs) != 0 {
+					t.Fatal(errs)
+				}
+			}
+
+			got := make([]string, 0)
+			for _, call := range mock.Calls() {
+				got = append(got, fmt.Sprintf("%v@%v:%v", call.UnitId, call.Time[0], call.GlobalIDs[0]))
+			}
+			want := make([]string, 0)
+			for unitId, times := range tt.units {
+				for _, ts := range times {
+					want = append(want, fmt.Sprintf("%v@%v:%v", unitId, ts, GlobalID(0, int64(unitId), true, true, true, int32(unitId+1))))
+				}
+			}
+			sort.Strings(got)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("写入的调用为%v, 应为%v", got, want)
+			}
+		})
+	}
+}
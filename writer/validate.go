@@ -0,0 +1,494 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// CsvSchema CSV文件的列格式, 与 ParseAnalogRecord, ParseDigitalRecord, ParseStaticAnalogRecord, ParseStaticDigitalRecord 一致
+type CsvSchema struct {
+	Name    string   // 输出时的名称
+	Suffix  string   // 文件名后缀, 如REALTIME_FAST_STATIC_ANALOG.csv以STATIC_ANALOG.csv结尾
+	Header  []string // 表头
+	Static  bool     // 是否为静态文件, 静态文件没有TIME列, 整个文件为一个断面
+	Tew     int      // TEW的列序号, 为-1表示没有
+	Strings []int    // 字符串的列序号, 长度上限与插件接口中的缓冲区大小一致
+	parse   func(record []string) (int64, error)
+}
+
+// 数据集的CSV格式
+var (
+	StaticAnalogSchema = &CsvSchema{
+		Name:    "静态模拟量",
+		Suffix:  "STATIC_ANALOG.csv",
+		Header:  strings.Split("P_NUM,TAGT,FACK,L4AR,L3AR,L2AR,L1AR,H4AR,H3AR,H2AR,H1AR,CHN,PN,DESC,UNIT,MU,MD", ","),
+		Static:  true,
+		Tew:     -1,
+		Strings: []int{11, 12, 13, 14},
+		parse: func(record []string) (int64, error) {
+			_, err := ParseStaticAnalogRecord(record)
+			return 0, err
+		},
+	}
+	StaticDigitalSchema = &CsvSchema{
+		Name:    "静态数字量",
+		Suffix:  "STATIC_DIGITAL.csv",
+		Header:  strings.Split("P_NUM,FACK,CHN,PN,DESC,UNIT", ","),
+		Static:  true,
+		Tew:     -1,
+		Strings: []int{2, 3, 4, 5},
+		parse: func(record []string) (int64, error) {
+			_, err := ParseStaticDigitalRecord(record)
+			return 0, err
+		},
+	}
+	AnalogSchema = &CsvSchema{
+		Name:   "模拟量",
+		Suffix: "ANALOG.csv",
+		Header: strings.Split("TIME,P_NUM,AV,AVR,Q,BF,FQ,FAI,MS,TEW,CST", ","),
+		Tew:    9,
+		parse: func(record []string) (int64, error) {
+			ts, _, err := ParseAnalogRecord(record)
+			return ts, err
+		},
+	}
+	DigitalSchema = &CsvSchema{
+		Name:   "数字量",
+		Suffix: "DIGITAL.csv",
+		Header: strings.Split("TIME,P_NUM,DV,DVR,Q,BF,FQ,FAI,MS,TEW,CST", ","),
+		Tew:    9,
+		parse: func(record []string) (int64, error) {
+			ts, _, err := ParseDigitalRecord(record)
+			return ts, err
+		},
+	}
+)
+
+// CsvSchemas 支持校验的CSV格式, 按文件名后缀匹配时静态格式优先
+var CsvSchemas = []*CsvSchema{StaticAnalogSchema, StaticDigitalSchema, AnalogSchema, DigitalSchema}
+
+// CsvFlags 写入命令的CSV参数及其格式
+var CsvFlags = []struct {
+	Flag   string
+	Schema *CsvSchema
+}{
+	{"static_analog", StaticAnalogSchema},
+	{"static_digital", StaticDigitalSchema},
+	{"rt_fast_analog", AnalogSchema},
+	{"rt_fast_digital", DigitalSchema},
+	{"rt_normal_analog", AnalogSchema},
+	{"rt_normal_digital", DigitalSchema},
+	{"his_normal_analog", AnalogSchema},
+	{"his_normal_digital", DigitalSchema},
+}
+
+// 字符串列的缓冲区大小: chn, pn, desc, unit, 字符串需要以NUL结尾, 长度必须小于缓冲区大小
+var staticStringLimits = []struct {
+	Name  string
+	Limit int
+}{
+	{"CHN", 32},
+	{"PN", 32},
+	{"DESC", 128},
+	{"UNIT", 32},
+}
+
+// 校验的问题分类, 按输出顺序排列
+const (
+	IssueRead     = "CSV格式错误"
+	IssueHeader   = "表头错误"
+	IssueColumns  = "列数错误"
+	IssueParse    = "解析错误"
+	IssueTime     = "时间戳不单调"
+	IssueDup      = "P_NUM重复"
+	IssuePNum     = "P_NUM超出21位"
+	IssueTew      = "TEW长度错误"
+	IssueString   = "字符串超长"
+	IssueMismatch = "静态和动态P_NUM不一致"
+)
+
+// ValidateIssueKinds 问题分类的输出顺序
+var ValidateIssueKinds = []string{IssueRead, IssueHeader, IssueColumns, IssueParse, IssueTime, IssueDup, IssuePNum, IssueTew, IssueString, IssueMismatch}
+
+// CsvValidation 一个CSV文件的校验结果
+type CsvValidation struct {
+	Path      string
+	Schema    *CsvSchema
+	Rows      int64            // 数据行数, 不包括表头
+	Sections  int64            // 断面数量
+	Issues    map[string]int64 // 每个分类的问题数量
+	Lines     map[string][]int // 每个分类输出的问题行号
+	pnums     map[int64]int    // 文件中出现的P_NUM及其第一次出现的行号
+	maxIssues int
+}
+
+// ValidateCsvFiles 校验paths中的CSV文件, 目录会展开为其中的*.csv文件; maxIssues为每个文件每个分类最多输出的问题数, 为0表示全部输出
+// 先逐个校验文件, 再检查同一组静态文件和动态文件的P_NUM是否一致, 最后输出汇总, 有问题时返回错误
+func ValidateCsvFiles(paths []string, maxIssues int) error {
+	files, err := expandCsvPaths(paths)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("没有需要校验的CSV文件")
+	}
+
+	results := make([]*CsvValidation, 0, len(files))
+	for _, path := range files {
+		v, err := ValidateCsv(path, maxIssues)
+		if err != nil {
+			return err
+		}
+		results = append(results, v)
+	}
+	CheckStaticPNums(results)
+
+	total, bad := int64(0), 0
+	for _, v := range results {
+		n := v.IssueCount()
+		log.Printf("校验结果 - 文件: %v, 格式: %v, 行数: %v, 断面数量: %v, PNUM数量: %v, 问题数量: %v\n",
+			v.Path, v.Schema.Name, v.Rows, v.Sections, len(v.pnums), n)
+		log.Printf("问题分类: %v\n", v.IssueSummary())
+		total += n
+		if n > 0 {
+			bad++
+		}
+	}
+	log.Printf("校验完成 - 文件数量: %v, 有问题的文件数量: %v, 问题总数: %v\n", len(results), bad, total)
+	if total > 0 {
+		return fmt.Errorf("CSV校验失败, 问题总数: %v", total)
+	}
+	return nil
+}
+
+// expandCsvPaths 展开目录中的*.csv文件, 按文件名排序
+func expandCsvPaths(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.csv"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// DetectCsvSchema 按文件名后缀识别CSV格式, 无法识别时按表头识别
+func DetectCsvSchema(path string) (*CsvSchema, error) {
+	for _, schema := range CsvSchemas {
+		if strings.HasSuffix(strings.ToUpper(filepath.Base(path)), strings.ToUpper(schema.Suffix)) {
+			return schema, nil
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	header, err := NewCsvReader(file).Read()
+	if err != nil {
+		return nil, fmt.Errorf("无法识别CSV格式: %v, %v", path, err)
+	}
+	for _, schema := range CsvSchemas {
+		if strings.Join(header, ",") == strings.Join(schema.Header, ",") {
+			return schema, nil
+		}
+	}
+	return nil, fmt.Errorf("无法识别CSV格式: %v, 文件名和表头都不匹配", path)
+}
+
+// ValidateCsv 校验一个CSV文件, 每个问题输出 文件:行号: 分类, 详情
+func ValidateCsv(path string, maxIssues int) (*CsvValidation, error) {
+	schema, err := DetectCsvSchema(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	log.Printf("校验文件: %v, 格式: %v\n", path, schema.Name)
+
+	v := &CsvValidation{
+		Path:      path,
+		Schema:    schema,
+		Issues:    make(map[string]int64),
+		Lines:     make(map[string][]int),
+		pnums:     make(map[int64]int),
+		maxIssues: maxIssues,
+	}
+	reader := NewCsvReader(file)
+	reader.ReuseRecord = true
+
+	section := make(map[int64]int) // 当前断面的P_NUM及其行号
+	lastTs := int64(-1)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			v.report(CsvLine(reader, err), IssueRead, "%v", err)
+			continue
+		}
+		line := CsvLine(reader, nil)
+
+		// 表头
+		if first {
+			if record[0] == schema.Header[0] {
+				if strings.Join(record, ",") != strings.Join(schema.Header, ",") {
+					v.report(line, IssueHeader, "应为 %v", strings.Join(schema.Header, ","))
+				}
+				continue
+			}
+			v.report(line, IssueHeader, "缺少表头")
+		} else if record[0] == schema.Header[0] {
+			v.report(line, IssueHeader, "重复的表头")
+			continue
+		}
+		v.Rows++
+
+		if len(record) != len(schema.Header) {
+			v.report(line, IssueColumns, "列数为%v, 应为%v", len(record), len(schema.Header))
+			continue
+		}
+
+		// TEW和字符串单独检查, 解析时使用合法的TEW, 以免掩盖其他列的错误
+		if schema.Tew >= 0 && len(record[schema.Tew]) != 1 {
+			v.report(line, IssueTew, "TEW为%q, 长度应为1", record[schema.Tew])
+			record = append([]string(nil), record...)
+			record[schema.Tew] = "A"
+		}
+		for i, col := range schema.Strings {
+			if limit := staticStringLimits[i]; len(record[col]) >= limit.Limit {
+				v.report(line, IssueString, "%v长度为%v字节, 应小于%v字节(包括结尾的NUL)", limit.Name, len(record[col]), limit.Limit)
+			}
+		}
+		ts, err := schema.parse(record)
+		if err != nil {
+			v.report(line, IssueParse, "%v", strings.TrimSpace(err.Error()))
+			continue
+		}
+
+		// 断面: 动态文件按连续相同的时间戳划分, 静态文件整个文件为一个断面
+		if !schema.Static {
+			if lastTs != -1 && ts < lastTs {
+				v.report(line, IssueTime, "时间戳%v小于上一行的%v", ts, lastTs)
+			}
+			if ts != lastTs {
+				v.Sections++
+				section = make(map[int64]int)
+				lastTs = ts
+			}
+		} else if v.Sections == 0 {
+			v.Sections = 1
+		}
+
+		pNumCol := 1
+		if schema.Static {
+			pNumCol = 0
+		}
+		pNum, err := strconv.ParseInt(record[pNumCol], 10, 32)
+		if err != nil {
+			v.report(line, IssueParse, "P_NUM %q无法解析", record[pNumCol])
+			continue
+		}
+		if pNum < 0 || pNum > 0x1FFFFF {
+			v.report(line, IssuePNum, "P_NUM %v超出[0, %v], 写入时会被GlobalID截断", pNum, 0x1FFFFF)
+		}
+		if dup, ok := section[pNum]; ok {
+			v.report(line, IssueDup, "P_NUM %v与第%v行重复", pNum, dup)
+		} else {
+			section[pNum] = line
+		}
+		if _, ok := v.pnums[pNum]; !ok {
+			v.pnums[pNum] = line
+		}
+	}
+	return v, nil
+}
+
+// CheckStrictCsv 严格模式(--strict)下在登录前检查命令的所有CSV文件, 按机组读取时检查每个机组的文件
+// 遇到第一个无法读取, 列数错误或无法解析的行时返回 文件:行号 的错误; 不是严格模式时直接返回nil
+func CheckStrictCsv(cmd *cobra.Command, options CsvOptions) error {
+	if strict, _ := cmd.Flags().GetBool("strict"); !strict {
+		return nil
+	}
+	start := time.Now()
+	count := 0
+	for _, f := range CsvFlags {
+		if cmd.Flags().Lookup(f.Flag) == nil {
+			continue
+		}
+		path, _ := cmd.Flags().GetString(f.Flag)
+		if path == "" {
+			continue
+		}
+		for _, p := range options.csvPaths(path) {
+			if err := ScanCsvStrict(p, f.Schema); err != nil {
+				return fmt.Errorf("严格模式检查CSV失败: %v", err)
+			}
+			count++
+		}
+	}
+	log.Printf("严格模式检查CSV通过, 文件数量: %v, 耗时: %v\n", count, time.Since(start))
+	return nil
+}
+
+// ScanCsvStrict 按写入时的读取方式检查CSV文件, 返回第一个会被跳过的行: 无法读取, 列数错误或无法解析, 表头除外
+func ScanCsvStrict(path string, schema *CsvSchema) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	reader := NewCsvReader(file)
+	reader.ReuseRecord = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v:%v: %v", path, CsvLine(reader, err), err)
+		}
+		if _, err := schema.parse(record); err != nil && !strings.Contains(err.Error(), "continue HEAD") {
+			return fmt.Errorf("%v:%v: %v", path, CsvLine(reader, nil), strings.TrimSpace(err.Error()))
+		}
+	}
+}
+
+// unitCsvPattern 按机组读取时的文件名前缀, 见 UnitCsvPrefix
+var unitCsvPattern = regexp.MustCompile(`^unit_\d+_`)
+
+// staticGroup 静态文件和动态文件的分组, 去掉机组前缀和STATIC_后相同的文件为一组
+// 如unit_0_1718350759143_REALTIME_FAST_ANALOG.csv与1718350759143_REALTIME_FAST_STATIC_ANALOG.csv
+func staticGroup(path string) string {
+	name := unitCsvPattern.ReplaceAllString(filepath.Base(path), "")
+	return filepath.Join(filepath.Dir(path), strings.Replace(name, "STATIC_", "", 1))
+}
+
+// CheckStaticPNums 检查同一组静态文件和动态文件的P_NUM集合是否一致, 不一致的P_NUM报告在其出现的文件和行号上
+func CheckStaticPNums(results []*CsvValidation) {
+	groups := make(map[string][]*CsvValidation)
+	keys := make([]string, 0)
+	for _, v := range results {
+		key := staticGroup(v.Path)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], v)
+	}
+	for _, key := range keys {
+		statics, dynamics := make([]*CsvValidation, 0), make([]*CsvValidation, 0)
+		for _, v := range groups[key] {
+			if v.Schema.Static {
+				statics = append(statics, v)
+			} else {
+				dynamics = append(dynamics, v)
+			}
+		}
+		if len(statics) == 0 || len(dynamics) == 0 {
+			continue
+		}
+		for _, s := range statics {
+			for _, d := range dynamics {
+				comparePNums(d, s)
+				comparePNums(s, d)
+			}
+		}
+	}
+}
+
+// comparePNums 报告v中有但other中没有的P_NUM
+func comparePNums(v *CsvValidation, other *CsvValidation) {
+	missing := make([]int64, 0)
+	for pNum := range v.pnums {
+		if _, ok := other.pnums[pNum]; !ok {
+			missing = append(missing, pNum)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	for _, pNum := range missing {
+		v.report(v.pnums[pNum], IssueMismatch, "P_NUM %v不在%v中", pNum, other.Path)
+	}
+}
+
+// report 记录一个问题, 每个分类最多输出maxIssues个, line为0表示不在某一行上
+func (v *CsvValidation) report(line int, kind string, format string, args ...interface{}) {
+	v.Issues[kind]++
+	if v.maxIssues > 0 && v.Issues[kind] > int64(v.maxIssues) {
+		return
+	}
+	v.Lines[kind] = append(v.Lines[kind], line)
+	if line == 0 {
+		log.Printf("%v: %v, %v\n", v.Path, kind, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf("%v:%v: %v, %v\n", v.Path, line, kind, fmt.Sprintf(format, args...))
+}
+
+// IssueCount 问题总数
+func (v *CsvValidation) IssueCount() int64 {
+	n := int64(0)
+	for _, count := range v.Issues {
+		n += count
+	}
+	return n
+}
+
+// IssueSummary 每个分类的问题数量和输出的行号, 没有问题时为"无"
+func (v *CsvValidation) IssueSummary() string {
+	parts := make([]string, 0)
+	for _, kind := range ValidateIssueKinds {
+		count := v.Issues[kind]
+		if count == 0 {
+			continue
+		}
+		lines := make([]string, 0, len(v.Lines[kind]))
+		for _, line := range v.Lines[kind] {
+			if line > 0 {
+				lines = append(lines, strconv.Itoa(line))
+			}
+		}
+		part := fmt.Sprintf("%v: %v", kind, count)
+		if len(lines) > 0 {
+			more := ""
+			if int64(len(v.Lines[kind])) < count {
+				more = "..."
+			}
+			part += fmt.Sprintf("(第%v%v行)", strings.Join(lines, ", "), more)
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AddValidateFlags 添加校验命令的命令行参数
+func AddValidateFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("max_issues", 10, "每个文件每个问题分类最多输出的问题数量, 为0表示全部输出, 汇总中的数量不受限制")
+}
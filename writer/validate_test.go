@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeTestCsv 在临时目录中写入CSV文件, rows为每一行的内容, 以"\n"结尾
+func writeTestCsv(t *testing.T, name string, rows ...string) string {
+	t.Helper()
+	return writeTestCsvEol(t, name, "\n", rows...)
+}
+
+// writeTestCsvEol 在临时目录中写入CSV文件, 每一行以eol结尾
+func writeTestCsvEol(t *testing.T, name string, eol string, rows ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(strings.Join(rows, eol)+eol), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const (
+	testAnalogHeader = "TIME,P_NUM,AV,AVR,Q,BF,FQ,FAI,MS,TEW,CST"
+	testAnalogRow    = ",1.0,0.5,False,False,False,0.0,False,A,0" // P_NUM之后的列
+
+	testStaticDigitalHeader = "P_NUM,FACK,CHN,PN,DESC,UNIT"
+)
+
+// testCsvEols 测试的行尾, 数据集中的CSV文件以"\r\r\n"结尾
+var testCsvEols = []struct {
+	name string
+	eol  string
+}{
+	{"LF", "\n"},
+	{"CRCRLF", "\r\r\n"},
+	{"CRCR", "\r\r"},
+	{"CRLF", "\r\n"},
+	{"CR", "\r"},
+}
+
+func TestValidateCsvLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      []string
+		maxIssues int
+		lines     map[string][]int // 每个分类输出的问题行号
+		issues    map[string]int64 // 每个分类的问题数量, 为nil时与lines的数量相同
+		sections  int64
+	}{
+		{"没有问题", []string{testAnalogHeader, "1000,1" + testAnalogRow, "1000,2" + testAnalogRow, "1010,1" + testAnalogRow},
+			0, map[string][]int{}, nil, 2},
+		{"各类问题", []string{
+			testAnalogHeader,
+			"1000,1" + testAnalogRow,
+			"1000,2,1.0",
+			"1000,3,x,0.5,False,False,False,0.0,False,A,0",
+			"1000,1" + testAnalogRow,
+			"999,2" + testAnalogRow,
+			"999,3,1.0,0.5,False,False,False,0.0,False,AB,0",
+			"999,3000000" + testAnalogRow,
+		}, 0, map[string][]int{
+			IssueColumns: {3}, IssueParse: {4}, IssueDup: {5}, IssueTime: {6}, IssueTew: {7}, IssuePNum: {8},
+		}, nil, 2},
+		{"缺少表头", []string{"1000,1" + testAnalogRow, testAnalogHeader, "1000,2" + testAnalogRow},
+			0, map[string][]int{IssueHeader: {1, 2}}, nil, 1},
+		{"表头错误", []string{"TIME,P_NUM,AV,AVR,Q,BF,FQ,FAI,MS,TEW", "1000,1" + testAnalogRow},
+			0, map[string][]int{IssueHeader: {1}}, nil, 1},
+		{"跨行的引号字段", []string{testAnalogHeader, "1000,1,1.0,0.5,False,False,False,0.0,False,\"A", "B\",0", "1000,2,x" + testAnalogRow[4:]},
+			0, map[string][]int{IssueTew: {2}, IssueParse: {4}}, nil, 1},
+		{"CSV格式错误", []string{testAnalogHeader, "1000,1" + testAnalogRow, "1000,2,\"1.0\"x" + testAnalogRow[4:]},
+			0, map[string][]int{IssueRead: {3}}, nil, 1},
+		{"限制输出的问题数", []string{testAnalogHeader, "1000,1,x", "1000,2,x", "1000,3,x"},
+			2, map[string][]int{IssueColumns: {2, 3}}, map[string]int64{IssueColumns: 3}, 0},
+	}
+	for _, eol := range testCsvEols {
+		for _, tt := range tests {
+			t.Run(eol.name+"/"+tt.name, func(t *testing.T) {
+				path := writeTestCsvEol(t, "1718350759143_REALTIME_FAST_ANALOG.csv", eol.eol, tt.rows...)
+				v, err := ValidateCsv(path, tt.maxIssues)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(v.Lines, tt.lines) {
+					t.Fatalf("问题行号为%v, 应为%v", v.Lines, tt.lines)
+				}
+				issues := tt.issues
+				if issues == nil {
+					issues = make(map[string]int64)
+					for kind, lines := range tt.lines {
+						issues[kind] = int64(len(lines))
+					}
+				}
+				if !reflect.DeepEqual(v.Issues, issues) {
+					t.Fatalf("问题数量为%v, 应为%v", v.Issues, issues)
+				}
+				if v.Sections != tt.sections {
+					t.Fatalf("断面数量为%v, 应为%v", v.Sections, tt.sections)
+				}
+			})
+		}
+	}
+}
+
+// TestValidateCsvDataset 数据集中的CSV文件以"\r\r\n"结尾, 问题的行号为文件中的物理行号
+func TestValidateCsvDataset(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "CSV20240614", "1718350759143_REALTIME_FAST_STATIC_DIGITAL.csv"))
+	if err != nil {
+		t.Skip(err)
+	}
+	rows := strings.Split(strings.TrimSuffix(string(data), "\r\r\n"), "\r\r\n")
+	if len(rows) < 5 {
+		t.Fatalf("数据集的行数为%v, 行尾不是\\r\\r\\n", len(rows))
+	}
+	rows[3] = "x" + rows[3]
+	path := writeTestCsvEol(t, "1718350759143_REALTIME_FAST_STATIC_DIGITAL.csv", "\r\r\n", rows...)
+	v, err := ValidateCsv(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := v.Lines[IssueParse]; !reflect.DeepEqual(lines, []int{4}) {
+		t.Fatalf("解析错误的行号为%v, 应为[4]", lines)
+	}
+	if v.Rows != int64(len(rows)-1) {
+		t.Fatalf("数据行数为%v, 应为%v", v.Rows, len(rows)-1)
+	}
+}
+
+// TestValidateCsvStrings 字符串需要以NUL结尾, 长度等于缓冲区大小时也是问题
+func TestValidateCsvStrings(t *testing.T) {
+	tests := []struct {
+		name  string
+		row   string
+		lines []int
+	}{
+		{"CHN为31字节", "1,0," + strings.Repeat("c", 31) + ",p,d,u", nil},
+		{"CHN为32字节", "1,0," + strings.Repeat("c", 32) + ",p,d,u", []int{2}},
+		{"PN为32字节", "1,0,c," + strings.Repeat("p", 32) + ",d,u", []int{2}},
+		{"DESC为127字节", "1,0,c,p," + strings.Repeat("d", 127) + ",u", nil},
+		{"DESC为128字节", "1,0,c,p," + strings.Repeat("d", 128) + ",u", []int{2}},
+		{"UNIT为33字节", "1,0,c,p,d," + strings.Repeat("u", 33), []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestCsv(t, "1718350759143_REALTIME_FAST_STATIC_DIGITAL.csv", testStaticDigitalHeader, tt.row)
+			v, err := ValidateCsv(path, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lines := v.Lines[IssueString]; !reflect.DeepEqual(lines, tt.lines) {
+				t.Fatalf("字符串超长的行号为%v, 应为%v", lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestCheckStaticPNums(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, rows ...string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	static := write("1718350759143_REALTIME_FAST_STATIC_DIGITAL.csv",
+		testStaticDigitalHeader, "1,0,c,p,d,u", "2,0,c,p,d,u")
+	dynamic := write("1718350759143_REALTIME_FAST_DIGITAL.csv",
+		"TIME,P_NUM,DV,DVR,Q,BF,FQ,FAI,MS,TEW,CST",
+		"1000,1,True,False,False,False,False,False,False,A,0",
+		"1000,3,True,False,False,False,False,False,False,A,0")
+	results := make([]*CsvValidation, 0)
+	for _, path := range []string{static, dynamic} {
+		v, err := ValidateCsv(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, v)
+	}
+	CheckStaticPNums(results)
+	if lines := results[0].Lines[IssueMismatch]; !reflect.DeepEqual(lines, []int{3}) {
+		t.Fatalf("静态文件中不一致的P_NUM行号为%v, 应为[3]", lines)
+	}
+	if lines := results[1].Lines[IssueMismatch]; !reflect.DeepEqual(lines, []int{3}) {
+		t.Fatalf("动态文件中不一致的P_NUM行号为%v, 应为[3]", lines)
+	}
+}
+
+func TestScanCsvStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema *CsvSchema
+		rows   []string
+		line   string // 错误中的 :行号:, 为空表示没有错误
+	}{
+		{"没有问题", AnalogSchema, []string{testAnalogHeader, "1000,1" + testAnalogRow, "1010,1" + testAnalogRow}, ""},
+		{"只有表头", AnalogSchema, []string{testAnalogHeader}, ""},
+		{"没有表头", AnalogSchema, []string{"1000,1" + testAnalogRow}, ""},
+		{"解析错误", AnalogSchema, []string{testAnalogHeader, "1000,1" + testAnalogRow, "1000,2,x" + testAnalogRow[4:]}, ":3:"},
+		{"列数错误", AnalogSchema, []string{testAnalogHeader, "1000,1,1.0"}, ":2:"},
+		{"返回第一个问题", AnalogSchema, []string{testAnalogHeader, "1000,1,1.0", "1000,2,x" + testAnalogRow[4:]}, ":2:"},
+		{"CSV格式错误", AnalogSchema, []string{testAnalogHeader, "1000,2,\"1.0\"x" + testAnalogRow[4:]}, ":2:"},
+		{"跨行的记录取起始行号", AnalogSchema, []string{testAnalogHeader, "1000,1,1.0,0.5,False,False,False,0.0,False,\"A", "\",0", "x,1" + testAnalogRow}, ":2:"},
+		{"静态文件", StaticDigitalSchema, []string{testStaticDigitalHeader, "1,0,c,p,d,u"}, ""},
+		{"静态文件解析错误", StaticDigitalSchema, []string{testStaticDigitalHeader, "1,0,c,p,d,u", "x,0,c,p,d,u"}, ":3:"},
+	}
+	for _, eol := range testCsvEols {
+		for _, tt := range tests {
+			t.Run(eol.name+"/"+tt.name, func(t *testing.T) {
+				path := writeTestCsvEol(t, "test.csv", eol.eol, tt.rows...)
+				err := ScanCsvStrict(path, tt.schema)
+				if tt.line == "" {
+					if err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+				if err == nil || !strings.HasPrefix(err.Error(), path+tt.line) {
+					t.Fatalf("错误为%v, 应以%v%v开头", err, path, tt.line)
+				}
+			})
+		}
+	}
+}
+
+// TestReadCsvSkippedLine 写入时跳过的行在日志中输出文件中的物理行号, 与 ScanCsvStrict 一致
+func TestReadCsvSkippedLine(t *testing.T) {
+	path := writeTestCsvEol(t, "REALTIME_FAST_ANALOG.csv", "\r\r\n",
+		testAnalogHeader, "1000,1"+testAnalogRow, "1000,2"+testAnalogRow, "1010,x"+testAnalogRow, "1010,1"+testAnalogRow)
+	if err := ScanCsvStrict(path, AnalogSchema); err == nil || !strings.HasPrefix(err.Error(), path+":4:") {
+		t.Fatalf("严格模式的错误为%v, 应以%v:4:开头", err, path)
+	}
+
+	output := new(bytes.Buffer)
+	log.SetOutput(output)
+	defer log.SetOutput(os.Stderr)
+	ch := make(chan AnalogSection, 16)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go ReadAnalogCsv(wg, path, ch, make(chan bool), nil, CsvOptions{Loop: 1})
+	for range ch {
+	}
+	wg.Wait()
+	if !strings.Contains(output.String(), path+":4: parse pNum error") {
+		t.Fatalf("日志中没有第4行的解析错误: %v", output.String())
+	}
+}
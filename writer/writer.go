@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+
+	"writer/sdk"
+)
+
+// PluginInfo 插件信息, 对应 plugin/write_plugin.h 中的 PluginInfo
+type PluginInfo = sdk.PluginInfo
+
+// Writer 插件后端, 对应插件的登录/登出以及8个写入接口
+// 每次调用写入一个机组的数据, 数据的GlobalID已经初始化完成
+// 写入接口返回nil表示写入成功, 失败时返回 *WriteError
+type Writer interface {
+	Info() PluginInfo
+	Login(param string) int
+	Logout()
+	WriteRtAnalog(magic int32, unitId int64, section AnalogSection, isFast bool) error
+	WriteRtDigital(magic int32, unitId int64, section DigitalSection, isFast bool) error
+	WriteRtAnalogList(magic int32, unitId int64, sections []AnalogSection) error
+	WriteRtDigitalList(magic int32, unitId int64, sections []DigitalSection) error
+	WriteHisAnalog(magic int32, unitId int64, section AnalogSection) error
+	WriteHisDigital(magic int32, unitId int64, section DigitalSection) error
+	WriteStaticAnalog(magic int32, unitId int64, section StaticAnalogSection, typ int64) error
+	WriteStaticDigital(magic int32, unitId int64, section StaticDigitalSection, typ int64) error
+}
+
+// NewWriter 根据插件路径创建插件后端
+// * mock://...: 内置mock插件, 参考 NewMockPlugin
+// * goplugin://path: Go插件, 参考 NewGoPluginWriter
+// * exec://command: 外部进程插件, 参考 NewProcessWriter
+// * host://path: 在插件宿主进程中加载插件, 参考 NewHostWriter
+// * http://host:port, https://host:port: 网络插件, 参考 NewHTTPWriter
+// * 其他: C动态库插件, 参考 NewDylibWriter
+func NewWriter(path string) (Writer, error) {
+	switch {
+	case IsMockPlugin(path):
+		return NewMockPlugin(path)
+	case strings.HasPrefix(path, GoPluginScheme):
+		return NewGoPluginWriter(strings.TrimPrefix(path, GoPluginScheme))
+	case strings.HasPrefix(path, ProcessPluginScheme):
+		return NewProcessWriter(strings.TrimPrefix(path, ProcessPluginScheme))
+	case strings.HasPrefix(path, HostPluginScheme):
+		return NewHostWriter(strings.TrimPrefix(path, HostPluginScheme))
+	case IsHTTPPlugin(path):
+		return NewHTTPWriter(path)
+	default:
+		return NewDylibWriter(path)
+	}
+}